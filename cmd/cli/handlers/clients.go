@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+	"github.com/AleksaS003/zastitaprojekat/internal/network"
+)
+
+func HandleClients(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Expected 'add', 'list' or 'remove' subcommand")
+		fmt.Println("Usage: crypto-cli clients <add|list|remove> [options]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		handleClientsAdd(args[1:])
+	case "list":
+		handleClientsList(args[1:])
+	case "remove":
+		handleClientsRemove(args[1:])
+	default:
+		fmt.Printf("Unknown subcommand: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func openClients(path string, create bool) *network.ClientRegistry {
+	if _, err := os.Stat(path); os.IsNotExist(err) && create {
+		return network.NewClientRegistry(path)
+	}
+
+	registry, err := network.LoadClients(path)
+	if err != nil {
+		log.Fatal("Failed to open clients file:", err)
+	}
+	return registry
+}
+
+func handleClientsAdd(args []string) {
+	cmd := flag.NewFlagSet("clients add", flag.ExitOnError)
+	file := cmd.String("file", "clients.json", "Clients file")
+	id := cmd.String("id", "", "Client identity (required)")
+	allow := cmd.String("allow", "", "Comma-separated IP addresses or CIDR ranges (default: any)")
+	secretOut := cmd.String("secret-out", "", "Write the client secret to this file (default: <id>.secret)")
+	download := cmd.Bool("download", false, "Allow the client to list and download files")
+
+	cmd.Parse(args)
+
+	if *id == "" {
+		logger.Error(logger.CLIENT_AUTH, "Missing client id", nil)
+		log.Fatal("--id is required")
+	}
+
+	var allowList []string
+	for _, entry := range strings.Split(*allow, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			allowList = append(allowList, entry)
+		}
+	}
+
+	outputFile := *secretOut
+	if outputFile == "" {
+		outputFile = *id + ".secret"
+	}
+
+	registry := openClients(*file, true)
+	secret, err := registry.Add(*id, allowList, *download)
+	if err != nil {
+		log.Fatal("Failed to add client:", err)
+	}
+
+	if err := os.WriteFile(outputFile, []byte(fmt.Sprintf("%x\n", secret)), 0600); err != nil {
+		log.Fatal("Failed to write client secret:", err)
+	}
+	if err := registry.Save(); err != nil {
+		log.Fatal("Failed to save clients file:", err)
+	}
+
+	logger.Info(logger.CLIENT_AUTH, "Client added", true, map[string]interface{}{
+		"clients_file": *file,
+		"client_id":    *id,
+		"allow":        allowList,
+		"download":     *download,
+	})
+
+	fmt.Printf("✓ Client '%s' added to %s\n", *id, *file)
+	if len(allowList) > 0 {
+		fmt.Printf("  Allowed from: %s\n", strings.Join(allowList, ", "))
+	} else {
+		fmt.Printf("  Allowed from: any address\n")
+	}
+	fmt.Printf("  Download:     %t\n", *download)
+	fmt.Printf("  Secret:       %s (give it to the client, use with --client-secret)\n", outputFile)
+}
+
+func handleClientsList(args []string) {
+	cmd := flag.NewFlagSet("clients list", flag.ExitOnError)
+	file := cmd.String("file", "clients.json", "Clients file")
+
+	cmd.Parse(args)
+
+	entries := openClients(*file, false).List()
+	if len(entries) == 0 {
+		fmt.Printf("Clients file %s is empty\n", *file)
+		return
+	}
+
+	fmt.Printf("%-20s %-9s %s\n", "ID", "DOWNLOAD", "ALLOW")
+	for _, entry := range entries {
+		allow := "any"
+		if len(entry.Allow) > 0 {
+			allow = strings.Join(entry.Allow, ", ")
+		}
+		fmt.Printf("%-20s %-9t %s\n", entry.ID, entry.Download, allow)
+	}
+}
+
+func handleClientsRemove(args []string) {
+	cmd := flag.NewFlagSet("clients remove", flag.ExitOnError)
+	file := cmd.String("file", "clients.json", "Clients file")
+	id := cmd.String("id", "", "Client identity (required)")
+
+	cmd.Parse(args)
+
+	if *id == "" {
+		logger.Error(logger.CLIENT_AUTH, "Missing client id", nil)
+		log.Fatal("--id is required")
+	}
+
+	registry := openClients(*file, false)
+	if err := registry.Remove(*id); err != nil {
+		log.Fatal("Failed to remove client:", err)
+	}
+	if err := registry.Save(); err != nil {
+		log.Fatal("Failed to save clients file:", err)
+	}
+
+	logger.Info(logger.CLIENT_AUTH, "Client removed", true, map[string]interface{}{
+		"clients_file": *file,
+		"client_id":    *id,
+	})
+
+	fmt.Printf("✓ Client '%s' removed from %s\n", *id, *file)
+}
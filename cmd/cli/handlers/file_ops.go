@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"bytes"
+	"crypto/ecdh"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -9,33 +9,56 @@ import (
 	"os"
 	"strings"
 
+	"github.com/AleksaS003/zastitaprojekat/cmd/cli/utils"
 	"github.com/AleksaS003/zastitaprojekat/internal/core"
+	"github.com/AleksaS003/zastitaprojekat/internal/keypair"
 	"github.com/AleksaS003/zastitaprojekat/internal/logger"
 )
 
 func HandleEncryptFile(args []string) {
 	cmd := flag.NewFlagSet("encrypt-file", flag.ExitOnError)
 	file := cmd.String("file", "", "File to encrypt (required)")
-	keyfile := cmd.String("keyfile", "", "Encryption key file (required)")
+	keyfile := cmd.String("keyfile", "", "Encryption key file")
+	keyname := cmd.String("keyname", "", "Name of key in keystore")
 	algorithm := cmd.String("algo", "LEA-PCBC", "Algorithm: LEA, LEA-PCBC")
 	output := cmd.String("output", "", "Output file (optional)")
+	var recipientFiles utils.StringList
+	cmd.Var(&recipientFiles, "recipient", "Recipient X25519 public key file (repeatable)")
+	signKey := cmd.String("sign-key", "", "Ed25519 private key to embed a signature with (optional)")
 
 	cmd.Parse(args)
 
-	if *file == "" || *keyfile == "" {
+	if *file == "" || (*keyfile == "" && *keyname == "" && len(recipientFiles) == 0) {
 		logger.Error(logger.ActivityType("ENCRYPT_FILE"), "Missing required arguments", nil)
-		log.Fatal("Both --file and --keyfile are required")
+		log.Fatal("--file and --keyfile (or --keyname, or --recipient) are required")
 	}
 
-	keyBytes, err := os.ReadFile(*keyfile)
-	if err != nil {
-		logger.Error(logger.ActivityType("ENCRYPT_FILE"), "Failed to read key file", map[string]interface{}{
-			"keyfile": *keyfile,
-			"error":   err.Error(),
-		})
-		log.Fatal("Failed to read key file:", err)
+	var keyBytes []byte
+	if *keyfile != "" || *keyname != "" {
+		var err error
+		keyBytes, err = utils.LoadKeyFile(*keyfile, *keyname)
+		if err != nil {
+			logger.Error(logger.ActivityType("ENCRYPT_FILE"), "Failed to load key", map[string]interface{}{
+				"keyfile": *keyfile,
+				"keyname": *keyname,
+				"error":   err.Error(),
+			})
+			log.Fatal("Failed to load key:", err)
+		}
+	}
+
+	var recipients []*ecdh.PublicKey
+	for _, path := range recipientFiles {
+		publicKey, err := keypair.LoadX25519PublicKey(path)
+		if err != nil {
+			logger.Error(logger.ActivityType("ENCRYPT_FILE"), "Failed to load recipient key", map[string]interface{}{
+				"recipient": path,
+				"error":     err.Error(),
+			})
+			log.Fatal("Failed to load recipient key:", err)
+		}
+		recipients = append(recipients, publicKey)
 	}
-	keyBytes = bytes.TrimSpace(keyBytes)
 
 	outputFile := *output
 	if outputFile == "" {
@@ -48,11 +71,29 @@ func HandleEncryptFile(args []string) {
 		"algorithm":   *algorithm,
 		"key_size":    len(keyBytes) * 8,
 		"keyfile":     *keyfile,
+		"recipients":  len(recipients),
 	})
 
 	processor := core.NewFileProcessor()
 
-	err = processor.EncryptFileWithMetadata(*file, outputFile, *algorithm, keyBytes)
+	if *signKey != "" {
+		signingKey, err := keypair.LoadEd25519PrivateKey(*signKey)
+		if err != nil {
+			logger.Error(logger.ActivityType("ENCRYPT_FILE"), "Failed to load signing key", map[string]interface{}{
+				"sign_key": *signKey,
+				"error":    err.Error(),
+			})
+			log.Fatal("Failed to load signing key:", err)
+		}
+		processor.SetSigningKey(signingKey)
+	}
+
+	var err error
+	if len(recipients) > 0 {
+		err = processor.EncryptFileForRecipients(*file, outputFile, *algorithm, keyBytes, recipients)
+	} else {
+		err = processor.EncryptFileWithMetadata(*file, outputFile, *algorithm, keyBytes)
+	}
 	if err != nil {
 		logger.Error(logger.ActivityType("ENCRYPT_FILE"), "Encryption failed", map[string]interface{}{
 			"input_file": *file,
@@ -66,6 +107,15 @@ func HandleEncryptFile(args []string) {
 	fmt.Printf("  Input:  %s\n", *file)
 	fmt.Printf("  Output: %s\n", outputFile)
 	fmt.Printf("  Algorithm: %s\n", *algorithm)
+	if keyBytes != nil {
+		fmt.Printf("  Key fingerprint: %s\n", core.KeyFingerprint(keyBytes))
+	}
+	for i, publicKey := range recipients {
+		fmt.Printf("  Recipient: %s (%s)\n", recipientFiles[i], keypair.Fingerprint(publicKey.Bytes()))
+	}
+	if *signKey != "" {
+		fmt.Printf("  Signed with: %s\n", *signKey)
+	}
 
 	data, _ := os.ReadFile(outputFile)
 	if len(data) >= 4 {
@@ -84,25 +134,41 @@ func HandleEncryptFile(args []string) {
 func HandleDecryptFile(args []string) {
 	cmd := flag.NewFlagSet("decrypt-file", flag.ExitOnError)
 	file := cmd.String("file", "", "File to decrypt (required)")
-	keyfile := cmd.String("keyfile", "", "Decryption key file (required)")
+	keyfile := cmd.String("keyfile", "", "Decryption key file")
+	keyname := cmd.String("keyname", "", "Name of key in keystore")
+	identity := cmd.String("identity", "", "X25519 private key file (for files encrypted to recipients)")
 	output := cmd.String("output", "", "Output file (optional)")
 
 	cmd.Parse(args)
 
-	if *file == "" || *keyfile == "" {
+	if *file == "" || (*keyfile == "" && *keyname == "" && *identity == "") {
 		logger.Error(logger.ActivityType("DECRYPT_FILE"), "Missing required arguments", nil)
-		log.Fatal("Both --file and --keyfile are required")
+		log.Fatal("--file and --keyfile (or --keyname, or --identity) are required")
 	}
 
-	keyBytes, err := os.ReadFile(*keyfile)
-	if err != nil {
-		logger.Error(logger.ActivityType("DECRYPT_FILE"), "Failed to read key file", map[string]interface{}{
-			"keyfile": *keyfile,
-			"error":   err.Error(),
-		})
-		log.Fatal("Failed to read key file:", err)
+	var keyBytes []byte
+	var privateKey *ecdh.PrivateKey
+	var err error
+	if *identity != "" {
+		privateKey, err = keypair.LoadX25519PrivateKey(*identity)
+		if err != nil {
+			logger.Error(logger.ActivityType("DECRYPT_FILE"), "Failed to load identity", map[string]interface{}{
+				"identity": *identity,
+				"error":    err.Error(),
+			})
+			log.Fatal("Failed to load identity:", err)
+		}
+	} else {
+		keyBytes, err = utils.LoadKeyFile(*keyfile, *keyname)
+		if err != nil {
+			logger.Error(logger.ActivityType("DECRYPT_FILE"), "Failed to load key", map[string]interface{}{
+				"keyfile": *keyfile,
+				"keyname": *keyname,
+				"error":   err.Error(),
+			})
+			log.Fatal("Failed to load key:", err)
+		}
 	}
-	keyBytes = bytes.TrimSpace(keyBytes)
 
 	outputFile := *output
 	if outputFile == "" {
@@ -118,11 +184,21 @@ func HandleDecryptFile(args []string) {
 		"output_file": outputFile,
 		"key_size":    len(keyBytes) * 8,
 		"keyfile":     *keyfile,
+		"identity":    *identity,
 	})
 
 	processor := core.NewFileProcessor()
 
-	metadata, err := processor.DecryptFileWithMetadata(*file, outputFile, keyBytes)
+	var metadata *core.Metadata
+	if privateKey != nil {
+		metadata, err = processor.DecryptFileWithIdentity(*file, outputFile, privateKey)
+	} else {
+		if fileMetadata, err := core.ReadMetadata(*file); err == nil && fileMetadata.KeyInfo != "" {
+			fmt.Printf("  File was encrypted with key %s, you supplied %s\n",
+				fileMetadata.KeyInfo, core.KeyFingerprint(keyBytes))
+		}
+		metadata, err = processor.DecryptFileWithMetadata(*file, outputFile, keyBytes)
+	}
 	if err != nil {
 		logger.Error(logger.ActivityType("DECRYPT_FILE"), "Decryption failed", map[string]interface{}{
 			"input_file": *file,
@@ -151,3 +227,100 @@ func HandleDecryptFile(args []string) {
 		fmt.Printf("  IV used: %s...\n", metadata.IV[:16])
 	}
 }
+
+func HandleInspect(args []string) {
+	cmd := flag.NewFlagSet("inspect", flag.ExitOnError)
+	file := cmd.String("file", "", "Encrypted file to inspect (required)")
+	keyfile := cmd.String("keyfile", "", "Key file to compare against (optional)")
+	keyname := cmd.String("keyname", "", "Name of key in keystore to compare against (optional)")
+
+	cmd.Parse(args)
+
+	if *file == "" {
+		logger.Error(logger.ActivityType("INSPECT"), "Missing required arguments", nil)
+		log.Fatal("--file is required")
+	}
+
+	metadata, err := core.ReadMetadata(*file)
+	if err != nil {
+		logger.Error(logger.ActivityType("INSPECT"), "Failed to read metadata", map[string]interface{}{
+			"file":  *file,
+			"error": err.Error(),
+		})
+		log.Fatal("Failed to read metadata:", err)
+	}
+
+	logger.Info(logger.ActivityType("INSPECT"), "Metadata inspected", true, map[string]interface{}{
+		"file":      *file,
+		"algorithm": metadata.EncryptionAlgorithm,
+		"key_info":  metadata.KeyInfo,
+	})
+
+	fmt.Printf("File: %s\n", *file)
+	fmt.Printf("  Original filename: %s\n", metadata.Filename)
+	fmt.Printf("  Original size:     %d bytes\n", metadata.Size)
+	fmt.Printf("  Encrypted at:      %s\n", metadata.Timestamp.Format("2006-01-02 15:04:05 MST"))
+	fmt.Printf("  Algorithm:         %s\n", metadata.EncryptionAlgorithm)
+	if metadata.Hash != "" {
+		fmt.Printf("  Hash (%s):    %s\n", metadata.HashAlgorithm, metadata.Hash)
+		if metadata.HashVersion == core.HashVersionLegacy {
+			fmt.Printf("                     legacy digest, run rekey to upgrade\n")
+		}
+	}
+	if metadata.IV != "" {
+		fmt.Printf("  IV:                %s\n", metadata.IV)
+	}
+	if metadata.KeyInfo != "" {
+		fmt.Printf("  Key fingerprint:   %s\n", metadata.KeyInfo)
+	} else {
+		fmt.Printf("  Key fingerprint:   (not recorded)\n")
+	}
+	if metadata.WrappedKey != "" {
+		fmt.Printf("  Data key:          wrapped (%s)\n", metadata.KeyWrapAlgorithm)
+	} else if len(metadata.Recipients) == 0 {
+		fmt.Printf("  Data key:          none (encrypted directly with master key)\n")
+	}
+	for _, recipient := range metadata.Recipients {
+		fmt.Printf("  Recipient:         %s\n", recipient.Fingerprint)
+	}
+	if metadata.Signature != nil {
+		fmt.Printf("  Signature:         %s by %s (not verified, use verify-signature)\n",
+			metadata.Signature.Algorithm, metadata.Signature.Signer)
+	}
+
+	if *keyfile == "" && *keyname == "" {
+		return
+	}
+
+	keyBytes, err := utils.LoadKeyFile(*keyfile, *keyname)
+	if err != nil {
+		logger.Error(logger.ActivityType("INSPECT"), "Failed to load key", map[string]interface{}{
+			"keyfile": *keyfile,
+			"keyname": *keyname,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to load key:", err)
+	}
+
+	report, ok := keyReport(metadata, keyBytes)
+	fmt.Printf("\n%s\n", report)
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// keyReport poredi otisak ključa sa otiskom iz metapodataka. ok je false samo
+// kada se otisci razlikuju; stari kontejneri nemaju otisak i ne mogu se proveriti.
+func keyReport(metadata *core.Metadata, key []byte) (report string, ok bool) {
+	supplied := core.KeyFingerprint(key)
+	if metadata.KeyInfo == "" {
+		return fmt.Sprintf("File has no key fingerprint stored (legacy container), you supplied %s\n"+
+			"Key cannot be checked without decrypting the file", supplied), true
+	}
+
+	report = fmt.Sprintf("File was encrypted with key %s, you supplied %s\n", metadata.KeyInfo, supplied)
+	if err := metadata.CheckKey(key); err != nil {
+		return report + "Key does NOT match", false
+	}
+	return report + "Key matches", true
+}
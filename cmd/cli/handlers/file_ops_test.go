@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+)
+
+func TestKeyReport(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 16)
+	other := bytes.Repeat([]byte{0x24}, 16)
+
+	tests := []struct {
+		name    string
+		keyInfo string
+		want    string
+		wantOK  bool
+	}{
+		{"matching key", core.KeyFingerprint(key), "Key matches", true},
+		{"different key", core.KeyFingerprint(other), "Key does NOT match", false},
+		{"legacy container", "", "no key fingerprint stored (legacy container)", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, ok := keyReport(&core.Metadata{KeyInfo: tt.keyInfo}, key)
+			if ok != tt.wantOK || !strings.Contains(report, tt.want) {
+				t.Errorf("got %q, %v; want %q, %v", report, ok, tt.want, tt.wantOK)
+			}
+			if strings.Contains(report, "key ,") {
+				t.Errorf("report prints an empty fingerprint: %q", report)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
 	"log"
@@ -9,6 +8,7 @@ import (
 	"os/signal"
 	"path/filepath"
 
+	"github.com/AleksaS003/zastitaprojekat/cmd/cli/utils"
 	"github.com/AleksaS003/zastitaprojekat/internal/fsw"
 	"github.com/AleksaS003/zastitaprojekat/internal/logger"
 )
@@ -41,25 +41,26 @@ func handleFSWStart(args []string) {
 	cmd := flag.NewFlagSet("fsw start", flag.ExitOnError)
 	watchDir := cmd.String("watch", "./watch", "Directory to watch")
 	outputDir := cmd.String("output", "./encrypted", "Output directory for encrypted files")
-	keyfile := cmd.String("keyfile", "", "Encryption key file (required)")
+	keyfile := cmd.String("keyfile", "", "Encryption key file")
+	keyname := cmd.String("keyname", "", "Name of key in keystore")
 	algorithm := cmd.String("algo", "LEA-PCBC", "Algorithm: LEA, LEA-PCBC")
 
 	cmd.Parse(args)
 
-	if *keyfile == "" {
+	if *keyfile == "" && *keyname == "" {
 		logger.Error(logger.FSW_START, "Keyfile not specified", nil)
-		log.Fatal("--keyfile is required")
+		log.Fatal("--keyfile or --keyname is required")
 	}
 
-	keyBytes, err := os.ReadFile(*keyfile)
+	keyBytes, err := utils.LoadKeyFile(*keyfile, *keyname)
 	if err != nil {
-		logger.Error(logger.FSW_START, "Failed to read key file", map[string]interface{}{
+		logger.Error(logger.FSW_START, "Failed to load key", map[string]interface{}{
 			"keyfile": *keyfile,
+			"keyname": *keyname,
 			"error":   err.Error(),
 		})
-		log.Fatal("Failed to read key file:", err)
+		log.Fatal("Failed to load key:", err)
 	}
-	keyBytes = bytes.TrimSpace(keyBytes)
 
 	watcher, err := fsw.NewFileSystemWatcher(*watchDir, *outputDir, *algorithm, keyBytes)
 	if err != nil {
@@ -134,27 +135,28 @@ func handleFSWEncryptExisting(args []string) {
 	cmd := flag.NewFlagSet("fsw encrypt-existing", flag.ExitOnError)
 	watchDir := cmd.String("watch", "./watch", "Directory with existing files")
 	outputDir := cmd.String("output", "./encrypted", "Output directory")
-	keyfile := cmd.String("keyfile", "", "Encryption key file (required)")
+	keyfile := cmd.String("keyfile", "", "Encryption key file")
+	keyname := cmd.String("keyname", "", "Name of key in keystore")
 	algorithm := cmd.String("algo", "LEA-PCBC", "Algorithm: LEA, LEA-PCBC")
 
 	cmd.Parse(args)
 
-	if *keyfile == "" {
+	if *keyfile == "" && *keyname == "" {
 
 		logger.Error(logger.ActivityType("FSW_ENCRYPT_EXISTING"), "Keyfile not specified", nil)
-		log.Fatal("--keyfile is required")
+		log.Fatal("--keyfile or --keyname is required")
 	}
 
-	keyBytes, err := os.ReadFile(*keyfile)
+	keyBytes, err := utils.LoadKeyFile(*keyfile, *keyname)
 	if err != nil {
 
-		logger.Error(logger.ActivityType("FSW_ENCRYPT_EXISTING"), "Failed to read key file", map[string]interface{}{
+		logger.Error(logger.ActivityType("FSW_ENCRYPT_EXISTING"), "Failed to load key", map[string]interface{}{
 			"keyfile": *keyfile,
+			"keyname": *keyname,
 			"error":   err.Error(),
 		})
-		log.Fatal("Failed to read key file:", err)
+		log.Fatal("Failed to load key:", err)
 	}
-	keyBytes = bytes.TrimSpace(keyBytes)
 
 	watcher, err := fsw.NewFileSystemWatcher(*watchDir, *outputDir, *algorithm, keyBytes)
 	if err != nil {
@@ -12,7 +12,15 @@ Usage:
 
 Commands:
   server        - Start TCP server to receive files
-  client        - Send file to TCP server
+  client        - Send files or a directory to TCP server
+    list        - List files on server (needs --allow-download)
+    get         - Download file from server
+    pull        - Download and remove all files waiting on a relay
+  relay         - Hold encrypted files for recipients that pull them later
+  clients       - Manage clients allowed to connect to server
+    add         - Add client identity and generate its secret
+    list        - List clients
+    remove      - Remove client
   
   fsw           - File System Watcher
     start       - Start watching directory
@@ -21,6 +29,10 @@ Commands:
   
   encrypt-file  - Encrypt file with metadata
   decrypt-file  - Decrypt file with metadata
+  rekey         - Re-encrypt .enc files under a new key
+  inspect       - Show metadata and key fingerprint of .enc file
+  sign          - Create detached Ed25519 signature (.sig)
+  verify-signature - Verify detached or embedded signature
   
   foursquare    - Use Foursquare cipher
     encrypt     - Encrypt text/file
@@ -36,6 +48,18 @@ Commands:
     encrypt     - Encrypt file
     decrypt     - Decrypt file
   
+  key           - Manage encrypted keystore
+    add         - Add existing key under a name
+    generate    - Generate new named key
+    list        - List stored keys
+    export      - Export key to file
+    delete      - Delete key
+    split       - Split key into Shamir shares
+    combine     - Recover key from shares
+
+  keypair       - Manage public-key identities
+    generate    - Generate X25519 (encryption) or Ed25519 (signing) key pair
+
   sha256        - Use SHA-256 hash function
     hash        - Hash text/file
     verify      - Verify file hash
@@ -64,6 +88,33 @@ Examples:
   crypto-cli pcbc encrypt --file=data.txt --keyfile=key.bin --output=data.enc
   crypto-cli pcbc decrypt --file=data.enc --keyfile=key.bin --output=data.txt
 
+  # Inspect encrypted file
+  crypto-cli inspect --file=data.txt.enc --keyfile=key.bin
+
+  # Key rotation
+  crypto-cli rekey --old-keyfile=old.bin --new-keyfile=new.bin --dir=./encrypted
+  crypto-cli rekey --old-keyfile=old.bin --new-keyfile=new.bin --dir=./encrypted --algo=LEA
+
+  # Keystore (any --keyfile can be replaced with --keyname)
+  crypto-cli key generate --name=backup --size=256
+  crypto-cli key add --name=legacy --keyfile=old.bin
+  crypto-cli key list
+  crypto-cli encrypt-file --file=data.txt --keyname=backup
+  crypto-cli key split --keyfile=master.bin --shares=5 --threshold=3 --output=master
+  crypto-cli key combine --shares=master.share1,master.share3,master.share5 --output=master.bin
+
+  # Public-key recipients
+  crypto-cli keypair generate --output=alice
+  crypto-cli encrypt-file --file=data.txt --recipient=alice.pub --recipient=bob.pub
+  crypto-cli decrypt-file --file=data.txt.enc --identity=alice.key
+
+  # Signatures
+  crypto-cli keypair generate --type=ed25519 --output=signer
+  crypto-cli sign --file=report.pdf --key=signer.key
+  crypto-cli verify-signature --file=report.pdf --sig=report.pdf.sig --pubkey=signer.pub
+  crypto-cli encrypt-file --file=data.txt --keyfile=key.bin --sign-key=signer.key
+  crypto-cli verify-signature --file=data.txt.enc --pubkey=signer.pub
+
   # SHA-256
   crypto-cli sha256 hash --file=document.pdf
   crypto-cli sha256 verify --file=document.pdf --hashfile=document.pdf.sha256
@@ -75,12 +126,54 @@ Examples:
   # TCP Server/Client
   crypto-cli server --address=:8080 --output=./received --keyfile=key.bin
   crypto-cli client --address=localhost:8080 --file=data.txt --keyfile=key.bin
+  crypto-cli server --keyfile=key.bin --trusted-signer=alice.pub --trusted-signer=bob.pub
+  crypto-cli client --file=data.txt --keyfile=key.bin --sign-key=alice.key
+  crypto-cli client --file=big.iso --keyfile=key.bin --retries=10 --retry-delay=5s
+  crypto-cli client --dir=./outbox --keyfile=key.bin
+  crypto-cli client --files=a.txt,b.txt --keyfile=key.bin
+  crypto-cli server --keyfile=key.bin --allow-download
+  crypto-cli client list --keyfile=key.bin
+  crypto-cli client get --name=report.txt --output=report.txt --keyfile=key.bin
+  crypto-cli server --keyfile=key.bin --max-connections=8 --max-transfer-size=104857600 --read-timeout=30s
+  crypto-cli server --keyfile=key.bin --on-collision=reject
+  crypto-cli server --keyfile=key.bin --staging-expiry=6h --staging-quota=2G --staging-transfers=4
+  crypto-cli server --keyfile=key.bin --progress
+  crypto-cli server --keyfile=key.bin --shutdown-timeout=2m
+  crypto-cli server --keyfile=key.bin --rate-limit=1M --total-rate-limit=10M --conn-rate=30
+  crypto-cli server --keyfile=key.bin --inbox --storage-keyfile=storage.bin
+  crypto-cli decrypt-file --file=received/report.pdf.enc --keyfile=storage.bin
+  crypto-cli server --keyfile=key.bin --on-receive='mv "$CRYPTO_PATH" /srv/incoming/' --hook-timeout=10s
+  crypto-cli server --keyfile=key.bin --spool-dir=./events
+  crypto-cli server --keyfile=key.bin --address=unix:///run/crypto/crypto.sock --socket-mode=0660
+  crypto-cli client --keyfile=key.bin --address=unix:///run/crypto/crypto.sock --file=data.txt
+  crypto-cli client --file=big.iso --keyfile=key.bin --rate-limit=512K
+  crypto-cli client --file=big.iso --keyfile=key.bin --progress=false
+
+  # Relay (store-and-forward between machines that cannot reach each other)
+  crypto-cli relay --keyfile=relay.key --clients=clients.json --expiry=72h --mailbox-quota=1G
+  crypto-cli client --address=relay:9000 --relay-key=relay.key --client-id=alice --client-secret=alice.secret --to=bob --file=data.txt --keyfile=key.bin
+  crypto-cli client pull --address=relay:9000 --relay-key=relay.key --client-id=bob --client-secret=bob.secret --keyfile=key.bin --output=./inbox
+
+  # Client authentication
+  crypto-cli clients add --id=alice --allow=127.0.0.1,10.0.0.0/8
+  crypto-cli clients add --id=bob --download
+  crypto-cli server --keyfile=key.bin --clients=clients.json
+  crypto-cli client --file=data.txt --keyfile=key.bin --client-id=alice --client-secret=alice.secret
 
   # Log Management
   crypto-cli logs show -n 100
   crypto-cli logs stats
   crypto-cli logs clear --yes
 
+Key files:
+  - --keyfile of lea and pcbc is hex-decoded when it holds 32-64 hex characters
+  - every other --keyfile (encrypt-file, decrypt-file, server, client, relay,
+    fsw, rekey, inspect, key add/split) is used as raw bytes
+
+Keystore:
+  - keystore.json (override with CRYPTO_KEYSTORE)
+  - password is read from CRYPTO_KEYSTORE_PASSWORD or prompted on stdin
+
 Log Files:
   - logs/crypto-app.log     - Text logs (human readable)
   - logs/activity-log.json  - JSON logs (for analysis)
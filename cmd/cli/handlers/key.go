@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/AleksaS003/zastitaprojekat/cmd/cli/utils"
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+	"github.com/AleksaS003/zastitaprojekat/internal/shamir"
+)
+
+func HandleKey(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Expected 'add', 'list', 'export', 'delete', 'generate', 'split' or 'combine' subcommand")
+		fmt.Println("Usage: crypto-cli key <add|list|export|delete|generate|split|combine> [options]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		handleKeyAdd(args[1:])
+	case "list":
+		handleKeyList(args[1:])
+	case "export":
+		handleKeyExport(args[1:])
+	case "delete":
+		handleKeyDelete(args[1:])
+	case "generate":
+		handleKeyGenerate(args[1:])
+	case "split":
+		handleKeySplit(args[1:])
+	case "combine":
+		handleKeyCombine(args[1:])
+	default:
+		fmt.Printf("Unknown subcommand: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func handleKeyAdd(args []string) {
+	cmd := flag.NewFlagSet("key add", flag.ExitOnError)
+	keystorePath := cmd.String("keystore", utils.KeystorePath(), "Keystore file")
+	name := cmd.String("name", "", "Key name (required)")
+	key := cmd.String("key", "", "Key as hex string")
+	keyFile := cmd.String("keyfile", "", "File containing key")
+	algorithm := cmd.String("algo", "LEA", "Key algorithm")
+
+	cmd.Parse(args)
+
+	if *name == "" {
+		logger.Error(logger.KEYSTORE, "Missing key name", nil)
+		log.Fatal("--name is required")
+	}
+
+	// --keyfile se čita kao sirovi ključ, kao u encrypt-file
+	var keyBytes []byte
+	var err error
+	if *key != "" {
+		keyBytes, err = utils.LoadKey(*key, "", "")
+	} else {
+		keyBytes, err = utils.LoadKeyFile(*keyFile, "")
+	}
+	if err != nil {
+		logger.Error(logger.KEYSTORE, "Failed to load key", map[string]interface{}{
+			"keyfile": *keyFile,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to load key:", err)
+	}
+
+	ks, err := utils.OpenKeystore(*keystorePath, true)
+	if err != nil {
+		log.Fatal("Failed to open keystore:", err)
+	}
+
+	entry, err := ks.Add(*name, *algorithm, keyBytes)
+	if err != nil {
+		log.Fatal("Failed to add key:", err)
+	}
+
+	if err := ks.Save(); err != nil {
+		log.Fatal("Failed to save keystore:", err)
+	}
+
+	fmt.Printf("✓ Key '%s' added to %s\n", entry.Name, ks.Path())
+	fmt.Printf("  Algorithm:   %s\n", entry.Algorithm)
+	fmt.Printf("  Key size:    %d bits\n", len(keyBytes)*8)
+	fmt.Printf("  Fingerprint: %s\n", entry.Fingerprint)
+}
+
+func handleKeyGenerate(args []string) {
+	cmd := flag.NewFlagSet("key generate", flag.ExitOnError)
+	keystorePath := cmd.String("keystore", utils.KeystorePath(), "Keystore file")
+	name := cmd.String("name", "", "Key name (required)")
+	size := cmd.Int("size", 256, "Key size: 128, 192, or 256")
+
+	cmd.Parse(args)
+
+	if *name == "" {
+		logger.Error(logger.KEYSTORE, "Missing key name", nil)
+		log.Fatal("--name is required")
+	}
+
+	ks, err := utils.OpenKeystore(*keystorePath, true)
+	if err != nil {
+		log.Fatal("Failed to open keystore:", err)
+	}
+
+	entry, err := ks.Generate(*name, *size)
+	if err != nil {
+		log.Fatal("Failed to generate key:", err)
+	}
+
+	if err := ks.Save(); err != nil {
+		log.Fatal("Failed to save keystore:", err)
+	}
+
+	fmt.Printf("✓ Generated LEA %d-bit key '%s' in %s\n", *size, entry.Name, ks.Path())
+	fmt.Printf("  Fingerprint: %s\n", entry.Fingerprint)
+}
+
+func handleKeyList(args []string) {
+	cmd := flag.NewFlagSet("key list", flag.ExitOnError)
+	keystorePath := cmd.String("keystore", utils.KeystorePath(), "Keystore file")
+
+	cmd.Parse(args)
+
+	ks, err := utils.OpenKeystore(*keystorePath, false)
+	if err != nil {
+		log.Fatal("Failed to open keystore:", err)
+	}
+
+	entries := ks.List()
+	if len(entries) == 0 {
+		fmt.Printf("Keystore %s is empty\n", ks.Path())
+		return
+	}
+
+	fmt.Printf("%-20s %-10s %-6s %-18s %s\n", "NAME", "ALGORITHM", "BITS", "FINGERPRINT", "CREATED")
+	for _, entry := range entries {
+		fmt.Printf("%-20s %-10s %-6d %-18s %s\n",
+			entry.Name, entry.Algorithm, len(entry.Key)*4, entry.Fingerprint,
+			entry.Created.Format("2006-01-02 15:04:05"))
+	}
+}
+
+func handleKeyExport(args []string) {
+	cmd := flag.NewFlagSet("key export", flag.ExitOnError)
+	keystorePath := cmd.String("keystore", utils.KeystorePath(), "Keystore file")
+	name := cmd.String("name", "", "Key name (required)")
+	output := cmd.String("output", "", "Output key file (required)")
+	asHex := cmd.Bool("hex", false, "Write key as hex string instead of raw bytes")
+
+	cmd.Parse(args)
+
+	if *name == "" || *output == "" {
+		logger.Error(logger.KEYSTORE, "Missing required arguments", nil)
+		log.Fatal("Both --name and --output are required")
+	}
+
+	ks, err := utils.OpenKeystore(*keystorePath, false)
+	if err != nil {
+		log.Fatal("Failed to open keystore:", err)
+	}
+
+	entry, err := ks.Get(*name)
+	if err != nil {
+		log.Fatal("Failed to export key:", err)
+	}
+
+	keyBytes, err := entry.KeyBytes()
+	if err != nil {
+		log.Fatal("Failed to export key:", err)
+	}
+
+	data := keyBytes
+	if *asHex {
+		data = []byte(entry.Key)
+	}
+
+	if err := os.WriteFile(*output, data, 0600); err != nil {
+		logger.Error(logger.KEYSTORE, "Failed to write key file", map[string]interface{}{
+			"output_file": *output,
+			"error":       err.Error(),
+		})
+		log.Fatal("Failed to write key file:", err)
+	}
+
+	logger.Info(logger.KEYSTORE, "Key exported from keystore", true, map[string]interface{}{
+		"name":        entry.Name,
+		"output_file": *output,
+		"fingerprint": entry.Fingerprint,
+	})
+
+	fmt.Printf("✓ Key '%s' exported to %s\n", entry.Name, *output)
+	fmt.Printf("  Fingerprint: %s\n", entry.Fingerprint)
+}
+
+func handleKeyDelete(args []string) {
+	cmd := flag.NewFlagSet("key delete", flag.ExitOnError)
+	keystorePath := cmd.String("keystore", utils.KeystorePath(), "Keystore file")
+	name := cmd.String("name", "", "Key name (required)")
+
+	cmd.Parse(args)
+
+	if *name == "" {
+		logger.Error(logger.KEYSTORE, "Missing key name", nil)
+		log.Fatal("--name is required")
+	}
+
+	ks, err := utils.OpenKeystore(*keystorePath, false)
+	if err != nil {
+		log.Fatal("Failed to open keystore:", err)
+	}
+
+	if err := ks.Delete(*name); err != nil {
+		log.Fatal("Failed to delete key:", err)
+	}
+
+	if err := ks.Save(); err != nil {
+		log.Fatal("Failed to save keystore:", err)
+	}
+
+	fmt.Printf("✓ Key '%s' deleted from %s\n", *name, ks.Path())
+}
+
+func handleKeySplit(args []string) {
+	cmd := flag.NewFlagSet("key split", flag.ExitOnError)
+	keyFile := cmd.String("keyfile", "", "Key file to split")
+	keyName := cmd.String("keyname", "", "Name of key in keystore to split")
+	shares := cmd.Int("shares", 5, "Number of shares to create")
+	threshold := cmd.Int("threshold", 3, "Number of shares needed to recover the key")
+	output := cmd.String("output", "key", "Output prefix for share files (<prefix>.share<N>)")
+
+	cmd.Parse(args)
+
+	if *keyFile == "" && *keyName == "" {
+		logger.Error(logger.KEYSTORE, "Missing key for split", nil)
+		log.Fatal("--keyfile or --keyname is required")
+	}
+
+	keyBytes, err := utils.LoadKeyFile(*keyFile, *keyName)
+	if err != nil {
+		logger.Error(logger.KEYSTORE, "Failed to load key", map[string]interface{}{
+			"keyfile": *keyFile,
+			"keyname": *keyName,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to load key:", err)
+	}
+
+	parts, err := shamir.Split(keyBytes, *shares, *threshold)
+	if err != nil {
+		logger.Error(logger.KEYSTORE, "Failed to split key", map[string]interface{}{
+			"shares":    *shares,
+			"threshold": *threshold,
+			"error":     err.Error(),
+		})
+		log.Fatal("Failed to split key:", err)
+	}
+
+	fingerprint := core.KeyFingerprint(keyBytes)
+
+	var files []string
+	for _, part := range parts {
+		part.Fingerprint = fingerprint
+
+		path := fmt.Sprintf("%s.share%d", *output, part.Index)
+		if err := os.WriteFile(path, []byte(part.String()+"\n"), 0600); err != nil {
+			logger.Error(logger.KEYSTORE, "Failed to write share file", map[string]interface{}{
+				"output_file": path,
+				"error":       err.Error(),
+			})
+			log.Fatal("Failed to write share file:", err)
+		}
+		files = append(files, path)
+	}
+
+	logger.Info(logger.KEYSTORE, "Key split into shares", true, map[string]interface{}{
+		"fingerprint": fingerprint,
+		"shares":      *shares,
+		"threshold":   *threshold,
+		"files":       files,
+	})
+
+	fmt.Printf("✓ Key %s split into %d shares (any %d recover it)\n", fingerprint, *shares, *threshold)
+	for _, path := range files {
+		fmt.Printf("   - %s\n", path)
+	}
+	fmt.Println("Store each share in a different place.")
+}
+
+func handleKeyCombine(args []string) {
+	cmd := flag.NewFlagSet("key combine", flag.ExitOnError)
+	shareFiles := cmd.String("shares", "", "Comma-separated share files (required)")
+	output := cmd.String("output", "", "Output key file (required)")
+
+	cmd.Parse(args)
+
+	if *shareFiles == "" || *output == "" {
+		logger.Error(logger.KEYSTORE, "Missing required arguments", nil)
+		log.Fatal("Both --shares and --output are required")
+	}
+
+	var parts []*shamir.Share
+	for _, path := range strings.Split(*shareFiles, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatal("Failed to read share file:", err)
+		}
+
+		part, err := shamir.ParseShare(string(data))
+		if err != nil {
+			logger.Error(logger.KEYSTORE, "Invalid share file", map[string]interface{}{
+				"file":  path,
+				"error": err.Error(),
+			})
+			log.Fatalf("Invalid share %s: %v", path, err)
+		}
+		parts = append(parts, part)
+	}
+
+	keyBytes, err := shamir.Combine(parts)
+	if err != nil {
+		logger.Error(logger.KEYSTORE, "Failed to combine shares", map[string]interface{}{
+			"share_count": len(parts),
+			"error":       err.Error(),
+		})
+		log.Fatal("Failed to combine shares:", err)
+	}
+
+	expected := parts[0].Fingerprint
+	if actual := core.KeyFingerprint(keyBytes); actual != expected {
+		logger.Error(logger.KEYSTORE, "Recovered key fingerprint mismatch", map[string]interface{}{
+			"expected": expected,
+			"actual":   actual,
+		})
+		log.Fatalf("Recovered key has fingerprint %s, expected %s (wrong combination of shares)", actual, expected)
+	}
+
+	if err := os.WriteFile(*output, keyBytes, 0600); err != nil {
+		logger.Error(logger.KEYSTORE, "Failed to write key file", map[string]interface{}{
+			"output_file": *output,
+			"error":       err.Error(),
+		})
+		log.Fatal("Failed to write key file:", err)
+	}
+
+	logger.Info(logger.KEYSTORE, "Key recovered from shares", true, map[string]interface{}{
+		"fingerprint": expected,
+		"share_count": len(parts),
+		"output_file": *output,
+	})
+
+	fmt.Printf("✓ Key %s recovered from %d shares\n", expected, len(parts))
+	fmt.Printf("  Output: %s (%d bits)\n", *output, len(keyBytes)*8)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/keypair"
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+func HandleKeypair(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Expected 'generate' subcommand")
+		fmt.Println("Usage: crypto-cli keypair generate [options]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "generate":
+		handleKeypairGenerate(args[1:])
+	default:
+		fmt.Printf("Unknown subcommand: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func handleKeypairGenerate(args []string) {
+	cmd := flag.NewFlagSet("keypair generate", flag.ExitOnError)
+	keyType := cmd.String("type", "x25519", "Key type: x25519 (encryption), ed25519 (signing)")
+	output := cmd.String("output", "identity", "Output prefix (<prefix>.key and <prefix>.pub)")
+
+	cmd.Parse(args)
+
+	privatePath := *output + ".key"
+	publicPath := *output + ".pub"
+
+	var fingerprint, usage string
+	var err error
+	switch strings.ToLower(*keyType) {
+	case "x25519":
+		fingerprint, err = generateX25519(privatePath, publicPath)
+		usage = "share with senders"
+	case "ed25519":
+		fingerprint, err = generateEd25519(privatePath, publicPath)
+		usage = "share with verifiers"
+	default:
+		log.Fatalf("Unsupported key type: %s (use x25519 or ed25519)", *keyType)
+	}
+	if err != nil {
+		logger.Error(logger.ActivityType("KEYPAIR"), "Failed to generate key pair", map[string]interface{}{
+			"type":  *keyType,
+			"error": err.Error(),
+		})
+		log.Fatal("Failed to generate key pair:", err)
+	}
+
+	logger.Info(logger.ActivityType("KEYPAIR"), "Key pair generated", true, map[string]interface{}{
+		"type":        *keyType,
+		"private_key": privatePath,
+		"public_key":  publicPath,
+		"fingerprint": fingerprint,
+	})
+
+	fmt.Printf("✓ %s key pair generated\n", strings.ToUpper(*keyType))
+	fmt.Printf("  Private key: %s (keep secret)\n", privatePath)
+	fmt.Printf("  Public key:  %s (%s)\n", publicPath, usage)
+	fmt.Printf("  Fingerprint: %s\n", fingerprint)
+}
+
+func generateX25519(privatePath, publicPath string) (string, error) {
+	privateKey, err := keypair.GenerateX25519()
+	if err != nil {
+		return "", err
+	}
+	if err := keypair.SaveX25519PrivateKey(privatePath, privateKey); err != nil {
+		return "", err
+	}
+	if err := keypair.SaveX25519PublicKey(publicPath, privateKey.PublicKey()); err != nil {
+		return "", err
+	}
+	return keypair.Fingerprint(privateKey.PublicKey().Bytes()), nil
+}
+
+func generateEd25519(privatePath, publicPath string) (string, error) {
+	publicKey, privateKey, err := keypair.GenerateEd25519()
+	if err != nil {
+		return "", err
+	}
+	if err := keypair.SaveEd25519PrivateKey(privatePath, privateKey); err != nil {
+		return "", err
+	}
+	if err := keypair.SaveEd25519PublicKey(publicPath, publicKey); err != nil {
+		return "", err
+	}
+	return keypair.Fingerprint(publicKey), nil
+}
@@ -42,6 +42,7 @@ func handleLEAEncrypt(args []string) {
 	file := cmd.String("file", "", "File to encrypt (required)")
 	key := cmd.String("key", "", "Encryption key (hex string)")
 	keyFile := cmd.String("keyfile", "", "File containing encryption key")
+	keyName := cmd.String("keyname", "", "Name of encryption key in keystore")
 	output := cmd.String("output", "", "Output file (required)")
 
 	cmd.Parse(args)
@@ -54,7 +55,7 @@ func handleLEAEncrypt(args []string) {
 		log.Fatal("Both --file and --output are required")
 	}
 
-	keyBytes, err := utils.LoadKey(*key, *keyFile)
+	keyBytes, err := utils.LoadKey(*key, *keyFile, *keyName)
 	if err != nil {
 		logger.Error("LEA_ENCRYPT", "Failed to load key", map[string]interface{}{
 			"key_provided":     *key != "",
@@ -125,6 +126,7 @@ func handleLEADecrypt(args []string) {
 	file := cmd.String("file", "", "File to decrypt (required)")
 	key := cmd.String("key", "", "Decryption key (hex string)")
 	keyFile := cmd.String("keyfile", "", "File containing decryption key")
+	keyName := cmd.String("keyname", "", "Name of decryption key in keystore")
 	output := cmd.String("output", "", "Output file (required)")
 
 	cmd.Parse(args)
@@ -137,7 +139,7 @@ func handleLEADecrypt(args []string) {
 		log.Fatal("Both --file and --output are required")
 	}
 
-	keyBytes, err := utils.LoadKey(*key, *keyFile)
+	keyBytes, err := utils.LoadKey(*key, *keyFile, *keyName)
 	if err != nil {
 		logger.Error("LEA_DECRYPT", "Failed to load key", map[string]interface{}{
 			"error": err.Error(),
@@ -1,154 +1,587 @@
 package handlers
 
 import (
-	"bytes"
+	"context"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/AleksaS003/zastitaprojekat/cmd/cli/utils"
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+	"github.com/AleksaS003/zastitaprojekat/internal/keypair"
 	"github.com/AleksaS003/zastitaprojekat/internal/logger"
 	"github.com/AleksaS003/zastitaprojekat/internal/network"
 )
 
 func HandleTCPServer(args []string) {
 	cmd := flag.NewFlagSet("server", flag.ExitOnError)
-	address := cmd.String("address", ":8080", "Server address (host:port)")
+	address := cmd.String("address", ":8080", "Server address (host:port or unix:///path/to.sock)")
+	socketMode := cmd.String("socket-mode", "0600", "Permissions of the Unix socket file (octal), e.g. 0660 to allow the group")
 	outputDir := cmd.String("output", "./received", "Output directory for received files")
-	keyfile := cmd.String("keyfile", "", "Decryption key file (required)")
+	keyfile := cmd.String("keyfile", "", "Decryption key file")
+	keyname := cmd.String("keyname", "", "Name of key in keystore")
+	var trustedSignerFiles utils.StringList
+	cmd.Var(&trustedSignerFiles, "trusted-signer", "Require files signed by this Ed25519 public key (repeatable)")
+	clientsFile := cmd.String("clients", "", "Clients file with identities and IP allowlists (enables client authentication)")
+	maxConnections := cmd.Int("max-connections", network.DefaultMaxConnections, "Maximum concurrent connections (0 = unlimited)")
+	maxTransfer := cmd.Int64("max-transfer-size", network.DefaultMaxTransferSize, "Maximum size of one transfer in bytes (0 = unlimited)")
+	readTimeout := cmd.Duration("read-timeout", network.DefaultTimeout, "Deadline for each read from a client (0 = none)")
+	writeTimeout := cmd.Duration("write-timeout", network.DefaultTimeout, "Deadline for each write to a client (0 = none)")
+	allowDownload := cmd.Bool("allow-download", false, "Allow clients to list and download received files")
+	onCollision := cmd.String("on-collision", string(network.CollisionRename), "When a received file already exists: rename, overwrite, reject")
+	stagingExpiry := cmd.Duration("staging-expiry", network.DefaultStagingExpiry, "How long interrupted transfers are kept for resuming (0 = forever)")
+	stagingQuota := utils.ByteSize(network.DefaultStagingLimits.MaxIdentity)
+	stagingTotalQuota := utils.ByteSize(network.DefaultStagingLimits.MaxTotal)
+	cmd.Var(&stagingQuota, "staging-quota", "Maximum bytes of unfinished transfers per client, e.g. 4G (0 = unlimited)")
+	cmd.Var(&stagingTotalQuota, "staging-total-quota", "Maximum bytes of unfinished transfers for all clients together (0 = unlimited)")
+	stagingTransfers := cmd.Int("staging-transfers", network.DefaultStagingLimits.MaxTransfers, "Maximum unfinished transfers per client (0 = unlimited)")
+	progress := cmd.Bool("progress", false, "Log progress of each transfer")
+	inbox := cmd.Bool("inbox", false, "Verify received files but store them encrypted as <name>.enc (decrypt later with decrypt-file)")
+	storageKeyfile := cmd.String("storage-keyfile", "", "With --inbox, re-wrap stored files under this key instead of the transfer key")
+	storageKeyname := cmd.String("storage-keyname", "", "Name of storage key in keystore")
+	onReceive := cmd.String("on-receive", "", "Shell command run after each received file, with CRYPTO_FILE, CRYPTO_PATH, CRYPTO_SIZE, CRYPTO_SENDER, CRYPTO_HASH... in its environment")
+	spoolDir := cmd.String("spool-dir", "", "Write a JSON event for each received file into this directory")
+	hookTimeout := cmd.Duration("hook-timeout", network.DefaultHookTimeout, "Kill the --on-receive command after this long (0 = no limit)")
+	var rateLimit, totalRateLimit utils.ByteSize
+	cmd.Var(&rateLimit, "rate-limit", "Bandwidth limit per connection in bytes/s, e.g. 512K or 10M (0 = unlimited)")
+	cmd.Var(&totalRateLimit, "total-rate-limit", "Bandwidth limit for all connections together in bytes/s (0 = unlimited)")
+	connRate := cmd.Int("conn-rate", 0, "Maximum new connections per minute from one IP address (0 = unlimited)")
+	shutdownTimeout := cmd.Duration("shutdown-timeout", 30*time.Second, "On Ctrl+C, wait this long for transfers in progress (second Ctrl+C stops at once)")
 
 	cmd.Parse(args)
 
-	if *keyfile == "" {
+	if *keyfile == "" && *keyname == "" {
 		logger.Error("TCP_SERVER", "Keyfile not specified", nil)
-		log.Fatal("--keyfile is required")
+		log.Fatal("--keyfile or --keyname is required")
 	}
 
-	keyBytes, err := os.ReadFile(*keyfile)
+	mode, err := strconv.ParseUint(*socketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		log.Fatalf("Invalid --socket-mode %q: expected octal permissions such as 0600", *socketMode)
+	}
+
+	collisionPolicy, err := network.ParseCollisionPolicy(*onCollision)
+	if err != nil {
+		logger.Error("TCP_SERVER", "Invalid collision policy", map[string]interface{}{
+			"on_collision": *onCollision,
+		})
+		log.Fatal(err)
+	}
+
+	keyBytes, err := utils.LoadKeyFile(*keyfile, *keyname)
 	if err != nil {
-		logger.Error("TCP_SERVER", "Failed to read key file", map[string]interface{}{
+		logger.Error("TCP_SERVER", "Failed to load key", map[string]interface{}{
 			"keyfile": *keyfile,
+			"keyname": *keyname,
 			"error":   err.Error(),
 		})
-		log.Fatal("Failed to read key file:", err)
+		log.Fatal("Failed to load key:", err)
+	}
+
+	var storageKey []byte
+	if *storageKeyfile != "" || *storageKeyname != "" {
+		if !*inbox {
+			log.Fatal("--storage-keyfile and --storage-keyname require --inbox")
+		}
+		storageKey, err = utils.LoadKeyFile(*storageKeyfile, *storageKeyname)
+		if err != nil {
+			logger.Error("TCP_SERVER", "Failed to load storage key", map[string]interface{}{
+				"storage_keyfile": *storageKeyfile,
+				"storage_keyname": *storageKeyname,
+				"error":           err.Error(),
+			})
+			log.Fatal("Failed to load storage key:", err)
+		}
+	}
+
+	trustedSigners, err := keypair.LoadEd25519PublicKeys(trustedSignerFiles)
+	if err != nil {
+		logger.Error("TCP_SERVER", "Failed to load trusted signer", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("Failed to load trusted signer:", err)
 	}
-	keyBytes = bytes.TrimSpace(keyBytes)
 
 	server := network.NewTCPServer(*address, *outputDir, keyBytes)
+	server.SetTrustedSigners(trustedSigners)
+	server.SetLimits(*maxConnections, *maxTransfer)
+	server.SetTimeouts(*readTimeout, *writeTimeout)
+	server.SetCollisionPolicy(collisionPolicy)
+	server.SetStagingExpiry(*stagingExpiry)
+	server.SetStagingLimits(network.StagingLimits{
+		MaxIdentity:  int64(stagingQuota),
+		MaxTotal:     int64(stagingTotalQuota),
+		MaxTransfers: *stagingTransfers,
+	})
+	server.SetDownloads(*allowDownload)
+	server.SetInbox(*inbox, storageKey)
+	server.SetSocketMode(fs.FileMode(mode))
+	server.SetReceiveHook(network.ReceiveHook{
+		Command:  *onReceive,
+		SpoolDir: *spoolDir,
+		Timeout:  *hookTimeout,
+	})
+	server.SetBandwidth(int64(rateLimit), int64(totalRateLimit))
+	server.SetConnectionRate(*connRate)
+	if *progress {
+		server.SetProgress(newProgressLogger().Update)
+	}
+
+	if *clientsFile != "" {
+		registry, err := network.LoadClients(*clientsFile)
+		if err != nil {
+			logger.Error("TCP_SERVER", "Failed to load clients file", map[string]interface{}{
+				"clients_file": *clientsFile,
+				"error":        err.Error(),
+			})
+			log.Fatal("Failed to load clients file:", err)
+		}
+		server.SetClients(registry)
+	}
 
 	logger.LogNetwork(logger.SERVER_START, *address,
 		"TCP Server started via CLI", true, map[string]interface{}{
 			"output_dir": *outputDir,
 			"keyfile":    *keyfile,
 			"key_size":   len(keyBytes) * 8,
+			"inbox":      *inbox,
 		})
 
 	fmt.Printf("   Starting TCP Server\n")
 	fmt.Printf("   Address: %s\n", *address)
 	fmt.Printf("   Output:  %s\n", *outputDir)
-	fmt.Printf("   Key:     %s (%d bits)\n", *keyfile, len(keyBytes)*8)
+	fmt.Printf("   Key:     %s (%d bits)\n", utils.KeySource(*keyfile, *keyname), len(keyBytes)*8)
+	fmt.Printf("   Key fingerprint: %s\n", core.KeyFingerprint(keyBytes))
+	if *inbox {
+		if storageKey != nil {
+			fmt.Printf("   Inbox:   files stored encrypted under %s (fingerprint %s)\n",
+				utils.KeySource(*storageKeyfile, *storageKeyname), core.KeyFingerprint(storageKey))
+		} else {
+			fmt.Printf("   Inbox:   files stored encrypted under the transfer key\n")
+		}
+	}
+	if len(trustedSigners) > 0 {
+		fmt.Printf("   Signatures: required (%d trusted signers)\n", len(trustedSigners))
+	}
+	if *onReceive != "" {
+		fmt.Printf("   On receive: %s (timeout %s)\n", *onReceive, *hookTimeout)
+	}
+	if *spoolDir != "" {
+		fmt.Printf("   Spool:   %s\n", *spoolDir)
+	}
+	if *clientsFile != "" {
+		fmt.Printf("   Client auth: required (%s)\n", *clientsFile)
+	}
+	if rateLimit > 0 || totalRateLimit > 0 {
+		fmt.Printf("   Bandwidth: %s per connection, %s total\n", formatLimit(int64(rateLimit)), formatLimit(int64(totalRateLimit)))
+	}
 	fmt.Printf("   Logs:    logs/crypto-app.log\n")
 	fmt.Printf("   Press Ctrl+C to stop\n")
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	runServer(server, *address, *shutdownTimeout)
+}
 
+// runServer pokreće server i na Ctrl+C ga gasi, čekajući prenose u toku
+// najviše shutdownTimeout.
+func runServer(server *network.TCPServer, address string, shutdownTimeout time.Duration) {
+	if err := server.Start(); err != nil {
+		logger.Error("TCP_SERVER", "Failed to start server", map[string]interface{}{
+			"address": address,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to start server:", err)
+	}
+
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+	fmt.Printf("\nShutting down server (waiting up to %s for transfers, Ctrl+C again to stop now)...\n", shutdownTimeout)
+
+	logger.LogNetwork(logger.SERVER_STOP, address,
+		"TCP Server stopped by user", true, map[string]interface{}{
+			"shutdown_timeout": shutdownTimeout.String(),
+		})
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 	go func() {
-		if err := server.Start(); err != nil {
-			logger.Error("TCP_SERVER", "Failed to start server", map[string]interface{}{
-				"address": *address,
-				"error":   err.Error(),
+		<-c
+		cancel()
+	}()
+
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Printf("Transfers still in progress were interrupted (%v); clients can resume them\n", err)
+	}
+}
+
+// clientFlags su opcije zajedničke za slanje, listanje i preuzimanje.
+type clientFlags struct {
+	address      *string
+	keyfile      *string
+	keyname      *string
+	algorithm    *string
+	clientID     *string
+	clientSecret *string
+	progress     *bool
+	rateLimit    utils.ByteSize
+	relayKey     *string
+}
+
+func addClientFlags(cmd *flag.FlagSet) *clientFlags {
+	f := &clientFlags{
+		address:      cmd.String("address", "localhost:8080", "Server address (host:port or unix:///path/to.sock)"),
+		keyfile:      cmd.String("keyfile", "", "Encryption key file"),
+		keyname:      cmd.String("keyname", "", "Name of key in keystore"),
+		algorithm:    cmd.String("algo", "LEA-PCBC", "Algorithm: LEA, LEA-PCBC"),
+		clientID:     cmd.String("client-id", "", "Client identity for servers that require authentication"),
+		clientSecret: cmd.String("client-secret", "", "File with client secret (hex)"),
+		progress:     cmd.Bool("progress", isTerminal(os.Stderr), "Show a progress bar (default when stderr is a terminal)"),
+		relayKey:     cmd.String("relay-key", "", "Relay transport key file, when the address is a relay"),
+	}
+	cmd.Var(&f.rateLimit, "rate-limit", "Bandwidth limit in bytes/s, e.g. 512K or 10M (0 = unlimited)")
+	return f
+}
+
+func (f *clientFlags) loadKey() []byte {
+	keyBytes, err := utils.LoadKeyFile(*f.keyfile, *f.keyname)
+	if err != nil {
+		logger.Error("TCP_CLIENT", "Failed to load key", map[string]interface{}{
+			"keyfile": *f.keyfile,
+			"keyname": *f.keyname,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to load key:", err)
+	}
+	return keyBytes
+}
+
+func (f *clientFlags) newClient() *network.TCPClient {
+	client := network.NewTCPClient(*f.address, 10*time.Second)
+	client.SetBandwidth(int64(f.rateLimit), 0)
+
+	if *f.clientID != "" {
+		secret, err := utils.LoadKey("", *f.clientSecret, "")
+		if err != nil {
+			logger.Error("TCP_CLIENT", "Failed to load client secret", map[string]interface{}{
+				"client_secret": *f.clientSecret,
+				"error":         err.Error(),
 			})
-			log.Fatal("Failed to start server:", err)
+			log.Fatal("Failed to load client secret:", err)
 		}
-	}()
+		client.SetCredentials(*f.clientID, secret)
+	}
 
-	<-c
-	fmt.Println("\nShutting down server...")
+	if *f.relayKey != "" {
+		transportKey, err := utils.LoadKeyFile(*f.relayKey, "")
+		if err != nil {
+			logger.Error("TCP_CLIENT", "Failed to load relay key", map[string]interface{}{
+				"relay_key": *f.relayKey,
+				"error":     err.Error(),
+			})
+			log.Fatal("Failed to load relay key:", err)
+		}
+		client.SetTransportKey(transportKey)
+	}
 
-	logger.LogNetwork(logger.SERVER_STOP, *address,
-		"TCP Server stopped by user", true, nil)
+	return client
+}
 
-	server.Stop()
-	time.Sleep(1 * time.Second)
+// showProgress crta traku napretka za prenose klijenta; pozivalac zatvara traku.
+func (f *clientFlags) showProgress(client *network.TCPClient) *progressBar {
+	bar := newProgressBar(*f.progress)
+	if bar != nil {
+		client.SetProgress(bar.Update)
+	}
+	return bar
+}
+
+func (f *clientFlags) connect(client *network.TCPClient) {
+	fmt.Printf("Connecting to server: %s\n", *f.address)
+	if err := client.Connect(); err != nil {
+		logger.Error("TCP_CLIENT", "Failed to connect to server", map[string]interface{}{
+			"address": *f.address,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to connect:", err)
+	}
 }
 
 func HandleTCPClient(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			handleClientList(args[1:])
+			return
+		case "get":
+			handleClientGet(args[1:])
+			return
+		case "pull":
+			handleClientPull(args[1:])
+			return
+		}
+	}
+
 	cmd := flag.NewFlagSet("client", flag.ExitOnError)
-	address := cmd.String("address", "localhost:8080", "Server address (host:port)")
-	file := cmd.String("file", "", "File to send (required)")
-	keyfile := cmd.String("keyfile", "", "Encryption key file (required)")
-	algorithm := cmd.String("algo", "LEA-PCBC", "Algorithm: LEA, LEA-PCBC")
+	opts := addClientFlags(cmd)
+	file := cmd.String("file", "", "File to send")
+	files := cmd.String("files", "", "Comma-separated files to send in one session")
+	dir := cmd.String("dir", "", "Directory tree to send in one session (relative paths are kept)")
+	signKey := cmd.String("sign-key", "", "Ed25519 private key to sign the file with (optional)")
+	recipient := cmd.String("to", "", "Recipient identity, when sending through a relay (needs --relay-key)")
+	retries := cmd.Int("retries", network.DefaultRetries, "Reconnect and resume this many times after the connection drops")
+	retryDelay := cmd.Duration("retry-delay", network.DefaultRetryDelay, "Wait between reconnect attempts")
 
 	cmd.Parse(args)
 
-	if *file == "" || *keyfile == "" {
+	address, keyfile, keyname, algorithm := opts.address, opts.keyfile, opts.keyname, opts.algorithm
+
+	if (*file == "" && *files == "" && *dir == "") || (*keyfile == "" && *keyname == "") {
 		logger.Error("TCP_CLIENT", "Missing required arguments", nil)
-		log.Fatal("Both --file and --keyfile are required")
+		log.Fatal("--file (or --files, --dir) and --keyfile (or --keyname) are required")
 	}
 
-	keyBytes, err := os.ReadFile(*keyfile)
-	if err != nil {
-		logger.Error("TCP_CLIENT", "Failed to read key file", map[string]interface{}{
-			"keyfile": *keyfile,
-			"error":   err.Error(),
-		})
-		log.Fatal("Failed to read key file:", err)
-	}
-	keyBytes = bytes.TrimSpace(keyBytes)
+	keyBytes := opts.loadKey()
 
-	fileInfo, err := os.Stat(*file)
+	entries, err := clientFileEntries(*file, *files, *dir)
 	if err != nil {
-		logger.Error("TCP_CLIENT", "Failed to get file info", map[string]interface{}{
+		logger.Error("TCP_CLIENT", "Failed to collect files", map[string]interface{}{
 			"file":  *file,
+			"files": *files,
+			"dir":   *dir,
 			"error": err.Error(),
 		})
-		log.Fatal("Failed to get file info:", err)
+		log.Fatal("Failed to collect files:", err)
 	}
 
 	logger.LogNetwork(logger.CLIENT_CONNECT, *address,
 		"TCP Client started via CLI", true, map[string]interface{}{
-			"file":      *file,
-			"algorithm": *algorithm,
-			"keyfile":   *keyfile,
-			"file_size": fileInfo.Size(),
-			"key_size":  len(keyBytes) * 8,
+			"file":       *file,
+			"dir":        *dir,
+			"file_count": len(entries),
+			"algorithm":  *algorithm,
+			"keyfile":    *keyfile,
+			"key_size":   len(keyBytes) * 8,
 		})
 
-	client := network.NewTCPClient(*address, 10*time.Second)
+	client := opts.newClient()
+	client.SetRetry(*retries, *retryDelay)
+	if *recipient != "" {
+		client.SetRecipient(*recipient)
+	}
 
-	fmt.Printf("Connecting to server: %s\n", *address)
-	if err := client.Connect(); err != nil {
-		logger.Error("TCP_CLIENT", "Failed to connect to server", map[string]interface{}{
-			"address": *address,
-			"error":   err.Error(),
-		})
-		log.Fatal("Failed to connect:", err)
+	if *signKey != "" {
+		signingKey, err := keypair.LoadEd25519PrivateKey(*signKey)
+		if err != nil {
+			logger.Error("TCP_CLIENT", "Failed to load signing key", map[string]interface{}{
+				"sign_key": *signKey,
+				"error":    err.Error(),
+			})
+			log.Fatal("Failed to load signing key:", err)
+		}
+		client.SetSigningKey(signingKey)
 	}
+
+	opts.connect(client)
 	defer client.Disconnect()
+	bar := opts.showProgress(client)
+	defer bar.Close()
 
-	fmt.Printf("Sending file: %s (%d bytes)\n", *file, fileInfo.Size())
 	fmt.Printf("Algorithm: %s\n", *algorithm)
-	fmt.Printf("Key: %s (%d bits)\n", *keyfile, len(keyBytes)*8)
+	fmt.Printf("Key: %s (%d bits)\n", utils.KeySource(*keyfile, *keyname), len(keyBytes)*8)
+
+	if *files == "" && *dir == "" {
+		sendSingleFile(client, *file, *address, *algorithm, keyBytes)
+		return
+	}
+
+	fmt.Printf("Sending %d files\n", len(entries))
 
-	if err := client.SendFile(*file, *algorithm, keyBytes); err != nil {
+	results, err := client.SendFiles(entries, *algorithm, keyBytes)
+
+	var sent, failed int
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("   ✗ %s: %v\n", result.Name, result.Err)
+			failed++
+			continue
+		}
+		fmt.Printf("   ✓ %s\n", result.Name)
+		sent++
+	}
+
+	logger.Info("TCP_CLIENT", "Files sent", failed == 0 && err == nil, map[string]interface{}{
+		"address":   *address,
+		"algorithm": *algorithm,
+		"sent":      sent,
+		"failed":    failed,
+	})
+
+	fmt.Printf("Sent %d files, %d failed\n", sent, failed)
+
+	if err != nil {
+		log.Fatal("Session aborted:", err)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func sendSingleFile(client *network.TCPClient, file, address, algorithm string, keyBytes []byte) {
+	fileInfo, err := os.Stat(file)
+	if err != nil {
+		logger.Error("TCP_CLIENT", "Failed to get file info", map[string]interface{}{
+			"file":  file,
+			"error": err.Error(),
+		})
+		log.Fatal("Failed to get file info:", err)
+	}
+
+	fmt.Printf("Sending file: %s (%d bytes)\n", file, fileInfo.Size())
+
+	if err := client.SendFile(file, algorithm, keyBytes); err != nil {
 		logger.Error("TCP_CLIENT", "Failed to send file", map[string]interface{}{
-			"file":      *file,
-			"address":   *address,
-			"algorithm": *algorithm,
+			"file":      file,
+			"address":   address,
+			"algorithm": algorithm,
 			"error":     err.Error(),
 		})
 		log.Fatal("Failed to send file:", err)
 	}
 
 	logger.Info("TCP_CLIENT", "File sent successfully", true, map[string]interface{}{
-		"file":      *file,
-		"address":   *address,
-		"algorithm": *algorithm,
+		"file":      file,
+		"address":   address,
+		"algorithm": algorithm,
 		"file_size": fileInfo.Size(),
 	})
 
 	fmt.Println("File sent successfully!")
 }
+
+// clientFileEntries spaja --file, --files i --dir u listu fajlova za slanje.
+func clientFileEntries(file, files, dir string) ([]network.FileEntry, error) {
+	var entries []network.FileEntry
+
+	paths := strings.Split(files, ",")
+	if file != "" {
+		paths = append([]string{file}, paths...)
+	}
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a regular file", path)
+		}
+		entries = append(entries, network.FileEntry{Path: path, Name: filepath.Base(path)})
+	}
+
+	if dir != "" {
+		dirEntries, err := network.CollectFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, dirEntries...)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no files to send")
+	}
+	return entries, nil
+}
+
+func handleClientList(args []string) {
+	cmd := flag.NewFlagSet("client list", flag.ExitOnError)
+	opts := addClientFlags(cmd)
+
+	cmd.Parse(args)
+
+	if *opts.keyfile == "" && *opts.keyname == "" {
+		logger.Error("TCP_CLIENT", "Missing required arguments", nil)
+		log.Fatal("--keyfile (or --keyname) is required")
+	}
+
+	keyBytes := opts.loadKey()
+	client := opts.newClient()
+	opts.connect(client)
+	defer client.Disconnect()
+
+	files, err := client.ListFiles(*opts.algorithm, keyBytes)
+	if err != nil {
+		logger.Error("TCP_CLIENT", "Failed to list files", map[string]interface{}{
+			"address": *opts.address,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to list files:", err)
+	}
+
+	if len(files) == 0 {
+		fmt.Println("No files on server")
+		return
+	}
+
+	fmt.Printf("%-12s %-20s %s\n", "SIZE", "MODIFIED", "NAME")
+	for _, file := range files {
+		fmt.Printf("%-12d %-20s %s\n", file.Size, file.Modified.Format("2006-01-02 15:04:05"), file.Name)
+	}
+	fmt.Printf("%d files\n", len(files))
+}
+
+func handleClientGet(args []string) {
+	cmd := flag.NewFlagSet("client get", flag.ExitOnError)
+	opts := addClientFlags(cmd)
+	name := cmd.String("name", "", "File on the server, as shown by 'client list' (required)")
+	output := cmd.String("output", "", "Output file (default: file name in current directory)")
+
+	cmd.Parse(args)
+
+	if *name == "" || (*opts.keyfile == "" && *opts.keyname == "") {
+		logger.Error("TCP_CLIENT", "Missing required arguments", nil)
+		log.Fatal("--name and --keyfile (or --keyname) are required")
+	}
+
+	outputPath := *output
+	if outputPath == "" {
+		outputPath = filepath.Base(filepath.FromSlash(*name))
+	}
+	if _, err := os.Stat(outputPath); err == nil {
+		log.Fatalf("Output file %s already exists", outputPath)
+	}
+
+	keyBytes := opts.loadKey()
+	client := opts.newClient()
+	opts.connect(client)
+	defer client.Disconnect()
+	bar := opts.showProgress(client)
+	defer bar.Close()
+
+	if err := client.GetFile(*name, outputPath, *opts.algorithm, keyBytes); err != nil {
+		logger.Error("TCP_CLIENT", "Failed to download file", map[string]interface{}{
+			"address": *opts.address,
+			"name":    *name,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to download file:", err)
+	}
+
+	logger.Info("TCP_CLIENT", "File downloaded", true, map[string]interface{}{
+		"address": *opts.address,
+		"name":    *name,
+		"output":  outputPath,
+	})
+
+	fmt.Printf("✓ Downloaded %s to %s\n", *name, outputPath)
+}
@@ -36,6 +36,7 @@ func handlePCBCEncrypt(args []string) {
 	file := cmd.String("file", "", "File to encrypt (required)")
 	key := cmd.String("key", "", "Encryption key (hex string)")
 	keyFile := cmd.String("keyfile", "", "File containing encryption key")
+	keyName := cmd.String("keyname", "", "Name of encryption key in keystore")
 	output := cmd.String("output", "", "Output file (required)")
 
 	cmd.Parse(args)
@@ -45,7 +46,7 @@ func handlePCBCEncrypt(args []string) {
 		log.Fatal("Both --file and --output are required")
 	}
 
-	keyBytes, err := utils.LoadKey(*key, *keyFile)
+	keyBytes, err := utils.LoadKey(*key, *keyFile, *keyName)
 	if err != nil {
 		logger.Error("PCBC_ENCRYPT", "Failed to load key", map[string]interface{}{
 			"error": err.Error(),
@@ -121,6 +122,7 @@ func handlePCBCDecrypt(args []string) {
 	file := cmd.String("file", "", "File to decrypt (required)")
 	key := cmd.String("key", "", "Decryption key (hex string)")
 	keyFile := cmd.String("keyfile", "", "File containing decryption key")
+	keyName := cmd.String("keyname", "", "Name of decryption key in keystore")
 	output := cmd.String("output", "", "Output file (required)")
 
 	cmd.Parse(args)
@@ -130,7 +132,7 @@ func handlePCBCDecrypt(args []string) {
 		log.Fatal("Both --file and --output are required")
 	}
 
-	keyBytes, err := utils.LoadKey(*key, *keyFile)
+	keyBytes, err := utils.LoadKey(*key, *keyFile, *keyName)
 	if err != nil {
 		logger.Error("PCBC_DECRYPT", "Failed to load key", map[string]interface{}{
 			"error": err.Error(),
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/network"
+)
+
+const progressBarWidth = 30
+
+// progressBar crta napredak prenosa u jednoj liniji na stderr. Istovremeno
+// preuzima izlaz paketa log, da poruke ne bi prekinule liniju sa trakom.
+type progressBar struct {
+	mu   sync.Mutex
+	out  io.Writer
+	line string
+}
+
+// newProgressBar vraća nil kada stderr nije terminal; tada se napredak ne crta.
+func newProgressBar(enabled bool) *progressBar {
+	if !enabled {
+		return nil
+	}
+	bar := &progressBar{out: os.Stderr}
+	log.SetOutput(bar)
+	return bar
+}
+
+// Update je network.ProgressFunc.
+func (b *progressBar) Update(p network.Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if p.Finished {
+		b.clear()
+		if p.Err == nil {
+			fmt.Fprintf(b.out, "%s %s done, %s\n", progressLabel(p), formatBytes(p.Total), formatRate(p.Rate))
+		}
+		b.line = ""
+		return
+	}
+
+	filled := int(p.Percent() / 100 * progressBarWidth)
+	filled = min(max(filled, 0), progressBarWidth)
+	b.line = fmt.Sprintf("%s [%s%s] %5.1f%% %s/%s %s ETA %s",
+		progressLabel(p),
+		strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+		p.Percent(), formatBytes(p.Done), formatBytes(p.Total), formatRate(p.Rate), formatETA(p.ETA))
+	b.clear()
+	fmt.Fprint(b.out, b.line)
+}
+
+// Write briše traku, ispisuje poruku i ponovo crta traku ispod nje.
+func (b *progressBar) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clear()
+	n, err := b.out.Write(p)
+	if b.line != "" {
+		fmt.Fprint(b.out, b.line)
+	}
+	return n, err
+}
+
+// Close vraća izlaz paketa log na stderr.
+func (b *progressBar) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.line != "" {
+		fmt.Fprintln(b.out)
+	}
+	log.SetOutput(os.Stderr)
+}
+
+func (b *progressBar) clear() {
+	if b.line != "" {
+		fmt.Fprint(b.out, "\r\033[K")
+	}
+}
+
+// progressLogger ispisuje napredak prenosa na serveru kao log poruke, na
+// svakih 25% i po završetku, jer više prenosa može da teče istovremeno.
+type progressLogger struct {
+	mu    sync.Mutex
+	steps map[string]int
+}
+
+func newProgressLogger() *progressLogger {
+	return &progressLogger{steps: make(map[string]int)}
+}
+
+// Update je network.ProgressFunc.
+func (l *progressLogger) Update(p network.Progress) {
+	key := p.Remote + "|" + p.Direction + "|" + p.Name
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if p.Finished {
+		delete(l.steps, key)
+		if p.Err != nil {
+			log.Printf("%s %s: failed at %s/%s", progressLabel(p), p.Remote, formatBytes(p.Done), formatBytes(p.Total))
+		}
+		return
+	}
+
+	step := int(p.Percent() / 25)
+	if last, ok := l.steps[key]; ok && step <= last {
+		return
+	}
+	l.steps[key] = step
+	log.Printf("%s %s: %3.0f%% %s/%s %s ETA %s", progressLabel(p), p.Remote,
+		p.Percent(), formatBytes(p.Done), formatBytes(p.Total), formatRate(p.Rate), formatETA(p.ETA))
+}
+
+func progressLabel(p network.Progress) string {
+	return progressArrow(p.Direction) + " " + p.Name
+}
+
+func progressArrow(direction string) string {
+	if direction == network.DirectionReceive {
+		return "↓"
+	}
+	return "↑"
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatRate(rate float64) string {
+	return formatBytes(int64(rate)) + "/s"
+}
+
+func formatLimit(rate int64) string {
+	if rate <= 0 {
+		return "unlimited"
+	}
+	return formatRate(float64(rate))
+}
+
+func formatETA(eta time.Duration) string {
+	if eta <= 0 {
+		return "--:--"
+	}
+	eta = eta.Round(time.Second)
+	if eta >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(eta.Hours()), int(eta.Minutes())%60, int(eta.Seconds())%60)
+	}
+	return fmt.Sprintf("%02d:%02d", int(eta.Minutes()), int(eta.Seconds())%60)
+}
+
+// isTerminal proverava da li je f terminal, da se traka ne bi upisivala u
+// preusmeren izlaz.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
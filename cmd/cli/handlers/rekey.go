@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AleksaS003/zastitaprojekat/cmd/cli/utils"
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+func HandleRekey(args []string) {
+	cmd := flag.NewFlagSet("rekey", flag.ExitOnError)
+	oldKeyfile := cmd.String("old-keyfile", "", "Current key file")
+	oldKeyname := cmd.String("old-keyname", "", "Name of current key in keystore")
+	newKeyfile := cmd.String("new-keyfile", "", "New key file")
+	newKeyname := cmd.String("new-keyname", "", "Name of new key in keystore")
+	dir := cmd.String("dir", "", "Directory with .enc files (required)")
+	algorithm := cmd.String("algo", "", "New algorithm: LEA, LEA-PCBC (default: keep current)")
+
+	cmd.Parse(args)
+
+	if (*oldKeyfile == "" && *oldKeyname == "") || (*newKeyfile == "" && *newKeyname == "") || *dir == "" {
+		logger.Error(logger.REKEY, "Missing required arguments", nil)
+		log.Fatal("--old-keyfile (or --old-keyname), --new-keyfile (or --new-keyname) and --dir are required")
+	}
+
+	oldKey, err := utils.LoadKeyFile(*oldKeyfile, *oldKeyname)
+	if err != nil {
+		logger.Error(logger.REKEY, "Failed to load old key", map[string]interface{}{
+			"keyfile": *oldKeyfile,
+			"keyname": *oldKeyname,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to load old key:", err)
+	}
+
+	newKey, err := utils.LoadKeyFile(*newKeyfile, *newKeyname)
+	if err != nil {
+		logger.Error(logger.REKEY, "Failed to load new key", map[string]interface{}{
+			"keyfile": *newKeyfile,
+			"keyname": *newKeyname,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to load new key:", err)
+	}
+
+	entries, err := os.ReadDir(*dir)
+	if err != nil {
+		logger.Error(logger.REKEY, "Failed to read directory", map[string]interface{}{
+			"directory": *dir,
+			"error":     err.Error(),
+		})
+		log.Fatal("Failed to read directory:", err)
+	}
+
+	logger.Info(logger.REKEY, "Starting directory re-key", true, map[string]interface{}{
+		"directory":    *dir,
+		"algorithm":    *algorithm,
+		"old_keyfile":  *oldKeyfile,
+		"new_keyfile":  *newKeyfile,
+		"old_key_size": len(oldKey) * 8,
+		"new_key_size": len(newKey) * 8,
+	})
+
+	processor := core.NewFileProcessor()
+
+	var rekeyed, failed []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".enc") {
+			continue
+		}
+
+		path := filepath.Join(*dir, entry.Name())
+		if err := processor.RekeyFile(path, oldKey, newKey, *algorithm); err != nil {
+			fmt.Printf("   ✗ %s: %v\n", entry.Name(), err)
+			failed = append(failed, path)
+			continue
+		}
+
+		fmt.Printf("   ✓ %s\n", entry.Name())
+		rekeyed = append(rekeyed, path)
+	}
+
+	logger.Info(logger.REKEY, "Directory re-key completed", len(failed) == 0, map[string]interface{}{
+		"directory": *dir,
+		"rekeyed":   len(rekeyed),
+		"failed":    len(failed),
+	})
+
+	fmt.Printf("Re-keyed %d files, %d failed\n", len(rekeyed), len(failed))
+
+	if len(failed) > 0 {
+		os.Exit(1)
+	}
+}
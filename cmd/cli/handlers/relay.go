@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/cmd/cli/utils"
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+	"github.com/AleksaS003/zastitaprojekat/internal/keypair"
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+	"github.com/AleksaS003/zastitaprojekat/internal/network"
+)
+
+func HandleRelay(args []string) {
+	cmd := flag.NewFlagSet("relay", flag.ExitOnError)
+	address := cmd.String("address", ":9000", "Relay address (host:port)")
+	storageDir := cmd.String("storage", "./relay", "Directory for files waiting for their recipients")
+	keyfile := cmd.String("keyfile", "", "Relay transport key file (shared with clients as --relay-key, not the file key)")
+	keyname := cmd.String("keyname", "", "Name of relay transport key in keystore")
+	clientsFile := cmd.String("clients", "", "Clients file with sender and recipient identities (required)")
+	expiry := cmd.Duration("expiry", network.DefaultRelayExpiry, "Delete files not pulled within this time (0 = keep forever)")
+	var mailboxQuota, totalQuota, rateLimit, totalRateLimit utils.ByteSize
+	cmd.Var(&mailboxQuota, "mailbox-quota", "Maximum bytes waiting for one recipient, e.g. 500M (0 = unlimited)")
+	cmd.Var(&totalQuota, "total-quota", "Maximum bytes stored on the relay, e.g. 10G (0 = unlimited)")
+	var trustedSignerFiles utils.StringList
+	cmd.Var(&trustedSignerFiles, "trusted-signer", "Only store files signed by this Ed25519 public key (repeatable)")
+	maxConnections := cmd.Int("max-connections", network.DefaultMaxConnections, "Maximum concurrent connections (0 = unlimited)")
+	maxTransfer := cmd.Int64("max-transfer-size", network.DefaultMaxTransferSize, "Maximum size of one file in bytes (0 = unlimited)")
+	readTimeout := cmd.Duration("read-timeout", network.DefaultTimeout, "Deadline for each read from a client (0 = none)")
+	writeTimeout := cmd.Duration("write-timeout", network.DefaultTimeout, "Deadline for each write to a client (0 = none)")
+	cmd.Var(&rateLimit, "rate-limit", "Bandwidth limit per connection in bytes/s (0 = unlimited)")
+	cmd.Var(&totalRateLimit, "total-rate-limit", "Bandwidth limit for all connections together in bytes/s (0 = unlimited)")
+	connRate := cmd.Int("conn-rate", 0, "Maximum new connections per minute from one IP address (0 = unlimited)")
+	progress := cmd.Bool("progress", false, "Log progress of each transfer")
+	shutdownTimeout := cmd.Duration("shutdown-timeout", 30*time.Second, "On Ctrl+C, wait this long for transfers in progress (second Ctrl+C stops at once)")
+
+	cmd.Parse(args)
+
+	if (*keyfile == "" && *keyname == "") || *clientsFile == "" {
+		logger.Error("RELAY", "Missing required arguments", nil)
+		log.Fatal("--keyfile (or --keyname) and --clients are required")
+	}
+
+	keyBytes, err := utils.LoadKeyFile(*keyfile, *keyname)
+	if err != nil {
+		logger.Error("RELAY", "Failed to load key", map[string]interface{}{
+			"keyfile": *keyfile,
+			"keyname": *keyname,
+			"error":   err.Error(),
+		})
+		log.Fatal("Failed to load key:", err)
+	}
+
+	registry, err := network.LoadClients(*clientsFile)
+	if err != nil {
+		logger.Error("RELAY", "Failed to load clients file", map[string]interface{}{
+			"clients_file": *clientsFile,
+			"error":        err.Error(),
+		})
+		log.Fatal("Failed to load clients file:", err)
+	}
+
+	trustedSigners, err := keypair.LoadEd25519PublicKeys(trustedSignerFiles)
+	if err != nil {
+		logger.Error("RELAY", "Failed to load trusted signer", map[string]interface{}{
+			"error": err.Error(),
+		})
+		log.Fatal("Failed to load trusted signer:", err)
+	}
+
+	relay := network.NewRelayServer(*address, *storageDir, keyBytes, network.RelayLimits{
+		Expiry:     *expiry,
+		MaxMailbox: int64(mailboxQuota),
+		MaxTotal:   int64(totalQuota),
+	})
+	relay.SetClients(registry)
+	relay.SetTrustedSigners(trustedSigners)
+	relay.SetLimits(*maxConnections, *maxTransfer)
+	relay.SetTimeouts(*readTimeout, *writeTimeout)
+	relay.SetBandwidth(int64(rateLimit), int64(totalRateLimit))
+	relay.SetConnectionRate(*connRate)
+	if *progress {
+		relay.SetProgress(newProgressLogger().Update)
+	}
+
+	logger.LogNetwork(logger.SERVER_START, *address,
+		"Relay started via CLI", true, map[string]interface{}{
+			"storage_dir":   *storageDir,
+			"expiry":        expiry.String(),
+			"mailbox_quota": int64(mailboxQuota),
+			"total_quota":   int64(totalQuota),
+		})
+
+	fmt.Printf("   Starting relay\n")
+	fmt.Printf("   Address: %s\n", *address)
+	fmt.Printf("   Storage: %s\n", *storageDir)
+	fmt.Printf("   Transport key: %s (fingerprint %s)\n", utils.KeySource(*keyfile, *keyname), core.KeyFingerprint(keyBytes))
+	fmt.Printf("   Clients: %s\n", *clientsFile)
+	fmt.Printf("   Expiry:  %s\n", *expiry)
+	fmt.Printf("   Quotas:  %s per mailbox, %s total\n", formatQuota(int64(mailboxQuota)), formatQuota(int64(totalQuota)))
+	if len(trustedSigners) > 0 {
+		fmt.Printf("   Signatures: required (%d trusted signers)\n", len(trustedSigners))
+	}
+	fmt.Printf("   Press Ctrl+C to stop\n")
+
+	runServer(relay, *address, *shutdownTimeout)
+}
+
+func handleClientPull(args []string) {
+	cmd := flag.NewFlagSet("client pull", flag.ExitOnError)
+	opts := addClientFlags(cmd)
+	output := cmd.String("output", "./received", "Directory for pulled files")
+
+	cmd.Parse(args)
+
+	if (*opts.keyfile == "" && *opts.keyname == "") || *opts.clientID == "" {
+		logger.Error("TCP_CLIENT", "Missing required arguments", nil)
+		log.Fatal("--keyfile (or --keyname) and --client-id are required")
+	}
+
+	if err := os.MkdirAll(*output, 0755); err != nil {
+		log.Fatal("Failed to create output directory:", err)
+	}
+
+	keyBytes := opts.loadKey()
+	client := opts.newClient()
+	opts.connect(client)
+	defer client.Disconnect()
+	bar := opts.showProgress(client)
+	defer bar.Close()
+
+	results, err := client.PullFiles(*output, *opts.algorithm, keyBytes)
+
+	var pulled, failed int
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("   ✗ %s: %v\n", result.Name, result.Err)
+			failed++
+			continue
+		}
+		fmt.Printf("   ✓ %s -> %s\n", result.Name, result.Path)
+		pulled++
+	}
+
+	logger.Info("TCP_CLIENT", "Files pulled from relay", failed == 0 && err == nil, map[string]interface{}{
+		"address": *opts.address,
+		"pulled":  pulled,
+		"failed":  failed,
+	})
+
+	if err != nil && len(results) == 0 {
+		log.Fatal("Failed to pull files:", err)
+	}
+	fmt.Printf("Pulled %d files, %d failed\n", pulled, failed)
+	if err != nil {
+		log.Fatal("Session aborted:", err)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func formatQuota(quota int64) string {
+	if quota <= 0 {
+		return "unlimited"
+	}
+	return formatBytes(quota)
+}
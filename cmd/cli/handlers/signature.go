@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/AleksaS003/zastitaprojekat/cmd/cli/utils"
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+	"github.com/AleksaS003/zastitaprojekat/internal/keypair"
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+func HandleSign(args []string) {
+	cmd := flag.NewFlagSet("sign", flag.ExitOnError)
+	file := cmd.String("file", "", "File to sign (required)")
+	signKey := cmd.String("key", "", "Ed25519 private key file (required)")
+	output := cmd.String("output", "", "Signature file (default: <file>.sig)")
+
+	cmd.Parse(args)
+
+	if *file == "" || *signKey == "" {
+		logger.Error(logger.ActivityType("SIGN"), "Missing required arguments", nil)
+		log.Fatal("--file and --key are required")
+	}
+
+	privateKey, err := keypair.LoadEd25519PrivateKey(*signKey)
+	if err != nil {
+		logger.Error(logger.ActivityType("SIGN"), "Failed to load signing key", map[string]interface{}{
+			"key":   *signKey,
+			"error": err.Error(),
+		})
+		log.Fatal("Failed to load signing key:", err)
+	}
+
+	outputFile := *output
+	if outputFile == "" {
+		outputFile = *file + ".sig"
+	}
+
+	sig, err := core.SignFile(*file, privateKey)
+	if err != nil {
+		logger.Error(logger.ActivityType("SIGN"), "Failed to sign file", map[string]interface{}{
+			"file":  *file,
+			"error": err.Error(),
+		})
+		log.Fatal("Failed to sign file:", err)
+	}
+
+	if err := core.WriteDetachedSignature(outputFile, sig); err != nil {
+		log.Fatal("Failed to write signature file:", err)
+	}
+
+	logger.Info(logger.ActivityType("SIGN"), "File signed", true, map[string]interface{}{
+		"file":      *file,
+		"signature": outputFile,
+		"signer":    sig.Signer,
+		"size":      sig.Size,
+	})
+
+	fmt.Printf("✓ File signed\n")
+	fmt.Printf("  File:      %s\n", *file)
+	fmt.Printf("  Signature: %s\n", outputFile)
+	fmt.Printf("  Signer:    %s\n", sig.Signer)
+}
+
+func HandleVerifySignature(args []string) {
+	cmd := flag.NewFlagSet("verify-signature", flag.ExitOnError)
+	file := cmd.String("file", "", "Signed file (required)")
+	sigFile := cmd.String("sig", "", "Detached signature file (omit to verify signature embedded in .enc)")
+	var publicKeyFiles utils.StringList
+	cmd.Var(&publicKeyFiles, "pubkey", "Trusted Ed25519 public key file (repeatable, required)")
+
+	cmd.Parse(args)
+
+	if *file == "" || len(publicKeyFiles) == 0 {
+		logger.Error(logger.ActivityType("VERIFY_SIGNATURE"), "Missing required arguments", nil)
+		log.Fatal("--file and at least one --pubkey are required")
+	}
+
+	trusted, err := keypair.LoadEd25519PublicKeys(publicKeyFiles)
+	if err != nil {
+		log.Fatal("Failed to load public key:", err)
+	}
+
+	var signer string
+	if *sigFile != "" {
+		sig, readErr := core.ReadDetachedSignature(*sigFile)
+		if readErr != nil {
+			log.Fatal(readErr)
+		}
+		signer, err = core.VerifyFileSignature(*file, sig, trusted)
+	} else {
+		signer, err = core.VerifyContainerSignature(*file, trusted)
+	}
+
+	if err != nil {
+		logger.Error(logger.ActivityType("VERIFY_SIGNATURE"), "Signature verification failed", map[string]interface{}{
+			"file":      *file,
+			"signature": *sigFile,
+			"signer":    signer,
+			"error":     err.Error(),
+		})
+		fmt.Printf("✗ Signature INVALID: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger.Info(logger.ActivityType("VERIFY_SIGNATURE"), "Signature verified", true, map[string]interface{}{
+		"file":      *file,
+		"signature": *sigFile,
+		"signer":    signer,
+	})
+
+	fmt.Printf("✓ Signature valid\n")
+	fmt.Printf("  File:   %s\n", *file)
+	fmt.Printf("  Signer: %s\n", signer)
+}
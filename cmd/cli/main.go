@@ -47,11 +47,23 @@ func main() {
 		handlers.HandlePCBC(os.Args[2:])
 	case "sha256":
 		handlers.HandleSHA256(os.Args[2:])
+	case "key":
+		handlers.HandleKey(os.Args[2:])
+	case "keypair":
+		handlers.HandleKeypair(os.Args[2:])
 
 	case "encrypt-file":
 		handlers.HandleEncryptFile(os.Args[2:])
 	case "decrypt-file":
 		handlers.HandleDecryptFile(os.Args[2:])
+	case "rekey":
+		handlers.HandleRekey(os.Args[2:])
+	case "inspect":
+		handlers.HandleInspect(os.Args[2:])
+	case "sign":
+		handlers.HandleSign(os.Args[2:])
+	case "verify-signature":
+		handlers.HandleVerifySignature(os.Args[2:])
 
 	case "fsw":
 		handlers.HandleFSW(os.Args[2:])
@@ -60,6 +72,10 @@ func main() {
 		handlers.HandleTCPServer(os.Args[2:])
 	case "client":
 		handlers.HandleTCPClient(os.Args[2:])
+	case "clients":
+		handlers.HandleClients(os.Args[2:])
+	case "relay":
+		handlers.HandleRelay(os.Args[2:])
 
 	case "logs":
 		handlers.HandleLogs(os.Args[2:])
@@ -72,9 +88,10 @@ func main() {
 		logger.Error(logger.ActivityType("CLI_COMMAND"), "Unknown command", map[string]interface{}{
 			"command": os.Args[1],
 			"valid_commands": []string{
-				"foursquare", "lea", "pcbc", "sha256",
-				"encrypt-file", "decrypt-file", "help",
-				"fsw", "server", "client", "logs",
+				"foursquare", "lea", "pcbc", "sha256", "key", "keypair",
+				"encrypt-file", "decrypt-file", "rekey", "inspect", "help",
+				"sign", "verify-signature",
+				"fsw", "server", "client", "clients", "relay", "logs",
 			},
 		})
 		handlers.PrintHelp()
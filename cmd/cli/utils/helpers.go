@@ -1,7 +1,11 @@
 package utils
 
 import (
+	"fmt"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 )
 
 func GetWorkingDir() string {
@@ -25,3 +29,45 @@ func Min(a, b int) int {
 	}
 	return b
 }
+
+// StringList is a flag value that can be given multiple times.
+type StringList []string
+
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *StringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// ByteSize is a flag value for sizes and rates such as "512K", "10M" or
+// "1.5G" (binary units, an optional "B" or "/s" suffix is ignored).
+type ByteSize int64
+
+func (b *ByteSize) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *ByteSize) Set(value string) error {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(s, "/S")
+	s = strings.TrimSuffix(s, "IB")
+	s = strings.TrimSuffix(s, "B")
+
+	multiplier := 1.0
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			multiplier = math.Pow(1024, float64(i+1))
+			s = s[:len(s)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	*b = ByteSize(n * multiplier)
+	return nil
+}
@@ -10,11 +10,16 @@ import (
 	"github.com/AleksaS003/zastitaprojekat/internal/logger"
 )
 
-func LoadKey(keyStr, keyFile string) ([]byte, error) {
+func LoadKey(keyStr, keyFile, keyName string) ([]byte, error) {
 	var keyBytes []byte
 	var err error
 
-	if keyStr != "" {
+	if keyName != "" {
+		keyBytes, err = loadKeystoreKey(keyName)
+		if err != nil {
+			return nil, err
+		}
+	} else if keyStr != "" {
 		keyStr = strings.TrimSpace(keyStr)
 		keyBytes, err = hex.DecodeString(keyStr)
 		if err != nil {
@@ -44,14 +49,66 @@ func LoadKey(keyStr, keyFile string) ([]byte, error) {
 			"key_size_bits": len(keyBytes) * 8,
 		})
 	} else {
-		return nil, fmt.Errorf("either --key or --keyfile must be specified")
+		return nil, fmt.Errorf("one of --key, --keyfile or --keyname must be specified")
+	}
+
+	return checkKeySize(keyBytes)
+}
+
+// LoadKeyFile loads the key for commands whose --keyfile has always been
+// used as raw bytes (encrypt-file, decrypt-file, server, client, fsw and
+// the commands that must agree with them). The trimmed file content is the
+// key even when it looks like hex, unlike LoadKey, so existing key files
+// keep meaning the same key. With keyName the key comes from the keystore.
+func LoadKeyFile(keyFile, keyName string) ([]byte, error) {
+	var keyBytes []byte
+	var err error
+
+	if keyName != "" {
+		keyBytes, err = loadKeystoreKey(keyName)
+		if err != nil {
+			return nil, err
+		}
+	} else if keyFile != "" {
+		keyBytes, err = os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %v", err)
+		}
+		keyBytes = bytes.TrimSpace(keyBytes)
+
+		logger.Info("KEY_LOAD", "Loaded raw key from file", true, map[string]interface{}{
+			"key_file":      keyFile,
+			"key_size_bits": len(keyBytes) * 8,
+		})
+	} else {
+		return nil, fmt.Errorf("one of --keyfile or --keyname must be specified")
 	}
 
+	return checkKeySize(keyBytes)
+}
+
+func loadKeystoreKey(keyName string) ([]byte, error) {
+	ks, err := OpenKeystore("", false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keystore: %v", err)
+	}
+	keyBytes, err := ks.Key(keyName)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("KEY_LOAD", "Loaded key from keystore", true, map[string]interface{}{
+		"keystore":      ks.Path(),
+		"key_name":      keyName,
+		"key_size_bits": len(keyBytes) * 8,
+	})
+	return keyBytes, nil
+}
+
+func checkKeySize(keyBytes []byte) ([]byte, error) {
 	keySize := len(keyBytes) * 8
 	if keySize != 128 && keySize != 192 && keySize != 256 {
 		return nil, fmt.Errorf("key must be 128, 192, or 256 bits (got %d bits)", keySize)
 	}
-
 	return keyBytes, nil
 }
 
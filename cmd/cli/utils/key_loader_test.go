@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+// TestMain sends the activity log to a temporary directory and keeps it off
+// the console.
+func TestMain(m *testing.M) {
+	logDir, err := os.MkdirTemp("", "utils-test-logs")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	err = logger.InitGlobal(logDir)
+	os.Stdout = stdout
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+
+	code := m.Run()
+	os.RemoveAll(logDir)
+	os.Exit(code)
+}
+
+func writeKeyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.bin")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadKeyFileRaw checks that a key file made of hex characters is still
+// the raw 256-bit key it was before the keystore was added.
+func TestLoadKeyFileRaw(t *testing.T) {
+	content := "00112233445566778899aabbccddeeff"
+	key, err := LoadKeyFile(writeKeyFile(t, content+"\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, []byte(content)) {
+		t.Errorf("got %x, want the raw file content", key)
+	}
+
+	// LoadKey (lea, pcbc) keeps decoding hex key files
+	key, err = LoadKey("", writeKeyFile(t, content), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 16 {
+		t.Errorf("LoadKey returned %d bytes, want the 16 decoded bytes", len(key))
+	}
+}
+
+func TestLoadKeyFileInvalidSize(t *testing.T) {
+	if _, err := LoadKeyFile(writeKeyFile(t, "short"), ""); err == nil {
+		t.Error("LoadKeyFile accepted a 40-bit key")
+	}
+	if _, err := LoadKeyFile("", ""); err == nil {
+		t.Error("LoadKeyFile accepted no key")
+	}
+}
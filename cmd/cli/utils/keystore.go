@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/keystore"
+)
+
+const (
+	DefaultKeystorePath = "keystore.json"
+	KeystorePathEnv     = "CRYPTO_KEYSTORE"
+	KeystorePasswordEnv = "CRYPTO_KEYSTORE_PASSWORD"
+)
+
+func KeystorePath() string {
+	if path := os.Getenv(KeystorePathEnv); path != "" {
+		return path
+	}
+	return DefaultKeystorePath
+}
+
+func ReadPassword(prompt string) (string, error) {
+	if password := os.Getenv(KeystorePasswordEnv); password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password: %v", err)
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("password must not be empty")
+	}
+	return password, nil
+}
+
+var openedKeystore *keystore.Keystore
+
+func OpenKeystore(path string, create bool) (*keystore.Keystore, error) {
+	if path == "" {
+		path = KeystorePath()
+	}
+
+	if openedKeystore != nil && openedKeystore.Path() == path {
+		return openedKeystore, nil
+	}
+
+	password, err := ReadPassword(fmt.Sprintf("Keystore password (%s): ", path))
+	if err != nil {
+		return nil, err
+	}
+
+	var ks *keystore.Keystore
+	if create {
+		ks, err = keystore.OpenOrCreate(path, password)
+	} else {
+		ks, err = keystore.Open(path, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	openedKeystore = ks
+	return ks, nil
+}
+
+func KeySource(keyFile, keyName string) string {
+	if keyName != "" {
+		return fmt.Sprintf("%s:%s", KeystorePath(), keyName)
+	}
+	return keyFile
+}
@@ -2,15 +2,16 @@ package windows
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/AleksaS003/zastitaprojekat/cmd/cli/utils"
+	"github.com/AleksaS003/zastitaprojekat/internal/network"
 )
 
 type NetworkWindow struct {
@@ -31,9 +32,12 @@ type NetworkWindow struct {
 	clientStatusLabel *widget.Label
 	serverLogLabel    *widget.Label
 
-	serverCmd       *exec.Cmd
+	serverProgress *widget.ProgressBar
+	clientProgress *widget.ProgressBar
+
+	server          *network.TCPServer
 	serverIsRunning bool
-	serverStopChan  chan bool
+	clientIsSending bool
 
 	serverBox *fyne.Container
 	clientBox *fyne.Container
@@ -41,8 +45,7 @@ type NetworkWindow struct {
 
 func NewNetworkWindow(parent fyne.Window) *NetworkWindow {
 	return &NetworkWindow{
-		parent:         parent,
-		serverStopChan: make(chan bool),
+		parent: parent,
 	}
 }
 
@@ -70,6 +73,8 @@ func (n *NetworkWindow) createWidgets() {
 
 	n.serverStatusLabel = widget.NewLabel("Status: Server nije pokrenut")
 	n.serverLogLabel = widget.NewLabel("")
+	n.serverProgress = widget.NewProgressBar()
+	n.serverProgress.Hide()
 
 	n.clientAddressEntry = widget.NewEntry()
 	n.clientAddressEntry.SetText("localhost:8080")
@@ -84,6 +89,8 @@ func (n *NetworkWindow) createWidgets() {
 	n.clientAlgoSelect.SetSelected("LEA-PCBC")
 
 	n.clientStatusLabel = widget.NewLabel("Status: Nije povezan")
+	n.clientProgress = widget.NewProgressBar()
+	n.clientProgress.Hide()
 }
 
 func (n *NetworkWindow) createLayout() *fyne.Container {
@@ -133,6 +140,7 @@ func (n *NetworkWindow) createLayout() *fyne.Container {
 		container.NewHBox(btnServerStart, btnServerStop),
 		n.serverStatusLabel,
 		widget.NewSeparator(),
+		n.serverProgress,
 		n.serverLogLabel,
 	)
 
@@ -179,6 +187,7 @@ func (n *NetworkWindow) createLayout() *fyne.Container {
 			n.clientAlgoSelect,
 		),
 		btnClientSend,
+		n.clientProgress,
 		n.clientStatusLabel,
 	)
 
@@ -222,17 +231,21 @@ func (n *NetworkWindow) startServer() {
 		return
 	}
 
+	key, err := utils.LoadKeyFile(n.serverKeyEntry.Text, "")
+	if err != nil {
+		dialog.ShowError(err, n.parent)
+		return
+	}
+
 	n.serverStatusLabel.SetText("Status: Pokrecem server...")
 
-	n.serverCmd = exec.Command("./crypto-cli",
-		"server",
-		"--address", n.serverAddressEntry.Text,
-		"--output", n.serverOutputEntry.Text,
-		"--keyfile", n.serverKeyEntry.Text,
-	)
+	// zaustavljen server ne moze ponovo da se pokrene, pa se pravi novi
+	n.server = network.NewTCPServer(n.serverAddressEntry.Text, n.serverOutputEntry.Text, key)
+	n.server.SetProgress(func(p network.Progress) {
+		fyne.Do(func() { n.showServerProgress(p) })
+	})
 
-	err := n.serverCmd.Start()
-	if err != nil {
+	if err := n.server.Start(); err != nil {
 		n.serverStatusLabel.SetText("Status: Greska pri pokretanju")
 		dialog.ShowError(err, n.parent)
 		return
@@ -241,41 +254,44 @@ func (n *NetworkWindow) startServer() {
 	n.serverIsRunning = true
 	n.serverStatusLabel.SetText(fmt.Sprintf("Status: Server radi na %s", n.serverAddressEntry.Text))
 	n.serverLogLabel.SetText("Server pokrenut, cekam konekcije...")
-
-	go func() {
-		waitErr := n.serverCmd.Wait()
-		fyne.Do(func() {
-			n.serverIsRunning = false
-			n.serverStatusLabel.SetText("Status: Server zaustavljen")
-
-			if waitErr != nil {
-				n.serverLogLabel.SetText(fmt.Sprintf("Server izasao sa greskom: %v", waitErr))
-			} else {
-				n.serverLogLabel.SetText("Server normalno zavrsio")
-			}
-		})
-	}()
 }
 
 func (n *NetworkWindow) stopServer() {
-	if !n.serverIsRunning || n.serverCmd == nil || n.serverCmd.Process == nil {
+	if !n.serverIsRunning || n.server == nil {
 		dialog.ShowInformation("Info", "Server nije pokrenut", n.parent)
 		return
 	}
 
-	n.serverStatusLabel.SetText("Status: Zaustavljam server...")
+	if err := n.server.Stop(); err != nil {
+		dialog.ShowError(fmt.Errorf("Greska pri zaustavljanju servera: %v", err), n.parent)
+		return
+	}
 
-	err := n.serverCmd.Process.Signal(os.Interrupt)
-	if err != nil {
-		err = n.serverCmd.Process.Kill()
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("Greska pri zaustavljanju servera: %v", err), n.parent)
-			return
-		}
+	n.serverIsRunning = false
+	n.serverStatusLabel.SetText("Status: Server zaustavljen")
+	n.serverLogLabel.SetText("Server zaustavljen")
+	n.serverProgress.Hide()
+}
+
+// showServerProgress prikazuje poslednji prenos o kome je server javio napredak.
+func (n *NetworkWindow) showServerProgress(p network.Progress) {
+	if !n.serverIsRunning {
+		return
 	}
 
-	n.serverLogLabel.SetText("Signal za zaustavljanje poslat serveru")
-	dialog.ShowInformation("Uspeh", "Signal za zaustavljanje poslat serveru", n.parent)
+	n.serverProgress.Show()
+	n.serverProgress.SetValue(p.Percent() / 100)
+
+	switch {
+	case p.Finished && p.Err != nil:
+		n.serverLogLabel.SetText(fmt.Sprintf("❌ %s (%s): %v", p.Name, p.Remote, p.Err))
+	case p.Finished && p.Direction == network.DirectionReceive:
+		n.serverLogLabel.SetText(fmt.Sprintf("✅ Primljen %s od %s (%s)", p.Name, p.Remote, formatSize(p.Total)))
+	case p.Finished:
+		n.serverLogLabel.SetText(fmt.Sprintf("✅ Poslat %s na %s (%s)", p.Name, p.Remote, formatSize(p.Total)))
+	default:
+		n.serverLogLabel.SetText(fmt.Sprintf("%s %s: %s", p.Name, p.Remote, progressText(p)))
+	}
 }
 
 func (n *NetworkWindow) sendFile() {
@@ -289,34 +305,82 @@ func (n *NetworkWindow) sendFile() {
 		return
 	}
 
+	if n.clientIsSending {
+		dialog.ShowInformation("Info", "Slanje je vec u toku", n.parent)
+		return
+	}
+
+	key, err := utils.LoadKeyFile(n.clientKeyEntry.Text, "")
+	if err != nil {
+		dialog.ShowError(err, n.parent)
+		return
+	}
+
+	address := n.clientAddressEntry.Text
+	filePath := n.clientFileEntry.Text
+	algorithm := n.clientAlgoSelect.Selected
+
+	n.clientIsSending = true
 	n.clientStatusLabel.SetText("📤 Saljem fajl...")
+	n.clientProgress.SetValue(0)
+	n.clientProgress.Show()
 
 	go func() {
-		cmd := exec.Command("./crypto-cli",
-			"client",
-			"--address", n.clientAddressEntry.Text,
-			"--file", n.clientFileEntry.Text,
-			"--keyfile", n.clientKeyEntry.Text,
-			"--algo", n.clientAlgoSelect.Selected,
-		)
-
-		output, err := cmd.CombinedOutput()
-		_ = err
-		outputStr := string(output)
+		client := network.NewTCPClient(address, 10*time.Second)
+		client.SetProgress(func(p network.Progress) {
+			if p.Finished {
+				return
+			}
+			fyne.Do(func() {
+				n.clientProgress.SetValue(p.Percent() / 100)
+				n.clientStatusLabel.SetText("📤 " + progressText(p))
+			})
+		})
+
+		err := client.Connect()
+		if err == nil {
+			err = client.SendFile(filePath, algorithm, key)
+			client.Disconnect()
+		}
 
 		fyne.Do(func() {
-			if strings.Contains(outputStr, "File sent successfully") {
-				n.clientStatusLabel.SetText("✅ Fajl uspesno poslat!")
-				dialog.ShowInformation("Uspeh",
-					fmt.Sprintf("Fajl '%s' je uspesno poslat na server %s",
-						filepath.Base(n.clientFileEntry.Text),
-						n.clientAddressEntry.Text),
-					n.parent)
-
-			} else {
+			n.clientIsSending = false
+			if err != nil {
+				n.clientProgress.Hide()
 				n.clientStatusLabel.SetText("❌ Greska pri slanju")
-				dialog.ShowError(fmt.Errorf(outputStr), n.parent)
+				dialog.ShowError(err, n.parent)
+				return
 			}
+
+			n.clientProgress.SetValue(1)
+			n.clientStatusLabel.SetText("✅ Fajl uspesno poslat!")
+			dialog.ShowInformation("Uspeh",
+				fmt.Sprintf("Fajl '%s' je uspesno poslat na server %s",
+					filepath.Base(filePath), address),
+				n.parent)
 		})
 	}()
 }
+
+// progressText opisuje napredak prenosa: procenat, brzinu i preostalo vreme.
+func progressText(p network.Progress) string {
+	eta := "--:--"
+	if p.ETA > 0 {
+		eta = p.ETA.Round(time.Second).String()
+	}
+	return fmt.Sprintf("%.1f%% (%s od %s, %s/s, jos %s)",
+		p.Percent(), formatSize(p.Done), formatSize(p.Total), formatSize(int64(p.Rate)), eta)
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,93 @@
+package keywrap
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/lea"
+)
+
+// Podrazumevani IV iz RFC 3394, služi kao provera integriteta pri raspakivanju
+var defaultIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+var ErrUnwrapFailed = errors.New("key unwrap failed: wrong key or corrupted wrapped key")
+
+// Wrap šifruje ključ podataka glavnim ključem (RFC 3394 sa LEA umesto AES).
+func Wrap(kek, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, fmt.Errorf("key to wrap must be a multiple of 8 bytes and at least 16 bytes, got %d", len(key))
+	}
+
+	cipher, err := lea.NewLEA(kek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LEA cipher: %w", err)
+	}
+
+	n := len(key) / 8
+	a := make([]byte, 8)
+	copy(a, defaultIV)
+	r := make([]byte, len(key))
+	copy(r, key)
+
+	block := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(block[:8], a)
+			copy(block[8:], r[i*8:(i+1)*8])
+
+			b, err := cipher.EncryptBlock(block)
+			if err != nil {
+				return nil, err
+			}
+
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(b[:8])^t)
+			copy(r[i*8:(i+1)*8], b[8:])
+		}
+	}
+
+	return append(a, r...), nil
+}
+
+// Unwrap vraća originalni ključ i proverava da je raspakovan ispravnim glavnim ključem.
+func Unwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("wrapped key must be a multiple of 8 bytes and at least 24 bytes, got %d", len(wrapped))
+	}
+
+	cipher, err := lea.NewLEA(kek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LEA cipher: %w", err)
+	}
+
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	r := make([]byte, n*8)
+	copy(r, wrapped[8:])
+
+	block := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(block[:8], binary.BigEndian.Uint64(a)^t)
+			copy(block[8:], r[i*8:(i+1)*8])
+
+			b, err := cipher.DecryptBlock(block)
+			if err != nil {
+				return nil, err
+			}
+
+			copy(a, b[:8])
+			copy(r[i*8:(i+1)*8], b[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(a, defaultIV) != 1 {
+		return nil, ErrUnwrapFailed
+	}
+
+	return r, nil
+}
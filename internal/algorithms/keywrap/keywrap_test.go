@@ -0,0 +1,187 @@
+package keywrap
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/lea"
+)
+
+// referenceWrap je RFC 3394 odeljak 2.2.1 u obliku sa pomeračkim registrom
+// (t = 1..6n), nezavisno od indeksiranja u Wrap, za bilo koju 128-bitnu šifru.
+func referenceWrap(block cipher.Block, key []byte) []byte {
+	n := len(key) / 8
+	a := binary.BigEndian.Uint64(defaultIV)
+	r := make([][]byte, n)
+	for i := range r {
+		r[i] = bytes.Clone(key[i*8 : (i+1)*8])
+	}
+
+	b := make([]byte, 16)
+	for t := 1; t <= 6*n; t++ {
+		binary.BigEndian.PutUint64(b[:8], a)
+		copy(b[8:], r[0])
+		block.Encrypt(b, b)
+		a = binary.BigEndian.Uint64(b[:8]) ^ uint64(t)
+		r = append(r[1:], bytes.Clone(b[8:]))
+	}
+
+	out := binary.BigEndian.AppendUint64(nil, a)
+	for _, ri := range r {
+		out = append(out, ri...)
+	}
+	return out
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestReferenceWrapRFC3394 proverava referentnu implementaciju na test
+// vektorima iz RFC 3394 (odeljak 4) sa AES-om.
+func TestReferenceWrapRFC3394(t *testing.T) {
+	tests := []struct {
+		kek, key, want string
+	}{
+		{
+			"000102030405060708090A0B0C0D0E0F",
+			"00112233445566778899AABBCCDDEEFF",
+			"1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5",
+		},
+		{
+			"000102030405060708090A0B0C0D0E0F1011121314151617",
+			"00112233445566778899AABBCCDDEEFF",
+			"96778B25AE6CA435F92B5B97C050AED2468AB8A17AD84E5D",
+		},
+		{
+			"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+			"00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F",
+			"28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21",
+		},
+	}
+
+	for _, tt := range tests {
+		block, err := aes.NewCipher(mustHex(t, tt.kek))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := referenceWrap(block, mustHex(t, tt.key))
+		if !bytes.Equal(got, mustHex(t, tt.want)) {
+			t.Errorf("KEK %s: got %X, want %s", tt.kek, got, tt.want)
+		}
+	}
+}
+
+// TestWrapMatchesReference proverava da Wrap sa LEA prati strukturu RFC 3394.
+func TestWrapMatchesReference(t *testing.T) {
+	for _, kekSize := range []int{16, 24, 32} {
+		for _, keySize := range []int{16, 24, 32, 64} {
+			kek := bytes.Repeat([]byte{byte(kekSize)}, kekSize)
+			key := make([]byte, keySize)
+			for i := range key {
+				key[i] = byte(i * 17)
+			}
+
+			block, err := lea.NewCipher(kek)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := referenceWrap(block, key)
+
+			got, err := Wrap(kek, key)
+			if err != nil {
+				t.Fatalf("Wrap: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("KEK %d, key %d bytes: got %x, want %x", kekSize, keySize, got, want)
+			}
+		}
+	}
+}
+
+func TestWrapUnwrapRoundTrip(t *testing.T) {
+	kek := bytes.Repeat([]byte{0x5a}, 32)
+	for _, size := range []int{16, 24, 32, 48} {
+		key := bytes.Repeat([]byte{0xc3}, size)
+
+		wrapped, err := Wrap(kek, key)
+		if err != nil {
+			t.Fatalf("Wrap: %v", err)
+		}
+		if len(wrapped) != size+8 {
+			t.Errorf("wrapped %d-byte key is %d bytes, want %d", size, len(wrapped), size+8)
+		}
+
+		unwrapped, err := Unwrap(kek, wrapped)
+		if err != nil {
+			t.Fatalf("Unwrap: %v", err)
+		}
+		if !bytes.Equal(unwrapped, key) {
+			t.Errorf("unwrapped %x, want %x", unwrapped, key)
+		}
+	}
+}
+
+func TestUnwrapWrongKEK(t *testing.T) {
+	key := bytes.Repeat([]byte{0x01}, 32)
+	wrapped, err := Wrap(bytes.Repeat([]byte{0x02}, 16), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Unwrap(bytes.Repeat([]byte{0x03}, 16), wrapped)
+	if !errors.Is(err, ErrUnwrapFailed) {
+		t.Errorf("got %v, want %v", err, ErrUnwrapFailed)
+	}
+}
+
+func TestUnwrapTampered(t *testing.T) {
+	kek := bytes.Repeat([]byte{0x02}, 16)
+	wrapped, err := Wrap(kek, bytes.Repeat([]byte{0x01}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range wrapped {
+		tampered := bytes.Clone(wrapped)
+		tampered[i] ^= 0x01
+		if _, err := Unwrap(kek, tampered); !errors.Is(err, ErrUnwrapFailed) {
+			t.Errorf("byte %d flipped: got %v, want %v", i, err, ErrUnwrapFailed)
+		}
+	}
+
+	// zamena redosleda 64-bitnih blokova takođe mora biti otkrivena
+	swapped := bytes.Clone(wrapped)
+	copy(swapped[8:16], wrapped[16:24])
+	copy(swapped[16:24], wrapped[8:16])
+	if _, err := Unwrap(kek, swapped); !errors.Is(err, ErrUnwrapFailed) {
+		t.Errorf("swapped blocks: got %v, want %v", err, ErrUnwrapFailed)
+	}
+}
+
+func TestInvalidLengths(t *testing.T) {
+	kek := bytes.Repeat([]byte{0x02}, 16)
+	for _, size := range []int{0, 8, 15, 17, 23} {
+		if _, err := Wrap(kek, make([]byte, size)); err == nil {
+			t.Errorf("Wrap accepted a %d-byte key", size)
+		}
+	}
+	for _, size := range []int{0, 16, 23, 25} {
+		if _, err := Unwrap(kek, make([]byte, size)); err == nil {
+			t.Errorf("Unwrap accepted %d wrapped bytes", size)
+		}
+	}
+	if _, err := Wrap(make([]byte, 10), make([]byte, 16)); err == nil {
+		t.Error("Wrap accepted a 10-byte KEK")
+	}
+}
@@ -0,0 +1,38 @@
+package lea
+
+import "crypto/cipher"
+
+const BlockSize = 16
+
+type blockCipher struct {
+	lea *LEA
+}
+
+// NewCipher vraća LEA kao cipher.Block, za upotrebu sa standardnim modovima (CTR, GCM).
+func NewCipher(key []byte) (cipher.Block, error) {
+	l, err := NewLEA(key)
+	if err != nil {
+		return nil, err
+	}
+	return &blockCipher{lea: l}, nil
+}
+
+func (b *blockCipher) BlockSize() int {
+	return BlockSize
+}
+
+func (b *blockCipher) Encrypt(dst, src []byte) {
+	encrypted, err := b.lea.EncryptBlock(src[:BlockSize])
+	if err != nil {
+		panic(err)
+	}
+	copy(dst, encrypted)
+}
+
+func (b *blockCipher) Decrypt(dst, src []byte) {
+	decrypted, err := b.lea.DecryptBlock(src[:BlockSize])
+	if err != nil {
+		panic(err)
+	}
+	copy(dst, decrypted)
+}
@@ -92,3 +92,76 @@ func (p *PCBC) Decrypt(dst, src []byte) error {
 
 	return nil
 }
+
+// pcbcMode čuva stanje lanca između poziva CryptBlocks, pa se podaci mogu
+// šifrovati i dešifrovati u delovima (npr. dok stižu preko mreže).
+type pcbcMode struct {
+	block      cipher.Block
+	blockSize  int
+	prevPlain  []byte
+	prevCipher []byte
+	decrypt    bool
+}
+
+// NewEncrypter vraća PCBC šifrovanje kao cipher.BlockMode.
+func NewEncrypter(block cipher.Block, iv []byte) (cipher.BlockMode, error) {
+	return newPCBCMode(block, iv, false)
+}
+
+// NewDecrypter vraća PCBC dešifrovanje kao cipher.BlockMode.
+func NewDecrypter(block cipher.Block, iv []byte) (cipher.BlockMode, error) {
+	return newPCBCMode(block, iv, true)
+}
+
+func newPCBCMode(block cipher.Block, iv []byte, decrypt bool) (*pcbcMode, error) {
+	blockSize := block.BlockSize()
+	if len(iv) != blockSize {
+		return nil, errors.New("IV length must equal block size")
+	}
+
+	m := &pcbcMode{
+		block:      block,
+		blockSize:  blockSize,
+		prevPlain:  make([]byte, blockSize),
+		prevCipher: make([]byte, blockSize),
+		decrypt:    decrypt,
+	}
+	copy(m.prevPlain, iv)
+	copy(m.prevCipher, iv)
+	return m, nil
+}
+
+func (m *pcbcMode) BlockSize() int {
+	return m.blockSize
+}
+
+// CryptBlocks dozvoljava da dst i src budu isti bafer.
+func (m *pcbcMode) CryptBlocks(dst, src []byte) {
+	if len(src)%m.blockSize != 0 {
+		panic("pcbc: input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("pcbc: output smaller than input")
+	}
+
+	in := make([]byte, m.blockSize)
+	for i := 0; i < len(src); i += m.blockSize {
+		copy(in, src[i:i+m.blockSize])
+
+		if m.decrypt {
+			m.block.Decrypt(dst[i:i+m.blockSize], in)
+			for j := 0; j < m.blockSize; j++ {
+				dst[i+j] ^= m.prevPlain[j] ^ m.prevCipher[j]
+			}
+			copy(m.prevPlain, dst[i:i+m.blockSize])
+			copy(m.prevCipher, in)
+		} else {
+			for j := 0; j < m.blockSize; j++ {
+				dst[i+j] = in[j] ^ m.prevPlain[j] ^ m.prevCipher[j]
+			}
+			m.block.Encrypt(dst[i:i+m.blockSize], dst[i:i+m.blockSize])
+			copy(m.prevPlain, in)
+			copy(m.prevCipher, dst[i:i+m.blockSize])
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package sha256
+
+import (
+	"crypto/hmac"
+	"hash"
+)
+
+func New() hash.Hash {
+	return NewSHA256()
+}
+
+func NewHMAC(key []byte) hash.Hash {
+	return hmac.New(New, key)
+}
+
+func HMAC(key, data []byte) [32]byte {
+	mac := NewHMAC(key)
+	mac.Write(data)
+
+	var sum [32]byte
+	copy(sum[:], mac.Sum(nil))
+	return sum
+}
+
+func VerifyHMAC(key, data []byte, expected []byte) bool {
+	sum := HMAC(key, data)
+	return hmac.Equal(sum[:], expected)
+}
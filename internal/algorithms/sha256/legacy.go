@@ -0,0 +1,69 @@
+package sha256
+
+import "hash"
+
+// legacy reproduces the digest HashBytes returned before block() compressed
+// every block. Of the data written in one call, only the first 64-byte block
+// and the bytes after the last whole block were hashed, together with the
+// total length. Containers written back then store this digest, so it is
+// kept to verify them until they are re-keyed.
+//
+// The digest does not depend on how the data is split across Write calls.
+type legacy struct {
+	first [64]byte
+	tail  [64]byte
+	n     uint64
+}
+
+// NewLegacy returns a hash computing the pre-fix digest of everything
+// written to it as if it had been passed to HashBytes at once.
+func NewLegacy() hash.Hash {
+	return &legacy{}
+}
+
+// LegacyHashBytes returns the digest HashBytes returned for data before the fix.
+func LegacyHashBytes(data []byte) [32]byte {
+	l := &legacy{}
+	l.Write(data)
+	var sum [32]byte
+	copy(sum[:], l.Sum(nil))
+	return sum
+}
+
+func (l *legacy) Write(p []byte) (int, error) {
+	if l.n < 64 {
+		copy(l.first[l.n:], p)
+	}
+	if len(p) >= 64 {
+		copy(l.tail[:], p[len(p)-64:])
+	} else {
+		copy(l.tail[:], l.tail[len(p):])
+		copy(l.tail[64-len(p):], p)
+	}
+	l.n += uint64(len(p))
+	return len(p), nil
+}
+
+func (l *legacy) Sum(in []byte) []byte {
+	s := NewSHA256()
+	if l.n < 64 {
+		s.nx = copy(s.x[:], l.first[:l.n])
+	} else {
+		s.compress(l.first[:])
+		s.nx = copy(s.x[:], l.tail[64-l.n%64:])
+	}
+	s.len = l.n
+	return s.Sum(in)
+}
+
+func (l *legacy) Reset() {
+	*l = legacy{}
+}
+
+func (l *legacy) Size() int {
+	return 32
+}
+
+func (l *legacy) BlockSize() int {
+	return 64
+}
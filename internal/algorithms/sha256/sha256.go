@@ -30,30 +30,39 @@ func (s *SHA256) Reset() {
 	s.len = 0
 }
 
+func (s *SHA256) Size() int {
+	return 32
+}
+
+func (s *SHA256) BlockSize() int {
+	return 64
+}
+
 func (s *SHA256) Write(p []byte) (n int, err error) {
-	s.len += uint64(len(p))
+	n = len(p)
+	s.len += uint64(n)
 
 	if s.nx > 0 {
-		n := copy(s.x[s.nx:], p)
-		s.nx += n
+		c := copy(s.x[s.nx:], p)
+		s.nx += c
 		if s.nx == 64 {
 			s.block(s.x[:])
 			s.nx = 0
 		}
-		p = p[n:]
+		p = p[c:]
 	}
 
 	if len(p) >= 64 {
-		n := len(p) &^ (64 - 1)
-		s.block(p[:n])
-		p = p[n:]
+		c := len(p) &^ (64 - 1)
+		s.block(p[:c])
+		p = p[c:]
 	}
 
 	if len(p) > 0 {
 		s.nx = copy(s.x[:], p)
 	}
 
-	return len(p), nil
+	return n, nil
 }
 
 func (s *SHA256) Sum(in []byte) []byte {
@@ -100,6 +109,13 @@ func (s *SHA256) checkSum() []byte {
 }
 
 func (s *SHA256) block(p []byte) {
+	for len(p) >= 64 {
+		s.compress(p[:64])
+		p = p[64:]
+	}
+}
+
+func (s *SHA256) compress(p []byte) {
 
 	var w [64]uint32
 
@@ -0,0 +1,145 @@
+package sha256
+
+import (
+	"bytes"
+	"crypto/hmac"
+	stdsha256 "crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKnownAnswers(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", "248d6a61d20638b8e5c026930c3e6039a33ce45964ff2167f6ecedd419db06c1"},
+		{"abcdefghbcdefghicdefghijdefghijkefghijklfghijklmghijklmnhijklmnoijklmnopjklmnopqklmnopqrlmnopqrsmnopqrstnopqrstu", "cf5b16a778af8380036ce59e7b0492370b249b11e8f07a51afac45037afee9d1"},
+	}
+
+	for _, tt := range tests {
+		h := NewSHA256()
+		h.Write([]byte(tt.input))
+		if got := hex.EncodeToString(h.Sum(nil)); got != tt.want {
+			t.Errorf("SHA256(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestMatchesStandardLibrary covers every length around the block and
+// padding boundaries, plus inputs spanning many blocks.
+func TestMatchesStandardLibrary(t *testing.T) {
+	data := make([]byte, 4096+3)
+	for i := range data {
+		data[i] = byte(i*7 + i>>8)
+	}
+
+	lengths := []int{1000, 4095, 4096, len(data)}
+	for n := 0; n <= 300; n++ {
+		lengths = append(lengths, n)
+	}
+
+	for _, n := range lengths {
+		h := NewSHA256()
+		h.Write(data[:n])
+		want := stdsha256.Sum256(data[:n])
+		if got := h.Sum256(); got != want {
+			t.Errorf("length %d: got %x, want %x", n, got, want)
+		}
+	}
+}
+
+func TestChunkedWrites(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdefghijklmnopqrstuvwxyz"), 100)
+	want := stdsha256.Sum256(data)
+
+	for _, chunk := range []int{1, 3, 63, 64, 65, 127, 500} {
+		h := NewSHA256()
+		for p := data; len(p) > 0; {
+			c := min(chunk, len(p))
+			n, err := h.Write(p[:c])
+			if err != nil || n != c {
+				t.Fatalf("chunk %d: Write returned %d, %v; want %d, nil", chunk, n, err, c)
+			}
+			p = p[c:]
+		}
+		if got := h.Sum256(); got != want {
+			t.Errorf("chunk %d: got %x, want %x", chunk, got, want)
+		}
+	}
+}
+
+func TestSumDoesNotChangeState(t *testing.T) {
+	h := NewSHA256()
+	h.Write([]byte("first part, "))
+	h.Sum(nil)
+	h.Write([]byte("second part"))
+
+	want := stdsha256.Sum256([]byte("first part, second part"))
+	if got := h.Sum256(); got != want {
+		t.Errorf("got %x, want %x", got, want)
+	}
+}
+
+func TestHMACMatchesStandardLibrary(t *testing.T) {
+	keys := [][]byte{nil, []byte("key"), bytes.Repeat([]byte{0x0b}, 64), bytes.Repeat([]byte{0xaa}, 131)}
+	data := bytes.Repeat([]byte("message "), 40)
+
+	for _, key := range keys {
+		mac := hmac.New(stdsha256.New, key)
+		mac.Write(data)
+		want := mac.Sum(nil)
+
+		got := HMAC(key, data)
+		if !bytes.Equal(got[:], want) {
+			t.Errorf("key of %d bytes: got %x, want %x", len(key), got, want)
+		}
+		if !VerifyHMAC(key, data, want) {
+			t.Errorf("key of %d bytes: VerifyHMAC rejected a valid MAC", len(key))
+		}
+	}
+}
+
+// TestLegacyDigest checks the pre-fix digest against values produced by
+// the implementation before block() compressed every block.
+func TestLegacyDigest(t *testing.T) {
+	data := make([]byte, 300)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{3, "ae4b3280e56e2faf83f414a6e3dabe9d5fbe18976544c05fed121accb85b53fc"},
+		{63, "29af2686fd53374a36b0846694cc342177e428d1647515f078784d69cdb9e488"},
+		{64, "fdeab9acf3710362bd2658cdc9a29e8f9c757fcf9811603a8c447cd1d9151108"},
+		{65, "4bfd2c8b6f1eec7a2afeb48b934ee4b2694182027e6d0fc075074f2fabb31781"},
+		{128, "8d5995d65102b49a246d5be362bf47da270c46e63fe947a0a911e016822ddbcc"},
+		{200, "7527176ee5ff452454cd2151cc142b7af5afaf10cbf0987012ff45ca23bb068d"},
+		{300, "3e1ac7a32283775750b185099f3ac3797b1d28762ea3070558c16c9195e3601a"},
+	}
+
+	for _, tt := range tests {
+		if got := HashToString(LegacyHashBytes(data[:tt.n])); got != tt.want {
+			t.Errorf("length %d: got %s, want %s", tt.n, got, tt.want)
+		}
+
+		// the legacy digest must not depend on how the data is written
+		for _, chunk := range []int{1, 7, 64, 65} {
+			h := NewLegacy()
+			for p := data[:tt.n]; len(p) > 0; {
+				c := min(chunk, len(p))
+				h.Write(p[:c])
+				p = p[c:]
+			}
+			if got := hex.EncodeToString(h.Sum(nil)); got != tt.want {
+				t.Errorf("length %d in chunks of %d: got %s, want %s", tt.n, chunk, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/keywrap"
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/lea"
+)
+
+const KeyWrapAlgorithm = "LEA-KW"
+
+// newDataKey generates a fresh per-file key of the same size as the master key.
+func newDataKey(masterKey []byte) ([]byte, error) {
+	dataKey, err := lea.GenerateKey(len(masterKey) * 8)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// SetDataKey wraps dataKey with masterKey and records it in the metadata.
+func (m *Metadata) SetDataKey(masterKey, dataKey []byte) error {
+	wrapped, err := keywrap.Wrap(masterKey, dataKey)
+	if err != nil {
+		return fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	m.WrappedKey = hex.EncodeToString(wrapped)
+	m.KeyWrapAlgorithm = KeyWrapAlgorithm
+	m.KeyInfo = KeyFingerprint(masterKey)
+	return nil
+}
+
+// DataKey returns the key the ciphertext was encrypted with. Files written
+// before envelope encryption carry no wrapped key and use the master key directly.
+func (m *Metadata) DataKey(masterKey []byte) ([]byte, error) {
+	if m.WrappedKey == "" {
+		if len(m.Recipients) > 0 {
+			return nil, fmt.Errorf("file is encrypted for %d recipient(s) only, a private key is required", len(m.Recipients))
+		}
+		return masterKey, nil
+	}
+
+	if m.KeyWrapAlgorithm != "" && m.KeyWrapAlgorithm != KeyWrapAlgorithm {
+		return nil, fmt.Errorf("unsupported key wrap algorithm: %s", m.KeyWrapAlgorithm)
+	}
+
+	wrapped, err := hex.DecodeString(m.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key in metadata: %w", err)
+	}
+
+	dataKey, err := keywrap.Unwrap(masterKey, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return dataKey, nil
+}
+
+// Rewrap returns a copy of the metadata with the data key re-wrapped from
+// oldKey to newKey. The ciphertext hash stays valid, but the embedded
+// signature covers the old header and is removed.
+func (m *Metadata) Rewrap(oldKey, newKey []byte) (*Metadata, error) {
+	dataKey, err := m.DataKey(oldKey)
+	if err != nil {
+		return nil, err
+	}
+
+	newMetadata := *m
+	newMetadata.Timestamp = time.Now().UTC()
+	newMetadata.Signature = nil
+	if err := newMetadata.SetDataKey(newKey, dataKey); err != nil {
+		return nil, err
+	}
+
+	unwrapped, err := newMetadata.DataKey(newKey)
+	if err != nil || !bytes.Equal(unwrapped, dataKey) {
+		return nil, fmt.Errorf("verification failed: re-wrapped data key does not match")
+	}
+	return &newMetadata, nil
+}
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"crypto/ecdh"
+	"crypto/ed25519"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,12 +14,18 @@ import (
 )
 
 type FileProcessor struct {
+	signingKey ed25519.PrivateKey
 }
 
 func NewFileProcessor() *FileProcessor {
 	return &FileProcessor{}
 }
 
+// SetSigningKey makes every encrypted container carry an embedded Ed25519 signature.
+func (fp *FileProcessor) SetSigningKey(key ed25519.PrivateKey) {
+	fp.signingKey = key
+}
+
 func (fp *FileProcessor) EncryptFileWithMetadata(
 	inputPath string,
 	outputPath string,
@@ -25,6 +33,65 @@ func (fp *FileProcessor) EncryptFileWithMetadata(
 	key []byte,
 ) error {
 
+	dataKey, err := newDataKey(key)
+	if err != nil {
+		logger.Error(logger.ENCRYPT, "Failed to generate data key", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	return fp.encryptFile(inputPath, outputPath, algorithm, dataKey, func(metadata *Metadata) error {
+		return metadata.SetDataKey(key, dataKey)
+	})
+}
+
+// EncryptFileForRecipients encrypts the file so that every recipient can
+// decrypt it with their X25519 private key. When key is not nil the data key
+// is also wrapped with that symmetric master key.
+func (fp *FileProcessor) EncryptFileForRecipients(
+	inputPath string,
+	outputPath string,
+	algorithm string,
+	key []byte,
+	recipients []*ecdh.PublicKey,
+) error {
+
+	if len(recipients) == 0 && key == nil {
+		return fmt.Errorf("at least one recipient or a master key is required")
+	}
+
+	dataKey, err := lea.GenerateKey(256)
+	if err != nil {
+		logger.Error(logger.ENCRYPT, "Failed to generate data key", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	return fp.encryptFile(inputPath, outputPath, algorithm, dataKey, func(metadata *Metadata) error {
+		if key != nil {
+			if err := metadata.SetDataKey(key, dataKey); err != nil {
+				return err
+			}
+		}
+		for _, recipient := range recipients {
+			if err := metadata.AddRecipient(recipient, dataKey); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (fp *FileProcessor) encryptFile(
+	inputPath string,
+	outputPath string,
+	algorithm string,
+	dataKey []byte,
+	wrapKey func(*Metadata) error,
+) error {
+
 	originalFileInfo, err := os.Stat(inputPath)
 	if err != nil {
 		return fmt.Errorf("failed to read input file: %w", err)
@@ -35,7 +102,7 @@ func (fp *FileProcessor) EncryptFileWithMetadata(
 		"output_file": outputPath,
 		"algorithm":   algorithm,
 		"file_size":   originalFileInfo.Size(),
-		"key_size":    len(key) * 8,
+		"key_size":    len(dataKey) * 8,
 	})
 
 	data, err := os.ReadFile(inputPath)
@@ -47,64 +114,9 @@ func (fp *FileProcessor) EncryptFileWithMetadata(
 		return fmt.Errorf("failed to read input file: %w", err)
 	}
 
-	var encryptedData []byte
-	var iv []byte
-
-	switch algorithm {
-	case "LEA":
-		logger.Info(logger.ENCRYPT, "Using LEA algorithm", true, map[string]interface{}{
-			"key_size": len(key) * 8,
-		})
-
-		cipher, err := lea.NewLEA(key)
-		if err != nil {
-			logger.Error(logger.ENCRYPT, "Failed to create LEA cipher", map[string]interface{}{
-				"key_size": len(key) * 8,
-				"error":    err.Error(),
-			})
-			return fmt.Errorf("failed to create LEA cipher: %w", err)
-		}
-		encryptedData, err = cipher.Encrypt(data)
-		if err != nil {
-			logger.Error(logger.ENCRYPT, "LEA encryption failed", map[string]interface{}{
-				"error": err.Error(),
-			})
-			return fmt.Errorf("encryption failed: %w", err)
-		}
-
-	case "LEA-PCBC":
-		logger.Info(logger.ENCRYPT, "Using LEA-PCBC algorithm", true, map[string]interface{}{
-			"key_size": len(key) * 8,
-			"mode":     "PCBC",
-		})
-
-		pcbcCipher, err := pcbc.NewLEAPCBC(key)
-		if err != nil {
-			logger.Error(logger.ENCRYPT, "Failed to create PCBC cipher", map[string]interface{}{
-				"error": err.Error(),
-			})
-			return fmt.Errorf("failed to create PCBC cipher: %w", err)
-		}
-		encryptedData, err = pcbcCipher.Encrypt(data)
-		if err != nil {
-			logger.Error(logger.ENCRYPT, "PCBC encryption failed", map[string]interface{}{
-				"error": err.Error(),
-			})
-			return fmt.Errorf("PCBC encryption failed: %w", err)
-		}
-		iv = pcbcCipher.GetIV()
-
-		logger.Info(logger.ENCRYPT, "PCBC IV generated", true, map[string]interface{}{
-			"iv_size": len(iv),
-			"iv_hex":  fmt.Sprintf("%x...", iv[:8]),
-		})
-
-	default:
-		logger.Error(logger.ENCRYPT, "Unsupported algorithm", map[string]interface{}{
-			"algorithm": algorithm,
-			"supported": []string{"LEA", "LEA-PCBC"},
-		})
-		return fmt.Errorf("unsupported algorithm: %s", algorithm)
+	encryptedData, iv, err := fp.encryptData(data, algorithm, dataKey)
+	if err != nil {
+		return err
 	}
 
 	encryptedHash := sha256.HashBytes(encryptedData)
@@ -130,6 +142,21 @@ func (fp *FileProcessor) EncryptFileWithMetadata(
 		})
 		return fmt.Errorf("failed to create metadata: %w", err)
 	}
+	if err := wrapKey(metadata); err != nil {
+		logger.Error(logger.ENCRYPT, "Failed to wrap data key", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	if fp.signingKey != nil {
+		if err := metadata.Sign(fp.signingKey, encryptedData); err != nil {
+			logger.Error(logger.ENCRYPT, "Failed to sign container", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return fmt.Errorf("failed to sign container: %w", err)
+		}
+	}
 
 	finalData, err := metadata.AddToEncryptedFile(nil, encryptedData)
 	if err != nil {
@@ -160,6 +187,10 @@ func (fp *FileProcessor) EncryptFileWithMetadata(
 		"hash_algorithm": "SHA-256",
 		"hash_verified":  "on_receive",
 		"iv_used":        iv != nil,
+		"key_info":       metadata.KeyInfo,
+		"key_wrap":       metadata.KeyWrapAlgorithm,
+		"recipients":     len(metadata.Recipients),
+		"signed":         metadata.Signature != nil,
 	})
 
 	return nil
@@ -171,6 +202,38 @@ func (fp *FileProcessor) DecryptFileWithMetadata(
 	key []byte,
 ) (*Metadata, error) {
 
+	return fp.decryptFile(inputPath, outputPath, func(metadata *Metadata) ([]byte, error) {
+		if err := metadata.CheckKey(key); err != nil {
+			logger.Error(logger.DECRYPT, "Supplied key does not match the key used for encryption", map[string]interface{}{
+				"file":            inputPath,
+				"file_key_info":   metadata.KeyInfo,
+				"supplied_key_fp": KeyFingerprint(key),
+			})
+			return nil, err
+		}
+		return metadata.DataKey(key)
+	})
+}
+
+// DecryptFileWithIdentity decrypts a file encrypted for recipients using the
+// recipient's X25519 private key.
+func (fp *FileProcessor) DecryptFileWithIdentity(
+	inputPath string,
+	outputPath string,
+	identity *ecdh.PrivateKey,
+) (*Metadata, error) {
+
+	return fp.decryptFile(inputPath, outputPath, func(metadata *Metadata) ([]byte, error) {
+		return metadata.RecipientDataKey(identity)
+	})
+}
+
+func (fp *FileProcessor) decryptFile(
+	inputPath string,
+	outputPath string,
+	unwrapKey func(*Metadata) ([]byte, error),
+) (*Metadata, error) {
+
 	inputFileInfo, err := os.Stat(inputPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read input file: %w", err)
@@ -180,7 +243,6 @@ func (fp *FileProcessor) DecryptFileWithMetadata(
 		"input_file":  inputPath,
 		"output_file": outputPath,
 		"file_size":   inputFileInfo.Size(),
-		"key_size":    len(key) * 8,
 	})
 
 	data, err := os.ReadFile(inputPath)
@@ -208,96 +270,27 @@ func (fp *FileProcessor) DecryptFileWithMetadata(
 		"iv_present":     metadata.IV != "",
 		"encrypted_size": len(encryptedData),
 		"metadata_size":  len(data) - len(encryptedData),
+		"key_info":       metadata.KeyInfo,
+		"key_wrapped":    metadata.WrappedKey != "",
+		"recipients":     len(metadata.Recipients),
 	})
 
-	if metadata.Hash != "" {
-		logger.Info(logger.VERIFY_HASH, "Starting hash verification of encrypted file", true, map[string]interface{}{
-			"expected_hash": metadata.Hash[:16] + "...",
-			"algorithm":     metadata.HashAlgorithm,
-		})
-
-		receivedHash := sha256.HashBytes(encryptedData)
-		receivedHashStr := sha256.HashToString(receivedHash)
-
-		logger.LogHashVerification(inputPath, metadata.Hash, receivedHashStr, receivedHashStr == metadata.Hash)
-
-		if receivedHashStr != metadata.Hash {
-			logger.Error(logger.VERIFY_HASH, "❌ Hash verification FAILED - file may be corrupted in transit", map[string]interface{}{
-				"file":          inputPath,
-				"expected_hash": metadata.Hash,
-				"actual_hash":   receivedHashStr,
-			})
-			return metadata, fmt.Errorf("hash verification failed: file corrupted during transfer")
-		}
-
-		logger.Info(logger.VERIFY_HASH, "✅ Hash verification successful - file integrity confirmed", true, map[string]interface{}{
-			"file": inputPath,
-		})
-	} else {
-		logger.Warning(logger.VERIFY_HASH, "No hash in metadata for verification", true, map[string]interface{}{
-			"file": inputPath,
+	dataKey, err := unwrapKey(metadata)
+	if err != nil {
+		logger.Error(logger.DECRYPT, "Failed to unwrap data key", map[string]interface{}{
+			"file":  inputPath,
+			"error": err.Error(),
 		})
+		return metadata, err
 	}
 
-	var decryptedData []byte
-
-	switch metadata.EncryptionAlgorithm {
-	case "LEA":
-		logger.Info(logger.DECRYPT, "Using LEA algorithm for decryption", true, nil)
-
-		cipher, err := lea.NewLEA(key)
-		if err != nil {
-			logger.Error(logger.DECRYPT, "Failed to create LEA cipher", map[string]interface{}{
-				"error": err.Error(),
-			})
-			return nil, fmt.Errorf("failed to create LEA cipher: %w", err)
-		}
-		decryptedData, err = cipher.Decrypt(encryptedData)
-		if err != nil {
-			logger.Error(logger.DECRYPT, "LEA decryption failed", map[string]interface{}{
-				"error": err.Error(),
-			})
-			return nil, fmt.Errorf("decryption failed: %w", err)
-		}
-
-	case "LEA-PCBC":
-		logger.Info(logger.DECRYPT, "Using LEA-PCBC algorithm for decryption", true, map[string]interface{}{
-			"iv_present": metadata.IV != "",
-		})
-
-		var iv []byte
-		if metadata.IV != "" {
-			iv = make([]byte, len(metadata.IV)/2)
-			for i := 0; i < len(iv); i++ {
-				fmt.Sscanf(metadata.IV[i*2:i*2+2], "%02x", &iv[i])
-			}
-
-			logger.Info(logger.DECRYPT, "IV extracted from metadata", true, map[string]interface{}{
-				"iv_size": len(iv),
-				"iv_hex":  fmt.Sprintf("%x...", iv[:8]),
-			})
-		}
-
-		pcbcCipher, err := pcbc.NewLEAPCBCWithIV(key, iv)
-		if err != nil {
-			logger.Error(logger.DECRYPT, "Failed to create PCBC cipher", map[string]interface{}{
-				"error": err.Error(),
-			})
-			return nil, fmt.Errorf("failed to create PCBC cipher: %w", err)
-		}
-		decryptedData, err = pcbcCipher.Decrypt(encryptedData)
-		if err != nil {
-			logger.Error(logger.DECRYPT, "PCBC decryption failed", map[string]interface{}{
-				"error": err.Error(),
-			})
-			return nil, fmt.Errorf("PCBC decryption failed: %w", err)
-		}
+	if err := fp.verifyEncryptedHash(inputPath, metadata, encryptedData); err != nil {
+		return metadata, err
+	}
 
-	default:
-		logger.Error(logger.DECRYPT, "Unsupported algorithm in metadata", map[string]interface{}{
-			"algorithm": metadata.EncryptionAlgorithm,
-		})
-		return nil, fmt.Errorf("unsupported algorithm: %s", metadata.EncryptionAlgorithm)
+	decryptedData, err := fp.decryptData(metadata, encryptedData, dataKey)
+	if err != nil {
+		return nil, err
 	}
 
 	err = os.WriteFile(outputPath, decryptedData, 0644)
@@ -397,3 +390,179 @@ func (fp *FileProcessor) ProcessDirectory(
 
 	return processedFiles, nil
 }
+
+func (fp *FileProcessor) encryptData(data []byte, algorithm string, key []byte) ([]byte, []byte, error) {
+	var encryptedData []byte
+	var iv []byte
+
+	switch algorithm {
+	case "LEA":
+		logger.Info(logger.ENCRYPT, "Using LEA algorithm", true, map[string]interface{}{
+			"key_size": len(key) * 8,
+		})
+
+		cipher, err := lea.NewLEA(key)
+		if err != nil {
+			logger.Error(logger.ENCRYPT, "Failed to create LEA cipher", map[string]interface{}{
+				"key_size": len(key) * 8,
+				"error":    err.Error(),
+			})
+			return nil, nil, fmt.Errorf("failed to create LEA cipher: %w", err)
+		}
+		encryptedData, err = cipher.Encrypt(data)
+		if err != nil {
+			logger.Error(logger.ENCRYPT, "LEA encryption failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, nil, fmt.Errorf("encryption failed: %w", err)
+		}
+
+	case "LEA-PCBC":
+		logger.Info(logger.ENCRYPT, "Using LEA-PCBC algorithm", true, map[string]interface{}{
+			"key_size": len(key) * 8,
+			"mode":     "PCBC",
+		})
+
+		pcbcCipher, err := pcbc.NewLEAPCBC(key)
+		if err != nil {
+			logger.Error(logger.ENCRYPT, "Failed to create PCBC cipher", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, nil, fmt.Errorf("failed to create PCBC cipher: %w", err)
+		}
+		encryptedData, err = pcbcCipher.Encrypt(data)
+		if err != nil {
+			logger.Error(logger.ENCRYPT, "PCBC encryption failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, nil, fmt.Errorf("PCBC encryption failed: %w", err)
+		}
+		iv = pcbcCipher.GetIV()
+
+		logger.Info(logger.ENCRYPT, "PCBC IV generated", true, map[string]interface{}{
+			"iv_size": len(iv),
+			"iv_hex":  fmt.Sprintf("%x...", iv[:8]),
+		})
+
+	default:
+		logger.Error(logger.ENCRYPT, "Unsupported algorithm", map[string]interface{}{
+			"algorithm": algorithm,
+			"supported": []string{"LEA", "LEA-PCBC"},
+		})
+		return nil, nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+
+	return encryptedData, iv, nil
+}
+
+func (fp *FileProcessor) verifyEncryptedHash(inputPath string, metadata *Metadata, encryptedData []byte) error {
+	if metadata.Hash != "" {
+		logger.Info(logger.VERIFY_HASH, "Starting hash verification of encrypted file", true, map[string]interface{}{
+			"expected_hash": shortHash(metadata.Hash),
+			"algorithm":     metadata.HashAlgorithm,
+		})
+
+		if metadata.HashVersion == HashVersionLegacy {
+			logger.Warning(logger.VERIFY_HASH, "Container uses the legacy SHA-256 digest, re-key it to upgrade", true, map[string]interface{}{
+				"file": inputPath,
+			})
+		}
+
+		receivedHashStr := metadata.HashOf(encryptedData)
+
+		logger.LogHashVerification(inputPath, metadata.Hash, receivedHashStr, receivedHashStr == metadata.Hash)
+
+		if receivedHashStr != metadata.Hash {
+			logger.Error(logger.VERIFY_HASH, "❌ Hash verification FAILED - file may be corrupted in transit", map[string]interface{}{
+				"file":          inputPath,
+				"expected_hash": metadata.Hash,
+				"actual_hash":   receivedHashStr,
+			})
+			return fmt.Errorf("hash verification failed: file corrupted during transfer")
+		}
+
+		logger.Info(logger.VERIFY_HASH, "✅ Hash verification successful - file integrity confirmed", true, map[string]interface{}{
+			"file": inputPath,
+		})
+	} else {
+		logger.Warning(logger.VERIFY_HASH, "No hash in metadata for verification", true, map[string]interface{}{
+			"file": inputPath,
+		})
+	}
+
+	return nil
+}
+
+// shortHash abbreviates a hash for log messages. The hash comes from the
+// container header, so it may be shorter than expected.
+func shortHash(hash string) string {
+	if len(hash) <= 16 {
+		return hash
+	}
+	return hash[:16] + "..."
+}
+
+func (fp *FileProcessor) decryptData(metadata *Metadata, encryptedData []byte, key []byte) ([]byte, error) {
+	var decryptedData []byte
+
+	switch metadata.EncryptionAlgorithm {
+	case "LEA":
+		logger.Info(logger.DECRYPT, "Using LEA algorithm for decryption", true, nil)
+
+		cipher, err := lea.NewLEA(key)
+		if err != nil {
+			logger.Error(logger.DECRYPT, "Failed to create LEA cipher", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, fmt.Errorf("failed to create LEA cipher: %w", err)
+		}
+		decryptedData, err = cipher.Decrypt(encryptedData)
+		if err != nil {
+			logger.Error(logger.DECRYPT, "LEA decryption failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, fmt.Errorf("decryption failed: %w", err)
+		}
+
+	case "LEA-PCBC":
+		logger.Info(logger.DECRYPT, "Using LEA-PCBC algorithm for decryption", true, map[string]interface{}{
+			"iv_present": metadata.IV != "",
+		})
+
+		var iv []byte
+		if metadata.IV != "" {
+			iv = make([]byte, len(metadata.IV)/2)
+			for i := 0; i < len(iv); i++ {
+				fmt.Sscanf(metadata.IV[i*2:i*2+2], "%02x", &iv[i])
+			}
+
+			logger.Info(logger.DECRYPT, "IV extracted from metadata", true, map[string]interface{}{
+				"iv_size": len(iv),
+				"iv_hex":  fmt.Sprintf("%x...", iv[:8]),
+			})
+		}
+
+		pcbcCipher, err := pcbc.NewLEAPCBCWithIV(key, iv)
+		if err != nil {
+			logger.Error(logger.DECRYPT, "Failed to create PCBC cipher", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, fmt.Errorf("failed to create PCBC cipher: %w", err)
+		}
+		decryptedData, err = pcbcCipher.Decrypt(encryptedData)
+		if err != nil {
+			logger.Error(logger.DECRYPT, "PCBC decryption failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, fmt.Errorf("PCBC decryption failed: %w", err)
+		}
+
+	default:
+		logger.Error(logger.DECRYPT, "Unsupported algorithm in metadata", map[string]interface{}{
+			"algorithm": metadata.EncryptionAlgorithm,
+		})
+		return nil, fmt.Errorf("unsupported algorithm: %s", metadata.EncryptionAlgorithm)
+	}
+
+	return decryptedData, nil
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+)
+
+const fingerprintLabel = "zastitaprojekat key fingerprint v1"
+
+type KeyMismatchError struct {
+	Expected string
+	Supplied string
+}
+
+func (e *KeyMismatchError) Error() string {
+	return fmt.Sprintf("key mismatch: file was encrypted with key %s, you supplied %s", e.Expected, e.Supplied)
+}
+
+// KeyFingerprint identifies a key without revealing it: the first 8 bytes of
+// HMAC-SHA256 over a fixed label, keyed with the key itself.
+func KeyFingerprint(key []byte) string {
+	mac := sha256.HMAC(key, []byte(fingerprintLabel))
+	return fmt.Sprintf("%x", mac[:8])
+}
+
+// CheckKey reports a *KeyMismatchError when the metadata records a fingerprint
+// different from the supplied key. Files without KeyInfo are accepted.
+func (m *Metadata) CheckKey(key []byte) error {
+	if m.KeyInfo == "" {
+		return nil
+	}
+
+	supplied := KeyFingerprint(key)
+	if supplied != m.KeyInfo {
+		return &KeyMismatchError{Expected: m.KeyInfo, Supplied: supplied}
+	}
+
+	return nil
+}
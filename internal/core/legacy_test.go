@@ -0,0 +1,168 @@
+package core
+
+import (
+	"bytes"
+	stdsha256 "crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+// TestMain sends the activity log to a temporary directory and keeps it off
+// the console.
+func TestMain(m *testing.M) {
+	logDir, err := os.MkdirTemp("", "core-test-logs")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	err = logger.InitGlobal(logDir)
+	os.Stdout = stdout
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+
+	code := m.Run()
+	os.RemoveAll(logDir)
+	os.Exit(code)
+}
+
+// The testdata/legacy-*.enc containers were written by the build before the
+// SHA-256 fix from testdata/legacy.txt under legacyKey. Their Hash is the
+// legacy digest and they carry no hash_version.
+var legacyKey = []byte("0123456789abcdef")
+
+var legacyContainers = []string{"legacy-lea.txt.enc", "legacy-pcbc.txt.enc"}
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// copyTestdata copies a container into a temporary directory, so tests can modify it.
+func copyTestdata(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, readTestdata(t, name), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDecryptLegacyContainer(t *testing.T) {
+	want := readTestdata(t, "legacy.txt")
+
+	for _, name := range legacyContainers {
+		output := filepath.Join(t.TempDir(), "legacy.txt")
+		metadata, err := NewFileProcessor().DecryptFileWithMetadata(filepath.Join("testdata", name), output, legacyKey)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if metadata.HashVersion != HashVersionLegacy {
+			t.Errorf("%s: hash version %d, want %d", name, metadata.HashVersion, HashVersionLegacy)
+		}
+		got, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: decrypted data differs from legacy.txt", name)
+		}
+	}
+}
+
+func TestDecryptLegacyContainerTampered(t *testing.T) {
+	for _, name := range legacyContainers {
+		path := copyTestdata(t, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[len(data)-1] ^= 0x01
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		output := filepath.Join(t.TempDir(), "legacy.txt")
+		if _, err := NewFileProcessor().DecryptFileWithMetadata(path, output, legacyKey); err == nil {
+			t.Errorf("%s: tampered legacy container was decrypted", name)
+		}
+	}
+}
+
+func TestRekeyUpgradesLegacyHash(t *testing.T) {
+	want := readTestdata(t, "legacy.txt")
+	newKey := bytes.Repeat([]byte{0x42}, 32)
+
+	for _, name := range legacyContainers {
+		path := copyTestdata(t, name)
+		fp := NewFileProcessor()
+		if err := fp.RekeyFile(path, legacyKey, newKey, ""); err != nil {
+			t.Fatalf("%s: RekeyFile: %v", name, err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		metadata, encryptedData, err := ExtractFromEncryptedFile(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metadata.HashVersion != CurrentHashVersion {
+			t.Errorf("%s: hash version %d after rekey, want %d", name, metadata.HashVersion, CurrentHashVersion)
+		}
+		if sum := fmt.Sprintf("%x", stdsha256.Sum256(encryptedData)); metadata.Hash != sum {
+			t.Errorf("%s: hash %s after rekey, want %s", name, metadata.Hash, sum)
+		}
+
+		output := filepath.Join(t.TempDir(), "legacy.txt")
+		if _, err := fp.DecryptFileWithMetadata(path, output, newKey); err != nil {
+			t.Fatalf("%s: decrypt after rekey: %v", name, err)
+		}
+		got, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: decrypted data differs from legacy.txt after rekey", name)
+		}
+	}
+}
+
+// TestNewContainersUseCurrentHash checks that new containers record the hash
+// version and store the standard SHA-256 digest of the ciphertext.
+func TestNewContainersUseCurrentHash(t *testing.T) {
+	input := filepath.Join("testdata", "legacy.txt")
+	output := filepath.Join(t.TempDir(), "legacy.txt.enc")
+	if err := NewFileProcessor().EncryptFileWithMetadata(input, output, "LEA-PCBC", legacyKey); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata, encryptedData, err := ExtractFromEncryptedFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.HashVersion != CurrentHashVersion {
+		t.Errorf("hash version %d, want %d", metadata.HashVersion, CurrentHashVersion)
+	}
+	if sum := fmt.Sprintf("%x", stdsha256.Sum256(encryptedData)); metadata.Hash != sum {
+		t.Errorf("hash %s, want %s", metadata.Hash, sum)
+	}
+}
@@ -2,35 +2,63 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"os"
+	"path/filepath"
 	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+)
+
+// Hash versions. Containers without hash_version were written before the
+// SHA-256 implementation hashed every block, and their Hash holds the legacy
+// digest (see sha256.NewLegacy). RekeyFile upgrades them.
+const (
+	HashVersionLegacy  = 0
+	HashVersionSHA256  = 1
+	CurrentHashVersion = HashVersionSHA256
 )
 
+// MaxHeaderSize bounds the metadata JSON of a container. Network transfers
+// send the header as a single METADATA frame, so it must not exceed the frame
+// limit there (network.MaxPacketSize).
+const MaxHeaderSize = 64 * 1024
+
+var ErrHeaderTooLarge = errors.New("container header too large")
+
 type Metadata struct {
-	Filename            string    `json:"filename"`
-	Size                int64     `json:"size"`
-	Timestamp           time.Time `json:"timestamp"`
-	EncryptionAlgorithm string    `json:"encryption_algorithm"`
-	HashAlgorithm       string    `json:"hash_algorithm,omitempty"`
-	Hash                string    `json:"hash,omitempty"`
-	IV                  string    `json:"iv,omitempty"`
-	KeyInfo             string    `json:"key_info,omitempty"`
+	Filename            string      `json:"filename"`
+	Size                int64       `json:"size"`
+	Timestamp           time.Time   `json:"timestamp"`
+	EncryptionAlgorithm string      `json:"encryption_algorithm"`
+	HashAlgorithm       string      `json:"hash_algorithm,omitempty"`
+	Hash                string      `json:"hash,omitempty"`
+	HashVersion         int         `json:"hash_version,omitempty"`
+	IV                  string      `json:"iv,omitempty"`
+	KeyInfo             string      `json:"key_info,omitempty"`
+	KeyWrapAlgorithm    string      `json:"key_wrap_algorithm,omitempty"`
+	WrappedKey          string      `json:"wrapped_key,omitempty"`
+	Recipients          []Recipient `json:"recipients,omitempty"`
+	Signature           *Signature  `json:"signature,omitempty"`
 }
 
-func NewMetadata(filepath string, encAlgo string, hashAlgo string, hash string, iv []byte) (*Metadata, error) {
-	fileInfo, err := os.Stat(filepath)
+func NewMetadata(path string, encAlgo string, hashAlgo string, hash string, iv []byte) (*Metadata, error) {
+	fileInfo, err := os.Stat(path)
 	if err != nil {
 		return nil, err
 	}
 
 	metadata := &Metadata{
-		Filename:            filepath,
+		Filename:            filepath.Base(path),
 		Size:                fileInfo.Size(),
 		Timestamp:           time.Now().UTC(),
 		EncryptionAlgorithm: encAlgo,
 		HashAlgorithm:       hashAlgo,
 		Hash:                hash,
+		HashVersion:         CurrentHashVersion,
 	}
 
 	if iv != nil {
@@ -40,6 +68,21 @@ func NewMetadata(filepath string, encAlgo string, hashAlgo string, hash string,
 	return metadata, nil
 }
 
+// NewHash returns the hash that Hash was computed with.
+func (m *Metadata) NewHash() hash.Hash {
+	if m.HashVersion == HashVersionLegacy {
+		return sha256.NewLegacy()
+	}
+	return sha256.New()
+}
+
+// HashOf returns the hex digest of encryptedData as stored in Hash.
+func (m *Metadata) HashOf(encryptedData []byte) string {
+	h := m.NewHash()
+	h.Write(encryptedData)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
 func (m *Metadata) ToJSON() ([]byte, error) {
 	return json.MarshalIndent(m, "", "  ")
 }
@@ -63,10 +106,28 @@ func (m *Metadata) WriteToFile(filepath string) error {
 
 func (m *Metadata) AddToEncryptedFile(metadataPath []byte, encryptedData []byte) ([]byte, error) {
 
+	header, err := m.ContainerHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, len(header)+len(encryptedData))
+	copy(result, header)
+	copy(result[len(header):], encryptedData)
+
+	return result, nil
+}
+
+// ContainerHeader returns the length-prefixed metadata that precedes the
+// ciphertext in an encrypted file.
+func (m *Metadata) ContainerHeader() ([]byte, error) {
 	metadataJSON, err := m.ToJSON()
 	if err != nil {
 		return nil, err
 	}
+	if len(metadataJSON) > MaxHeaderSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrHeaderTooLarge, len(metadataJSON), MaxHeaderSize)
+	}
 
 	header := make([]byte, 4+len(metadataJSON))
 
@@ -78,11 +139,7 @@ func (m *Metadata) AddToEncryptedFile(metadataPath []byte, encryptedData []byte)
 
 	copy(header[4:], metadataJSON)
 
-	result := make([]byte, len(header)+len(encryptedData))
-	copy(result, header)
-	copy(result[len(header):], encryptedData)
-
-	return result, nil
+	return header, nil
 }
 
 func ExtractFromEncryptedFile(data []byte) (*Metadata, []byte, error) {
@@ -92,18 +149,49 @@ func ExtractFromEncryptedFile(data []byte) (*Metadata, []byte, error) {
 
 	metadataLen := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16 | uint32(data[3])<<24
 
-	if len(data) < int(4+metadataLen) {
+	// compared in 64 bits, 4+metadataLen would wrap around in uint32
+	if uint64(metadataLen) > uint64(len(data)-4) {
 		return nil, nil, fmt.Errorf("invalid metadata length")
 	}
 
-	metadataJSON := data[4 : 4+metadataLen]
+	metadataJSON := data[4 : 4+int(metadataLen)]
 
 	metadata, err := FromJSON(metadataJSON)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	encryptedData := data[4+metadataLen:]
+	encryptedData := data[4+int(metadataLen):]
 
 	return metadata, encryptedData, nil
 }
+
+func ReadMetadata(path string) (*Metadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var header [4]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil {
+		return nil, fmt.Errorf("data too short for metadata header")
+	}
+
+	metadataLen := uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16 | uint32(header[3])<<24
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if int64(metadataLen) > fileInfo.Size()-4 {
+		return nil, fmt.Errorf("invalid metadata length")
+	}
+
+	metadataJSON := make([]byte, metadataLen)
+	if _, err := io.ReadFull(file, metadataJSON); err != nil {
+		return nil, fmt.Errorf("invalid metadata length")
+	}
+
+	return FromJSON(metadataJSON)
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestExtractFromEncryptedFileLength(t *testing.T) {
+	metadata := &Metadata{Filename: "report.txt", Size: 5, EncryptionAlgorithm: "LEA"}
+	metadataJSON, err := metadata.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		length  uint32
+		wantErr bool
+	}{
+		{"exact header", uint32(len(metadataJSON)), false},
+		{"one byte past the data", uint32(len(metadataJSON)) + 6, true},
+		// 4+length wraps around to a small value in uint32
+		{"wraps to 1", 0xFFFFFFFD, true},
+		{"wraps to 0", 0xFFFFFFFC, true},
+		{"maximum length", 0xFFFFFFFF, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := binary.LittleEndian.AppendUint32(nil, tt.length)
+			data = append(data, metadataJSON...)
+			data = append(data, "ciphe"...)
+
+			metadata, encryptedData, err := ExtractFromEncryptedFile(data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if metadata.Filename != "report.txt" || string(encryptedData) != "ciphe" {
+				t.Errorf("got %q and %q", metadata.Filename, encryptedData)
+			}
+		})
+	}
+}
+
+func TestVerifyEncryptedHashShortHash(t *testing.T) {
+	for _, hash := range []string{"", "ab", "0123456789abcde"} {
+		metadata := &Metadata{Filename: "report.txt", Size: 5, EncryptionAlgorithm: "LEA", Hash: hash}
+
+		err := NewFileProcessor().verifyEncryptedHash("report.txt.enc", metadata, []byte("ciphe"))
+		if hash == "" && err != nil {
+			t.Errorf("empty hash: %v", err)
+		}
+		if hash != "" && err == nil {
+			t.Errorf("hash %q: expected a verification error", hash)
+		}
+	}
+}
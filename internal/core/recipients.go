@@ -0,0 +1,105 @@
+package core
+
+import (
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/keywrap"
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+	"github.com/AleksaS003/zastitaprojekat/internal/keypair"
+)
+
+const recipientKDFInfo = "zastitaprojekat recipient key wrap v1"
+
+var ErrNotARecipient = errors.New("file is not encrypted for this identity")
+
+// Recipient holds the data key wrapped for one X25519 public key. Each entry
+// uses its own ephemeral key pair.
+type Recipient struct {
+	Fingerprint  string `json:"fingerprint"`
+	EphemeralKey string `json:"ephemeral_key"`
+	WrappedKey   string `json:"wrapped_key"`
+}
+
+// AddRecipient wraps dataKey for publicKey using X25519 ECDH and HKDF-SHA256.
+func (m *Metadata) AddRecipient(publicKey *ecdh.PublicKey, dataKey []byte) error {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	shared, err := ephemeral.ECDH(publicKey)
+	if err != nil {
+		return fmt.Errorf("key agreement failed: %w", err)
+	}
+
+	kek, err := recipientKEK(shared, ephemeral.PublicKey().Bytes(), publicKey.Bytes())
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := keywrap.Wrap(kek, dataKey)
+	if err != nil {
+		return fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	m.Recipients = append(m.Recipients, Recipient{
+		Fingerprint:  keypair.Fingerprint(publicKey.Bytes()),
+		EphemeralKey: hex.EncodeToString(ephemeral.PublicKey().Bytes()),
+		WrappedKey:   hex.EncodeToString(wrapped),
+	})
+	m.KeyWrapAlgorithm = KeyWrapAlgorithm
+	return nil
+}
+
+// RecipientDataKey finds the entry for identity and unwraps the data key.
+func (m *Metadata) RecipientDataKey(identity *ecdh.PrivateKey) ([]byte, error) {
+	publicKey := identity.PublicKey().Bytes()
+	fingerprint := keypair.Fingerprint(publicKey)
+
+	for _, recipient := range m.Recipients {
+		if recipient.Fingerprint != fingerprint {
+			continue
+		}
+
+		ephemeralBytes, err := hex.DecodeString(recipient.EphemeralKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ephemeral key in metadata: %w", err)
+		}
+		ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ephemeral key in metadata: %w", err)
+		}
+		wrapped, err := hex.DecodeString(recipient.WrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wrapped key in metadata: %w", err)
+		}
+
+		shared, err := identity.ECDH(ephemeral)
+		if err != nil {
+			return nil, fmt.Errorf("key agreement failed: %w", err)
+		}
+
+		kek, err := recipientKEK(shared, ephemeralBytes, publicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return keywrap.Unwrap(kek, wrapped)
+	}
+
+	return nil, fmt.Errorf("%w (identity %s)", ErrNotARecipient, fingerprint)
+}
+
+func recipientKEK(shared, ephemeralPublic, recipientPublic []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeralPublic...), recipientPublic...)
+	kek, err := hkdf.Key(sha256.New, shared, salt, recipientKDFInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key encryption key: %w", err)
+	}
+	return kek, nil
+}
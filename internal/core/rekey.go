@@ -0,0 +1,254 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+// RekeyFile moves an existing .enc container from oldKey to newKey. Files with
+// a wrapped data key only get a new header; older files and algorithm changes
+// are re-encrypted in memory. Either way the new header carries the current
+// hash version, so re-keying also upgrades containers with a legacy hash. The
+// original is replaced only after the new container has been verified.
+func (fp *FileProcessor) RekeyFile(path string, oldKey, newKey []byte, algorithm string) error {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Error(logger.REKEY, "Failed to read input file", map[string]interface{}{
+			"file_path": path,
+			"error":     err.Error(),
+		})
+		return fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	metadata, encryptedData, err := ExtractFromEncryptedFile(data)
+	if err != nil {
+		logger.Error(logger.REKEY, "Failed to extract metadata", map[string]interface{}{
+			"file_path": path,
+			"error":     err.Error(),
+		})
+		return fmt.Errorf("failed to extract metadata: %w", err)
+	}
+
+	if algorithm == "" {
+		algorithm = metadata.EncryptionAlgorithm
+	}
+
+	logger.Info(logger.REKEY, "Starting file re-key", true, map[string]interface{}{
+		"file_path":     path,
+		"old_algorithm": metadata.EncryptionAlgorithm,
+		"new_algorithm": algorithm,
+		"old_key_size":  len(oldKey) * 8,
+		"new_key_size":  len(newKey) * 8,
+	})
+
+	if err := metadata.CheckKey(oldKey); err != nil {
+		logger.Error(logger.REKEY, "Old key does not match the key used for encryption", map[string]interface{}{
+			"file_path":       path,
+			"file_key_info":   metadata.KeyInfo,
+			"supplied_key_fp": KeyFingerprint(oldKey),
+		})
+		return err
+	}
+
+	if err := fp.verifyEncryptedHash(path, metadata, encryptedData); err != nil {
+		return err
+	}
+
+	if metadata.Signature != nil {
+		logger.Warning(logger.REKEY, "Embedded signature will be removed, the header changes", true, map[string]interface{}{
+			"file_path": path,
+			"signer":    metadata.Signature.Signer,
+		})
+	}
+
+	mode := "reencrypt"
+	var finalData []byte
+	if metadata.WrappedKey != "" && algorithm == metadata.EncryptionAlgorithm {
+		mode = "rewrap"
+		finalData, err = fp.rewrapContainer(metadata, encryptedData, oldKey, newKey)
+	} else {
+		finalData, err = fp.reencryptContainer(path, metadata, encryptedData, oldKey, newKey, algorithm)
+	}
+	if err != nil {
+		logger.Error(logger.REKEY, "Failed to re-key container", map[string]interface{}{
+			"file_path": path,
+			"mode":      mode,
+			"error":     err.Error(),
+		})
+		return err
+	}
+
+	if err := replaceFileAtomically(path, finalData, fileInfo.Mode().Perm()); err != nil {
+		logger.Error(logger.REKEY, "Failed to replace original file", map[string]interface{}{
+			"file_path": path,
+			"error":     err.Error(),
+		})
+		return err
+	}
+
+	logger.Info(logger.REKEY, "File re-keyed successfully", true, map[string]interface{}{
+		"file_path":     path,
+		"algorithm":     algorithm,
+		"mode":          mode,
+		"original_size": metadata.Size,
+		"old_size":      len(data),
+		"new_size":      len(finalData),
+	})
+
+	return nil
+}
+
+// rewrapContainer re-wraps the per-file data key under newKey. Only the header
+// changes; the ciphertext is copied as is. A legacy hash is replaced with the
+// current digest of the (already verified) ciphertext.
+func (fp *FileProcessor) rewrapContainer(metadata *Metadata, encryptedData []byte, oldKey, newKey []byte) ([]byte, error) {
+	newMetadata, err := metadata.Rewrap(oldKey, newKey)
+	if err != nil {
+		return nil, err
+	}
+	if newMetadata.HashVersion != CurrentHashVersion {
+		newMetadata.HashAlgorithm = "SHA-256"
+		newMetadata.Hash = sha256.HashToString(sha256.HashBytes(encryptedData))
+		newMetadata.HashVersion = CurrentHashVersion
+	}
+
+	finalData, err := newMetadata.AddToEncryptedFile(nil, encryptedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add metadata header: %w", err)
+	}
+
+	return finalData, nil
+}
+
+// reencryptContainer decrypts the whole file and encrypts it again under a new
+// data key. Used when the algorithm changes or the file predates key wrapping.
+func (fp *FileProcessor) reencryptContainer(path string, metadata *Metadata, encryptedData []byte, oldKey, newKey []byte, algorithm string) ([]byte, error) {
+	if len(metadata.Recipients) > 0 {
+		return nil, fmt.Errorf("cannot re-encrypt a file with %d recipient(s), their wrapped keys would be lost", len(metadata.Recipients))
+	}
+
+	oldDataKey, err := metadata.DataKey(oldKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := fp.decryptData(metadata, encryptedData, oldDataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(plaintext)) != metadata.Size {
+		return nil, fmt.Errorf("decrypted size %d does not match original size %d (wrong old key?)", len(plaintext), metadata.Size)
+	}
+
+	dataKey, err := newDataKey(newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	newEncryptedData, iv, err := fp.encryptData(plaintext, algorithm, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	newMetadata := *metadata
+	newMetadata.Timestamp = time.Now().UTC()
+	newMetadata.Signature = nil
+	newMetadata.EncryptionAlgorithm = algorithm
+	newMetadata.HashAlgorithm = "SHA-256"
+	newMetadata.Hash = sha256.HashToString(sha256.HashBytes(newEncryptedData))
+	newMetadata.HashVersion = CurrentHashVersion
+	newMetadata.IV = ""
+	if iv != nil {
+		newMetadata.IV = fmt.Sprintf("%x", iv)
+	}
+	if err := newMetadata.SetDataKey(newKey, dataKey); err != nil {
+		return nil, err
+	}
+
+	finalData, err := newMetadata.AddToEncryptedFile(nil, newEncryptedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add metadata header: %w", err)
+	}
+
+	if err := fp.verifyRekeyedData(path, finalData, newKey, plaintext); err != nil {
+		return nil, err
+	}
+
+	return finalData, nil
+}
+
+func (fp *FileProcessor) verifyRekeyedData(path string, container []byte, key []byte, plaintext []byte) error {
+	metadata, encryptedData, err := ExtractFromEncryptedFile(container)
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	if err := fp.verifyEncryptedHash(path, metadata, encryptedData); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	dataKey, err := metadata.DataKey(key)
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	decrypted, err := fp.decryptData(metadata, encryptedData, dataKey)
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		return fmt.Errorf("verification failed: re-encrypted data does not match original plaintext")
+	}
+
+	return nil
+}
+
+func replaceFileAtomically(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".rekey-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
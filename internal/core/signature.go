@@ -0,0 +1,226 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+	"github.com/AleksaS003/zastitaprojekat/internal/keypair"
+)
+
+const (
+	SignatureAlgorithm      = "Ed25519"
+	detachedSignatureLabel  = "zastitaprojekat detached signature v1"
+	containerSignatureLabel = "zastitaprojekat container signature v1"
+)
+
+var (
+	ErrNotSigned        = errors.New("file is not signed")
+	ErrInvalidSignature = errors.New("signature verification failed")
+	ErrUntrustedSigner  = errors.New("signature is valid but the signer is not trusted")
+)
+
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	Signer    string `json:"signer"`
+	PublicKey string `json:"public_key"`
+	Value     string `json:"value"`
+}
+
+// DetachedSignature is stored next to the signed file as <file>.sig.
+type DetachedSignature struct {
+	Signature
+	File    string    `json:"file"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+// SignFile signs the SHA-256 digest of the file at path.
+func SignFile(path string, key ed25519.PrivateKey) (*DetachedSignature, error) {
+	digest, size, err := hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DetachedSignature{
+		Signature: newSignature(key, detachedSignatureLabel, digest),
+		File:      path,
+		Size:      size,
+		Created:   time.Now().UTC(),
+	}, nil
+}
+
+// VerifyFileSignature checks a detached signature and returns the signer
+// fingerprint when it was made by one of the trusted keys.
+func VerifyFileSignature(path string, sig *DetachedSignature, trusted []ed25519.PublicKey) (string, error) {
+	digest, _, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+	return sig.Signature.verify(detachedSignatureLabel, digest, trusted)
+}
+
+func WriteDetachedSignature(path string, sig *DetachedSignature) error {
+	data, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func ReadDetachedSignature(path string) (*DetachedSignature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature file: %w", err)
+	}
+
+	var sig DetachedSignature
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return nil, fmt.Errorf("failed to parse signature file: %w", err)
+	}
+	return &sig, nil
+}
+
+// Sign embeds a signature over the metadata header (without the signature
+// itself) and the ciphertext.
+func (m *Metadata) Sign(key ed25519.PrivateKey, encryptedData []byte) error {
+	digest, err := m.containerDigest(encryptedData)
+	if err != nil {
+		return err
+	}
+
+	m.SignDigest(key, digest)
+	return nil
+}
+
+// SignDigest embeds a signature over a digest produced by ContainerHasher.
+func (m *Metadata) SignDigest(key ed25519.PrivateKey, digest []byte) {
+	sig := newSignature(key, containerSignatureLabel, digest)
+	m.Signature = &sig
+}
+
+// VerifySignature checks the embedded signature and returns the signer
+// fingerprint when it was made by one of the trusted keys.
+func (m *Metadata) VerifySignature(encryptedData []byte, trusted []ed25519.PublicKey) (string, error) {
+	digest, err := m.containerDigest(encryptedData)
+	if err != nil {
+		return "", err
+	}
+	return m.VerifyDigest(digest, trusted)
+}
+
+// VerifyDigest is VerifySignature for a digest produced by ContainerHasher.
+func (m *Metadata) VerifyDigest(digest []byte, trusted []ed25519.PublicKey) (string, error) {
+	if m.Signature == nil {
+		return "", ErrNotSigned
+	}
+	return m.Signature.verify(containerSignatureLabel, digest, trusted)
+}
+
+// VerifyContainerSignature reads an .enc file and verifies its embedded signature.
+func VerifyContainerSignature(path string, trusted []ed25519.PublicKey) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	metadata, encryptedData, err := ExtractFromEncryptedFile(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract metadata: %w", err)
+	}
+
+	return metadata.VerifySignature(encryptedData, trusted)
+}
+
+func (m *Metadata) containerDigest(encryptedData []byte) ([]byte, error) {
+	h, err := m.ContainerHasher()
+	if err != nil {
+		return nil, err
+	}
+	h.Write(encryptedData)
+	return h.Sum(nil), nil
+}
+
+// ContainerHasher returns a hash that has already consumed the unsigned
+// header. Writing the ciphertext to it yields the digest the container
+// signature covers, without holding the ciphertext in memory.
+func (m *Metadata) ContainerHasher() (hash.Hash, error) {
+	unsigned := *m
+	unsigned.Signature = nil
+
+	header, err := unsigned.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write(header)
+	return h, nil
+}
+
+func newSignature(key ed25519.PrivateKey, label string, digest []byte) Signature {
+	publicKey := key.Public().(ed25519.PublicKey)
+	return Signature{
+		Algorithm: SignatureAlgorithm,
+		Signer:    keypair.Fingerprint(publicKey),
+		PublicKey: hex.EncodeToString(publicKey),
+		Value:     hex.EncodeToString(ed25519.Sign(key, signedMessage(label, digest))),
+	}
+}
+
+func (s *Signature) verify(label string, digest []byte, trusted []ed25519.PublicKey) (string, error) {
+	if len(trusted) == 0 {
+		return "", fmt.Errorf("no trusted public keys given")
+	}
+	if s.Algorithm != SignatureAlgorithm {
+		return "", fmt.Errorf("unsupported signature algorithm: %s", s.Algorithm)
+	}
+
+	publicKey, err := hex.DecodeString(s.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return "", fmt.Errorf("%w: invalid public key", ErrInvalidSignature)
+	}
+	value, err := hex.DecodeString(s.Value)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid signature encoding", ErrInvalidSignature)
+	}
+
+	if !ed25519.Verify(publicKey, signedMessage(label, digest), value) {
+		return "", ErrInvalidSignature
+	}
+
+	signer := keypair.Fingerprint(publicKey)
+	for _, key := range trusted {
+		if bytes.Equal(key, publicKey) {
+			return signer, nil
+		}
+	}
+	return signer, fmt.Errorf("%w (signer %s)", ErrUntrustedSigner, signer)
+}
+
+func signedMessage(label string, digest []byte) []byte {
+	return append([]byte(label), digest...)
+}
+
+func hashFile(path string) ([]byte, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read input file: %w", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read input file: %w", err)
+	}
+	return h.Sum(nil), size, nil
+}
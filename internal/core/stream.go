@@ -0,0 +1,525 @@
+package core
+
+import (
+	"crypto/cipher"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/lea"
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/pcbc"
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+const streamBufferSize = 32 * 1024
+
+var ErrFileChanged = errors.New("file changed while it was being sent")
+
+// EncryptedStream produces an encrypted container from a plaintext file on
+// the fly, without writing the ciphertext to disk. Encryption is
+// deterministic for a fixed data key and IV, so CopyTo can produce the same
+// bytes again, e.g. to resume an interrupted transfer at an offset.
+type EncryptedStream struct {
+	Metadata *Metadata
+	// Size is the length of the container: header and ciphertext.
+	Size int64
+
+	path      string
+	algorithm string
+	dataKey   []byte
+	iv        []byte
+	header    []byte
+	plainSize int64
+	modTime   time.Time
+}
+
+// NewEncryptedStream prepares a container for inputPath. The ciphertext hash
+// (and the signature, when a signing key is set) must be in the header, so
+// the file is encrypted once here only to compute them.
+func (fp *FileProcessor) NewEncryptedStream(inputPath, algorithm string, key []byte) (*EncryptedStream, error) {
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	dataKey, err := newDataKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var iv []byte
+	if algorithm == "LEA-PCBC" {
+		if iv, err = pcbc.GenerateIV(lea.BlockSize); err != nil {
+			return nil, fmt.Errorf("failed to generate IV: %w", err)
+		}
+	}
+
+	s := &EncryptedStream{
+		path:      inputPath,
+		algorithm: algorithm,
+		dataKey:   dataKey,
+		iv:        iv,
+		plainSize: info.Size(),
+		modTime:   info.ModTime(),
+	}
+
+	logger.Info(logger.ENCRYPT, "Starting stream encryption", true, map[string]interface{}{
+		"input_file": inputPath,
+		"algorithm":  algorithm,
+		"file_size":  info.Size(),
+		"key_size":   len(dataKey) * 8,
+	})
+
+	h := sha256.New()
+	encryptedSize, err := s.encrypt(h)
+	if err != nil {
+		return nil, err
+	}
+	hashStr := fmt.Sprintf("%x", h.Sum(nil))
+
+	metadata, err := NewMetadata(inputPath, algorithm, "SHA-256", hashStr, iv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata: %w", err)
+	}
+	if err := metadata.SetDataKey(key, dataKey); err != nil {
+		return nil, err
+	}
+
+	if fp.signingKey != nil {
+		digest, err := metadata.ContainerHasher()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.encrypt(digest); err != nil {
+			return nil, err
+		}
+		metadata.SignDigest(fp.signingKey, digest.Sum(nil))
+	}
+
+	if s.header, err = metadata.ContainerHeader(); err != nil {
+		return nil, fmt.Errorf("failed to add metadata header: %w", err)
+	}
+	s.Metadata = metadata
+	s.Size = int64(len(s.header)) + encryptedSize
+
+	logger.Info(logger.VERIFY_HASH, "Encrypted stream hash calculated", true, map[string]interface{}{
+		"file":           inputPath,
+		"hash":           hashStr,
+		"hash_type":      "encrypted_data",
+		"encrypted_size": s.Size,
+		"signed":         metadata.Signature != nil,
+	})
+
+	return s, nil
+}
+
+// CopyTo writes the container to w, skipping the first offset bytes, and
+// returns the number of bytes written to w.
+func (s *EncryptedStream) CopyTo(w io.Writer, offset int64) (int64, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read input file: %w", err)
+	}
+	if info.Size() != s.plainSize || !info.ModTime().Equal(s.modTime) {
+		return 0, ErrFileChanged
+	}
+
+	out := &skipWriter{w: w, skip: offset}
+	if _, err := out.Write(s.header); err != nil {
+		return out.written, err
+	}
+
+	h := sha256.New()
+	if _, err := s.encrypt(io.MultiWriter(out, h)); err != nil {
+		return out.written, err
+	}
+	if fmt.Sprintf("%x", h.Sum(nil)) != s.Metadata.Hash {
+		return out.written, ErrFileChanged
+	}
+	return out.written, nil
+}
+
+// encrypt writes the ciphertext of the whole file to w and returns its length.
+func (s *EncryptedStream) encrypt(w io.Writer) (int64, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read input file: %w", err)
+	}
+	defer file.Close()
+
+	counter := &countingWriter{w: w}
+	enc, err := NewEncryptWriter(counter, s.algorithm, s.dataKey, s.iv)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.CopyBuffer(enc, file, make([]byte, streamBufferSize)); err != nil {
+		return counter.n, fmt.Errorf("encryption failed: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return counter.n, fmt.Errorf("encryption failed: %w", err)
+	}
+	return counter.n, nil
+}
+
+// ContainerWriter decrypts a container while it is being written: the
+// header is parsed first, then the ciphertext is hashed and decrypted into
+// out. Close checks the signature (when required) and the hash; until then
+// the plaintext written to out must not be trusted.
+type ContainerWriter struct {
+	Metadata *Metadata
+	// Signer is the fingerprint of the verified signer after Close.
+	Signer string
+
+	out     io.Writer
+	key     []byte
+	trusted []ed25519.PublicKey
+	header  []byte
+	hash    hash.Hash
+	digest  hash.Hash
+	plain   io.WriteCloser
+}
+
+// NewContainerWriter decrypts with the data key wrapped under master key key.
+func NewContainerWriter(out io.Writer, key []byte) *ContainerWriter {
+	return &ContainerWriter{out: out, key: key}
+}
+
+// NewContainerChecker verifies the hash (and, with RequireSignature, the
+// signature) of a container without a key and without decrypting it, for
+// services that only store encrypted files.
+func NewContainerChecker() *ContainerWriter {
+	return &ContainerWriter{out: io.Discard}
+}
+
+// RequireSignature makes Close reject containers not signed by a trusted key.
+func (c *ContainerWriter) RequireSignature(trusted []ed25519.PublicKey) {
+	c.trusted = trusted
+}
+
+func (c *ContainerWriter) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if c.plain == nil {
+		var err error
+		if p, err = c.readHeader(p); err != nil {
+			return 0, err
+		}
+	}
+	if len(p) == 0 {
+		return n, nil
+	}
+
+	c.hash.Write(p)
+	if c.digest != nil {
+		c.digest.Write(p)
+	}
+	if _, err := c.plain.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// readHeader buffers the header and returns the part of p after it.
+func (c *ContainerWriter) readHeader(p []byte) ([]byte, error) {
+	need := 4
+	if len(c.header) >= 4 {
+		need += int(uint32(c.header[0]) | uint32(c.header[1])<<8 | uint32(c.header[2])<<16 | uint32(c.header[3])<<24)
+	}
+
+	for len(p) > 0 && len(c.header) < need {
+		take := min(need-len(c.header), len(p))
+		c.header = append(c.header, p[:take]...)
+		p = p[take:]
+
+		if len(c.header) == 4 {
+			metadataLen := uint32(c.header[0]) | uint32(c.header[1])<<8 | uint32(c.header[2])<<16 | uint32(c.header[3])<<24
+			if metadataLen == 0 {
+				return nil, fmt.Errorf("invalid metadata length")
+			}
+			if metadataLen > MaxHeaderSize {
+				return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrHeaderTooLarge, metadataLen, MaxHeaderSize)
+			}
+			need += int(metadataLen)
+		}
+	}
+	if len(c.header) < need || need == 4 {
+		return p, nil
+	}
+
+	metadata, err := FromJSON(c.header[4:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract metadata: %w", err)
+	}
+
+	if len(c.trusted) > 0 {
+		if c.digest, err = metadata.ContainerHasher(); err != nil {
+			return nil, err
+		}
+	}
+	if c.key == nil {
+		c.plain = discardCloser{}
+	} else {
+		if err := metadata.CheckKey(c.key); err != nil {
+			return nil, err
+		}
+		dataKey, err := metadata.DataKey(c.key)
+		if err != nil {
+			return nil, err
+		}
+		if c.plain, err = NewDecryptWriter(c.out, metadata.EncryptionAlgorithm, dataKey); err != nil {
+			return nil, err
+		}
+	}
+	c.hash = metadata.NewHash()
+	c.Metadata = metadata
+	c.header = nil
+	return p, nil
+}
+
+func (c *ContainerWriter) Close() error {
+	if c.plain == nil {
+		return fmt.Errorf("data too short for metadata header")
+	}
+
+	if c.digest != nil {
+		signer, err := c.Metadata.VerifyDigest(c.digest.Sum(nil), c.trusted)
+		c.Signer = signer
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Metadata.Hash != "" {
+		receivedHash := fmt.Sprintf("%x", c.hash.Sum(nil))
+		if receivedHash != c.Metadata.Hash {
+			logger.Error(logger.VERIFY_HASH, "❌ Hash verification FAILED - file may be corrupted in transit", map[string]interface{}{
+				"file":          c.Metadata.Filename,
+				"expected_hash": c.Metadata.Hash,
+				"actual_hash":   receivedHash,
+			})
+			return fmt.Errorf("hash verification failed: file corrupted during transfer")
+		}
+	}
+
+	if err := c.plain.Close(); err != nil {
+		return fmt.Errorf("decryption failed: %w", err)
+	}
+	return nil
+}
+
+// NewEncryptWriter encrypts everything written to it into w. LEA-PCBC
+// ciphertext starts with the IV, as in encryptData. Close pads and writes
+// the last block.
+func NewEncryptWriter(w io.Writer, algorithm string, key, iv []byte) (io.WriteCloser, error) {
+	block, err := lea.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LEA cipher: %w", err)
+	}
+
+	var mode cipher.BlockMode
+	switch algorithm {
+	case "LEA":
+		mode = ecbMode{block: block}
+	case "LEA-PCBC":
+		if mode, err = pcbc.NewEncrypter(block, iv); err != nil {
+			return nil, fmt.Errorf("failed to create PCBC cipher: %w", err)
+		}
+		if _, err := w.Write(iv); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+
+	return &encryptWriter{w: w, mode: mode}, nil
+}
+
+// NewDecryptWriter is the inverse of NewEncryptWriter. The last block is
+// held back until Close, which removes the padding.
+func NewDecryptWriter(w io.Writer, algorithm string, key []byte) (io.WriteCloser, error) {
+	block, err := lea.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LEA cipher: %w", err)
+	}
+
+	d := &decryptWriter{w: w}
+	switch algorithm {
+	case "LEA":
+		d.mode = ecbMode{block: block, decrypt: true}
+	case "LEA-PCBC":
+		d.newMode = func(iv []byte) (cipher.BlockMode, error) {
+			return pcbc.NewDecrypter(block, iv)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+	return d, nil
+}
+
+type encryptWriter struct {
+	w    io.Writer
+	mode cipher.BlockMode
+	buf  []byte
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	e.buf = append(e.buf, p...)
+	full := len(e.buf) - len(e.buf)%e.mode.BlockSize()
+	if full > 0 {
+		e.mode.CryptBlocks(e.buf[:full], e.buf[:full])
+		if _, err := e.w.Write(e.buf[:full]); err != nil {
+			return 0, err
+		}
+		e.buf = append(e.buf[:0], e.buf[full:]...)
+	}
+	return len(p), nil
+}
+
+func (e *encryptWriter) Close() error {
+	blockSize := e.mode.BlockSize()
+	padding := blockSize - len(e.buf)%blockSize
+	for i := 0; i < padding; i++ {
+		e.buf = append(e.buf, byte(padding))
+	}
+
+	e.mode.CryptBlocks(e.buf, e.buf)
+	_, err := e.w.Write(e.buf)
+	e.buf = nil
+	return err
+}
+
+type decryptWriter struct {
+	w       io.Writer
+	mode    cipher.BlockMode
+	newMode func(iv []byte) (cipher.BlockMode, error)
+	buf     []byte
+}
+
+func (d *decryptWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	d.buf = append(d.buf, p...)
+
+	if d.mode == nil {
+		if len(d.buf) < lea.BlockSize {
+			return n, nil
+		}
+		mode, err := d.newMode(d.buf[:lea.BlockSize])
+		if err != nil {
+			return 0, err
+		}
+		d.mode = mode
+		d.buf = append(d.buf[:0], d.buf[lea.BlockSize:]...)
+	}
+
+	// the last block waits for Close because it carries the padding
+	full := len(d.buf) - len(d.buf)%d.mode.BlockSize()
+	if full == len(d.buf) {
+		full -= d.mode.BlockSize()
+	}
+	if full > 0 {
+		d.mode.CryptBlocks(d.buf[:full], d.buf[:full])
+		if _, err := d.w.Write(d.buf[:full]); err != nil {
+			return 0, err
+		}
+		d.buf = append(d.buf[:0], d.buf[full:]...)
+	}
+	return n, nil
+}
+
+func (d *decryptWriter) Close() error {
+	if d.mode == nil {
+		return fmt.Errorf("ciphertext too short")
+	}
+	blockSize := d.mode.BlockSize()
+	if len(d.buf) != blockSize {
+		return fmt.Errorf("ciphertext length must be multiple of %d", blockSize)
+	}
+
+	d.mode.CryptBlocks(d.buf, d.buf)
+	last := d.buf
+	if padding := int(last[blockSize-1]); padding > 0 && padding <= blockSize {
+		valid := true
+		for _, b := range last[blockSize-padding:] {
+			if int(b) != padding {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			last = last[:blockSize-padding]
+		}
+	}
+
+	_, err := d.w.Write(last)
+	d.buf = nil
+	return err
+}
+
+// ecbMode is the block-by-block mode used by plain "LEA".
+type ecbMode struct {
+	block   cipher.Block
+	decrypt bool
+}
+
+func (m ecbMode) BlockSize() int {
+	return m.block.BlockSize()
+}
+
+func (m ecbMode) CryptBlocks(dst, src []byte) {
+	blockSize := m.block.BlockSize()
+	for i := 0; i < len(src); i += blockSize {
+		if m.decrypt {
+			m.block.Decrypt(dst[i:i+blockSize], src[i:i+blockSize])
+		} else {
+			m.block.Encrypt(dst[i:i+blockSize], src[i:i+blockSize])
+		}
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// skipWriter drops the first skip bytes written to it.
+type skipWriter struct {
+	w       io.Writer
+	skip    int64
+	written int64
+}
+
+func (s *skipWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if s.skip > 0 {
+		if int64(len(p)) <= s.skip {
+			s.skip -= int64(len(p))
+			return n, nil
+		}
+		p = p[s.skip:]
+		s.skip = 0
+	}
+
+	written, err := s.w.Write(p)
+	s.written += int64(written)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// discardCloser stands in for the decrypter when a container is only checked.
+type discardCloser struct{}
+
+func (discardCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (discardCloser) Close() error                { return nil }
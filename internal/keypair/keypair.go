@@ -0,0 +1,136 @@
+package keypair
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+)
+
+const (
+	X25519PrivateKeyType = "ZASTITA X25519 PRIVATE KEY"
+	X25519PublicKeyType  = "ZASTITA X25519 PUBLIC KEY"
+
+	Ed25519PrivateKeyType = "ZASTITA ED25519 PRIVATE KEY"
+	Ed25519PublicKeyType  = "ZASTITA ED25519 PUBLIC KEY"
+)
+
+// GenerateX25519 pravi novi par ključeva za šifrovanje ka primaocu.
+func GenerateX25519() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// GenerateEd25519 pravi novi par ključeva za potpisivanje.
+func GenerateEd25519() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// Fingerprint je kratak identifikator javnog ključa (prvih 8 bajtova SHA-256).
+func Fingerprint(publicKey []byte) string {
+	hash := sha256.HashBytes(publicKey)
+	return fmt.Sprintf("%x", hash[:8])
+}
+
+func SaveX25519PrivateKey(path string, key *ecdh.PrivateKey) error {
+	return writePEM(path, X25519PrivateKeyType, key.Bytes(), 0600)
+}
+
+func SaveX25519PublicKey(path string, key *ecdh.PublicKey) error {
+	return writePEM(path, X25519PublicKeyType, key.Bytes(), 0644)
+}
+
+func LoadX25519PrivateKey(path string) (*ecdh.PrivateKey, error) {
+	data, err := readPEM(path, X25519PrivateKeyType)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 private key in %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func LoadX25519PublicKey(path string) (*ecdh.PublicKey, error) {
+	data, err := readPEM(path, X25519PublicKeyType)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 public key in %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func SaveEd25519PrivateKey(path string, key ed25519.PrivateKey) error {
+	return writePEM(path, Ed25519PrivateKeyType, key.Seed(), 0600)
+}
+
+func SaveEd25519PublicKey(path string, key ed25519.PublicKey) error {
+	return writePEM(path, Ed25519PublicKeyType, key, 0644)
+}
+
+func LoadEd25519PrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := readPEM(path, Ed25519PrivateKeyType)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid Ed25519 private key in %s", path)
+	}
+	return ed25519.NewKeyFromSeed(data), nil
+}
+
+func LoadEd25519PublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := readPEM(path, Ed25519PublicKeyType)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 public key in %s", path)
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// LoadEd25519PublicKeys učitava listu javnih ključeva (npr. dozvoljene potpisnike).
+func LoadEd25519PublicKeys(paths []string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for _, path := range paths {
+		key, err := LoadEd25519PublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func writePEM(path, blockType string, data []byte, perm os.FileMode) error {
+	block := &pem.Block{Type: blockType, Bytes: data}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), perm); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	if block.Type != blockType {
+		return nil, fmt.Errorf("%s contains %q, expected %q", path, block.Type, blockType)
+	}
+
+	return block.Bytes, nil
+}
@@ -0,0 +1,341 @@
+package keystore
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/lea"
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/pcbc"
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+const (
+	formatVersion    = 1
+	kdfName          = "PBKDF2-HMAC-SHA256"
+	kdfIterations    = 100000
+	saltSize         = 16
+	cipherAlgorithm  = "LEA-PCBC"
+	derivedKeyLength = 64
+)
+
+var (
+	ErrInvalidPassword = errors.New("invalid keystore password or corrupted keystore")
+	ErrKeyNotFound     = errors.New("key not found in keystore")
+	ErrKeyExists       = errors.New("key with this name already exists")
+)
+
+type Entry struct {
+	Name        string    `json:"name"`
+	Algorithm   string    `json:"algorithm"`
+	Key         string    `json:"key"`
+	Fingerprint string    `json:"fingerprint"`
+	Created     time.Time `json:"created"`
+}
+
+// fileFormat is the on-disk layout: the entry list is encrypted with
+// LEA-PCBC and authenticated with HMAC-SHA256, both keys derived from the
+// password with PBKDF2.
+type fileFormat struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`
+	Cipher     string `json:"cipher"`
+	Ciphertext string `json:"ciphertext"`
+	MAC        string `json:"mac"`
+}
+
+type Keystore struct {
+	path     string
+	password string
+	entries  map[string]*Entry
+}
+
+func Create(path, password string) (*Keystore, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("keystore already exists: %s", path)
+	}
+
+	ks := &Keystore{
+		path:     path,
+		password: password,
+		entries:  make(map[string]*Entry),
+	}
+
+	if err := ks.Save(); err != nil {
+		return nil, err
+	}
+
+	logger.Info(logger.KEYSTORE, "Keystore created", true, map[string]interface{}{
+		"path": path,
+	})
+
+	return ks, nil
+}
+
+func Open(path, password string) (*Keystore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+
+	var file fileFormat
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse keystore: %w", err)
+	}
+
+	if file.Version != formatVersion || file.KDF != kdfName || file.Cipher != cipherAlgorithm {
+		return nil, fmt.Errorf("unsupported keystore format (version %d, %s, %s)", file.Version, file.KDF, file.Cipher)
+	}
+
+	salt, err := hex.DecodeString(file.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore salt: %w", err)
+	}
+	ciphertext, err := hex.DecodeString(file.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore ciphertext: %w", err)
+	}
+	mac, err := hex.DecodeString(file.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore MAC: %w", err)
+	}
+
+	encKey, macKey, err := deriveKeys(password, salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sha256.VerifyHMAC(macKey, macInput(&file, ciphertext), mac) {
+		logger.Error(logger.KEYSTORE, "Keystore authentication failed", map[string]interface{}{
+			"path": path,
+		})
+		return nil, ErrInvalidPassword
+	}
+
+	cipher, err := pcbc.NewLEAPCBCWithIV(encKey, make([]byte, 16))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := cipher.Decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore: %w", err)
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse keystore entries: %w", err)
+	}
+
+	ks := &Keystore{
+		path:     path,
+		password: password,
+		entries:  make(map[string]*Entry, len(entries)),
+	}
+	for _, entry := range entries {
+		ks.entries[entry.Name] = entry
+	}
+
+	logger.Info(logger.KEYSTORE, "Keystore opened", true, map[string]interface{}{
+		"path":      path,
+		"key_count": len(entries),
+	})
+
+	return ks, nil
+}
+
+func OpenOrCreate(path, password string) (*Keystore, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return Create(path, password)
+	}
+	return Open(path, password)
+}
+
+func (ks *Keystore) Path() string {
+	return ks.path
+}
+
+func (ks *Keystore) Add(name, algorithm string, key []byte) (*Entry, error) {
+	if name == "" {
+		return nil, fmt.Errorf("key name must not be empty")
+	}
+	if _, exists := ks.entries[name]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrKeyExists, name)
+	}
+	if _, err := lea.NewLEA(key); err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Name:        name,
+		Algorithm:   algorithm,
+		Key:         hex.EncodeToString(key),
+		Fingerprint: core.KeyFingerprint(key),
+		Created:     time.Now().UTC(),
+	}
+	ks.entries[name] = entry
+
+	logger.Info(logger.KEYSTORE, "Key added to keystore", true, map[string]interface{}{
+		"path":        ks.path,
+		"name":        name,
+		"algorithm":   algorithm,
+		"key_size":    len(key) * 8,
+		"fingerprint": entry.Fingerprint,
+	})
+
+	return entry, nil
+}
+
+func (ks *Keystore) Generate(name string, size int) (*Entry, error) {
+	key, err := lea.GenerateKey(size)
+	if err != nil {
+		return nil, err
+	}
+	return ks.Add(name, "LEA", key)
+}
+
+func (ks *Keystore) Get(name string) (*Entry, error) {
+	entry, ok := ks.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	return entry, nil
+}
+
+func (ks *Keystore) Key(name string) ([]byte, error) {
+	entry, err := ks.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return entry.KeyBytes()
+}
+
+func (ks *Keystore) Delete(name string) error {
+	if _, ok := ks.entries[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	delete(ks.entries, name)
+
+	logger.Info(logger.KEYSTORE, "Key deleted from keystore", true, map[string]interface{}{
+		"path": ks.path,
+		"name": name,
+	})
+
+	return nil
+}
+
+func (ks *Keystore) List() []*Entry {
+	entries := make([]*Entry, 0, len(ks.entries))
+	for _, entry := range ks.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+func (ks *Keystore) Save() error {
+	plaintext, err := json.Marshal(ks.List())
+	if err != nil {
+		return fmt.Errorf("failed to serialize keystore: %w", err)
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	encKey, macKey, err := deriveKeys(ks.password, salt, kdfIterations)
+	if err != nil {
+		return err
+	}
+
+	cipher, err := pcbc.NewLEAPCBC(encKey)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := cipher.Encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt keystore: %w", err)
+	}
+
+	file := fileFormat{
+		Version:    formatVersion,
+		KDF:        kdfName,
+		Iterations: kdfIterations,
+		Salt:       hex.EncodeToString(salt),
+		Cipher:     cipherAlgorithm,
+		Ciphertext: hex.EncodeToString(ciphertext),
+	}
+	mac := sha256.HMAC(macKey, macInput(&file, ciphertext))
+	file.MAC = hex.EncodeToString(mac[:])
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize keystore: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(ks.path), "."+filepath.Base(ks.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	if err := os.Rename(tmpPath, ks.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+
+	return nil
+}
+
+func (e *Entry) KeyBytes() ([]byte, error) {
+	key, err := hex.DecodeString(e.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %s in keystore: %w", e.Name, err)
+	}
+	return key, nil
+}
+
+func deriveKeys(password string, salt []byte, iterations int) ([]byte, []byte, error) {
+	if iterations <= 0 {
+		return nil, nil, fmt.Errorf("invalid iteration count: %d", iterations)
+	}
+
+	derived, err := pbkdf2.Key(sha256.New, password, salt, iterations, derivedKeyLength)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive keystore keys: %w", err)
+	}
+
+	return derived[:32], derived[32:], nil
+}
+
+func macInput(file *fileFormat, ciphertext []byte) []byte {
+	header := fmt.Sprintf("%d|%s|%d|%s|%s|", file.Version, file.KDF, file.Iterations, file.Salt, file.Cipher)
+	return append([]byte(header), ciphertext...)
+}
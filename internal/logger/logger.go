@@ -36,6 +36,10 @@ const (
 	SERVER_STOP    ActivityType = "SERVER_STOP"
 	FILE_MODIFY    ActivityType = "FILE_MODIFY"
 	FILE_DELETE    ActivityType = "FILE_DELETE"
+	REKEY          ActivityType = "REKEY"
+	KEYSTORE       ActivityType = "KEYSTORE"
+	CLIENT_AUTH    ActivityType = "CLIENT_AUTH"
+	POST_RECEIVE   ActivityType = "POST_RECEIVE"
 )
 
 type LogEntry struct {
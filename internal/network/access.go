@@ -0,0 +1,245 @@
+package network
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+)
+
+const (
+	ChallengeCmd    = "CHALLENGE"
+	AuthResponseCmd = "AUTH_RESPONSE"
+	AuthOKCmd       = "AUTH_OK"
+
+	challengeSize   = 32
+	clientAuthLabel = "zastitaprojekat client auth v1"
+)
+
+var ErrClientAuth = errors.New("client authentication failed")
+
+// ClientEntry je jedan dozvoljeni klijent iz fajla klijenata.
+type ClientEntry struct {
+	ID       string   `json:"id"`
+	Secret   string   `json:"secret"`
+	Allow    []string `json:"allow,omitempty"`
+	Download bool     `json:"download,omitempty"`
+
+	networks []*net.IPNet
+}
+
+type clientsFile struct {
+	Clients []*ClientEntry `json:"clients"`
+}
+
+// ClientRegistry drži identitete i IP/CIDR liste kojima server dozvoljava pristup.
+type ClientRegistry struct {
+	path    string
+	clients map[string]*ClientEntry
+}
+
+func NewClientRegistry(path string) *ClientRegistry {
+	return &ClientRegistry{path: path, clients: make(map[string]*ClientEntry)}
+}
+
+func LoadClients(path string) (*ClientRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clients file: %w", err)
+	}
+
+	var file clientsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse clients file: %w", err)
+	}
+
+	registry := NewClientRegistry(path)
+	for _, entry := range file.Clients {
+		if err := registry.add(entry); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+func (r *ClientRegistry) Save() error {
+	data, err := json.MarshalIndent(clientsFile{Clients: r.List()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0600)
+}
+
+// Add registruje novog klijenta sa nasumičnom tajnom i vraća tajnu.
+// download dozvoljava klijentu LIST i GET.
+func (r *ClientRegistry) Add(id string, allow []string, download bool) ([]byte, error) {
+	if _, exists := r.clients[id]; exists {
+		return nil, fmt.Errorf("client already exists: %s", id)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, fmt.Errorf("failed to generate client secret: %w", err)
+	}
+
+	entry := &ClientEntry{ID: id, Secret: hex.EncodeToString(secret), Allow: allow, Download: download}
+	if err := r.add(entry); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (r *ClientRegistry) Remove(id string) error {
+	if _, ok := r.clients[id]; !ok {
+		return fmt.Errorf("unknown client: %s", id)
+	}
+	delete(r.clients, id)
+	return nil
+}
+
+func (r *ClientRegistry) List() []*ClientEntry {
+	entries := make([]*ClientEntry, 0, len(r.clients))
+	for _, entry := range r.clients {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+func (r *ClientRegistry) add(entry *ClientEntry) error {
+	if entry.ID == "" || strings.ContainsAny(entry.ID, "|\n") {
+		return fmt.Errorf("invalid client id: %q", entry.ID)
+	}
+	if _, err := hex.DecodeString(entry.Secret); err != nil || entry.Secret == "" {
+		return fmt.Errorf("invalid secret for client %s", entry.ID)
+	}
+
+	entry.networks = nil
+	for _, allow := range entry.Allow {
+		ipNet, err := parseAllow(allow)
+		if err != nil {
+			return fmt.Errorf("client %s: %w", entry.ID, err)
+		}
+		entry.networks = append(entry.networks, ipNet)
+	}
+
+	r.clients[entry.ID] = entry
+	return nil
+}
+
+// Has proverava da li je identitet u fajlu klijenata.
+func (r *ClientRegistry) Has(id string) bool {
+	_, ok := r.clients[id]
+	return ok
+}
+
+// CanDownload proverava da li klijent sme da lista i preuzima fajlove.
+func (r *ClientRegistry) CanDownload(id string) bool {
+	entry, ok := r.clients[id]
+	return ok && entry.Download
+}
+
+// AllowsIP proverava da li ijedan klijent sme da se poveže sa ove adrese.
+func (r *ClientRegistry) AllowsIP(ip net.IP) bool {
+	for _, entry := range r.clients {
+		if entry.allows(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify proverava odgovor na izazov i IP adresu za dati identitet.
+func (r *ClientRegistry) Verify(id string, response, challenge []byte, sessionID string, ip net.IP) error {
+	entry, ok := r.clients[id]
+	if !ok {
+		return fmt.Errorf("%w: unknown client %q", ErrClientAuth, id)
+	}
+
+	secret, _ := hex.DecodeString(entry.Secret)
+	if !hmac.Equal(response, challengeResponse(secret, challenge, sessionID)) {
+		return fmt.Errorf("%w: invalid response for %q", ErrClientAuth, id)
+	}
+
+	if !entry.allows(ip) {
+		return fmt.Errorf("%w: client %q is not allowed from %s", ErrClientAuth, id, ip)
+	}
+
+	return nil
+}
+
+// Prazna lista dozvoljava sve adrese.
+func (e *ClientEntry) allows(ip net.IP) bool {
+	if len(e.networks) == 0 {
+		return true
+	}
+	for _, ipNet := range e.networks {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAllow(allow string) (*net.IPNet, error) {
+	if !strings.Contains(allow, "/") {
+		ip := net.ParseIP(allow)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address: %s", allow)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR: %s", allow)
+	}
+	return ipNet, nil
+}
+
+// Odgovor je vezan za sesiju, pa se ne može proslediti na drugu konekciju.
+func challengeResponse(secret, challenge []byte, sessionID string) []byte {
+	message := append([]byte(clientAuthLabel+"|"+sessionID+"|"), challenge...)
+	mac := sha256.HMAC(secret, message)
+	return mac[:]
+}
+
+func newChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := io.ReadFull(rand.Reader, challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// remoteIP vraća IP adresu klijenta. Klijent preko Unix socket-a je na istoj
+// mašini, pa se za liste adresa i ograničenja tretira kao 127.0.0.1.
+func remoteIP(addr net.Addr) net.IP {
+	if _, ok := addr.(*net.UnixAddr); ok {
+		return net.IPv4(127, 0, 0, 1)
+	}
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
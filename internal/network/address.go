@@ -0,0 +1,109 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// UnixScheme označava adresu Unix socket-a, npr. unix:///run/crypto.sock.
+	UnixScheme = "unix://"
+
+	// DefaultSocketMode dozvoljava Unix socket samo vlasniku procesa servera.
+	DefaultSocketMode fs.FileMode = 0600
+)
+
+// splitAddress vraća mrežu i adresu za net.Listen i net.Dial: adresa sa
+// prefiksom unix:// je putanja Unix socket-a, a sve ostalo je TCP host:port.
+func splitAddress(address string) (network, addr string) {
+	if path, ok := strings.CutPrefix(address, UnixScheme); ok {
+		return "unix", path
+	}
+	return "tcp", address
+}
+
+// listen otvara listener za adresu. Unix socket dobija prava mode, pa
+// pristup serveru određuju prava nad fajlom umesto IP adrese.
+func listen(address string, mode fs.FileMode) (net.Listener, error) {
+	network, addr := splitAddress(address)
+	if network != "unix" {
+		return net.Listen(network, addr)
+	}
+
+	if addr == "" {
+		return nil, fmt.Errorf("missing socket path in %q", address)
+	}
+	if err := removeStaleSocket(addr); err != nil {
+		return nil, err
+	}
+
+	// socket se vezuje u novom direktorijumu dostupnom samo vlasniku i tek sa
+	// konačnim pravima premešta na traženu putanju, pa ni u jednom trenutku
+	// nije dostupan sa pravima koja daje umask procesa
+	dir, err := os.MkdirTemp(filepath.Dir(addr), ".socket-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	bound := filepath.Join(dir, filepath.Base(addr))
+	listener, err := net.Listen(network, bound)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(bound, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	if err := os.Rename(bound, addr); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to move socket to %s: %w", addr, err)
+	}
+	// net.UnixListener bi pri zatvaranju obrisao privremenu putanju
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	return &socketListener{Listener: listener, path: addr}, nil
+}
+
+// socketListener briše fajl Unix socket-a sa konačne putanje pri zatvaranju.
+type socketListener struct {
+	net.Listener
+	path   string
+	unlink sync.Once
+}
+
+func (l *socketListener) Close() error {
+	err := l.Listener.Close()
+	l.unlink.Do(func() { os.Remove(l.path) })
+	return err
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s already exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is in use by another server", path)
+	}
+	return os.Remove(path)
+}
+
+// dial otvara konekciju ka TCP ili Unix adresi.
+func dial(address string, timeout time.Duration) (net.Conn, error) {
+	network, addr := splitAddress(address)
+	return net.DialTimeout(network, addr, timeout)
+}
@@ -0,0 +1,47 @@
+package network
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestListenUnixSocket proverava da socket na konačnoj putanji već ima
+// tražena prava, da iza vezivanja ne ostaje privremeni direktorijum i da
+// zatvaranje listener-a briše socket.
+func TestListenUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("socket permissions are not enforced on Windows")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "crypto.sock")
+
+	listener, err := listen(UnixScheme+path, 0600)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&fs.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode %v, want socket with 0600", info.Mode())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the socket", len(entries))
+	}
+
+	conn, err := dial(UnixScheme+path, time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	conn.Close()
+
+	listener.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after Close: %v", err)
+	}
+}
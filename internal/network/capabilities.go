@@ -0,0 +1,152 @@
+package network
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Verzija 1 (HELLO "<algoritam>,SHA256", bez razmene ključeva i
+	// mogućnosti) se ne podržava; serverHandshake je odbija sa
+	// UNSUPPORTED_VERSION.
+	ProtocolVersion    = 2
+	MinProtocolVersion = 2
+
+	FeatureResume   = "resume"
+	FeatureSession  = "session"
+	FeatureDownload = "download"
+	FeatureRelay    = "relay"
+
+	HashSHA256      = "SHA256"
+	CompressionNone = "none"
+
+	minFrameSize = 4 * 1024
+)
+
+// SupportedAlgorithms su algoritmi kojima server prima fajlove.
+var SupportedAlgorithms = []string{"LEA", "LEA-PCBC"}
+
+// Capabilities su parametri koje strana nudi u HELLO/READY poruci, a posle
+// handshake-a parametri oko kojih su se strane dogovorile. Zapis je
+// "v=2;alg=LEA,LEA-PCBC;hash=SHA256;comp=none;frame=65536;feat=resume,session";
+// nepoznati ključevi se ignorišu da bi novije verzije mogle da dodaju nove.
+type Capabilities struct {
+	Version     int
+	Algorithms  []string
+	Hashes      []string
+	Compression []string
+	MaxFrame    int
+	Features    []string
+}
+
+func (c Capabilities) String() string {
+	return fmt.Sprintf("v=%d;alg=%s;hash=%s;comp=%s;frame=%d;feat=%s",
+		c.Version,
+		strings.Join(c.Algorithms, ","),
+		strings.Join(c.Hashes, ","),
+		strings.Join(c.Compression, ","),
+		c.MaxFrame,
+		strings.Join(c.Features, ","))
+}
+
+// Has proverava da li je mogućnost dogovorena.
+func (c Capabilities) Has(feature string) bool {
+	return slices.Contains(c.Features, feature)
+}
+
+// ParseCapabilities čita ponudu druge strane.
+func ParseCapabilities(offer string) (Capabilities, error) {
+	c := Capabilities{MaxFrame: MaxPacketSize}
+	for _, field := range strings.Split(offer, ";") {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return Capabilities{}, NewProtocolError(ErrCodeProtocol, "malformed capability %q", field)
+		}
+
+		var err error
+		switch key {
+		case "v":
+			c.Version, err = strconv.Atoi(value)
+		case "alg":
+			c.Algorithms = splitList(value)
+		case "hash":
+			c.Hashes = splitList(value)
+		case "comp":
+			c.Compression = splitList(value)
+		case "frame":
+			c.MaxFrame, err = strconv.Atoi(value)
+		case "feat":
+			c.Features = splitList(value)
+		}
+		if err != nil {
+			return Capabilities{}, NewProtocolError(ErrCodeProtocol, "malformed capability %q", field)
+		}
+	}
+
+	if c.Version < 1 {
+		return Capabilities{}, NewProtocolError(ErrCodeProtocol, "missing protocol version")
+	}
+	return c, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// negotiate bira zajednički skup mogućnosti ili vraća grešku sa kodom koji
+// se šalje drugoj strani.
+func negotiate(local, remote Capabilities) (Capabilities, error) {
+	version := min(local.Version, remote.Version)
+	if version < MinProtocolVersion {
+		return Capabilities{}, NewProtocolError(ErrCodeUnsupportedVersion,
+			"protocol version %d is not supported (need %d to %d)", remote.Version, MinProtocolVersion, ProtocolVersion)
+	}
+
+	agreed := Capabilities{
+		Version:     version,
+		Algorithms:  intersect(local.Algorithms, remote.Algorithms),
+		Hashes:      intersect(local.Hashes, remote.Hashes),
+		Compression: intersect(local.Compression, remote.Compression),
+		MaxFrame:    min(local.MaxFrame, remote.MaxFrame),
+		Features:    intersect(local.Features, remote.Features),
+	}
+
+	switch {
+	case len(agreed.Algorithms) == 0:
+		return Capabilities{}, NewProtocolError(ErrCodeNoCommonAlgorithm,
+			"no common encryption algorithm (offered %s, supported %s)",
+			strings.Join(remote.Algorithms, ","), strings.Join(local.Algorithms, ","))
+	case len(agreed.Hashes) == 0:
+		return Capabilities{}, NewProtocolError(ErrCodeNoCommonAlgorithm,
+			"no common hash algorithm (offered %s, supported %s)",
+			strings.Join(remote.Hashes, ","), strings.Join(local.Hashes, ","))
+	case len(agreed.Compression) == 0:
+		return Capabilities{}, NewProtocolError(ErrCodeNoCommonAlgorithm,
+			"no common compression (offered %s, supported %s)",
+			strings.Join(remote.Compression, ","), strings.Join(local.Compression, ","))
+	case agreed.MaxFrame < minFrameSize:
+		return Capabilities{}, NewProtocolError(ErrCodeProtocol,
+			"frame size %d is below the minimum of %d", agreed.MaxFrame, minFrameSize)
+	}
+
+	return agreed, nil
+}
+
+// intersect zadržava redosled iz prve liste.
+func intersect(a, b []string) []string {
+	var common []string
+	for _, item := range a {
+		if slices.Contains(b, item) {
+			common = append(common, item)
+		}
+	}
+	return common
+}
@@ -1,12 +1,15 @@
 package network
 
 import (
+	"crypto/ed25519"
 	"fmt"
-	"io"
+	"io/fs"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/AleksaS003/zastitaprojekat/internal/core"
@@ -14,11 +17,32 @@ import (
 )
 
 type TCPClient struct {
-	address string
-	conn    net.Conn
-	timeout time.Duration
+	address    string
+	conn       net.Conn
+	timeout    time.Duration
+	signingKey ed25519.PrivateKey
+	session    *Session
+
+	clientID     string
+	clientSecret []byte
+
+	retries    int
+	retryDelay time.Duration
+
+	progress progressHandler
+
+	connBandwidth  int64
+	totalBandwidth *tokenBucket
+
+	transportKey []byte
+	recipient    string
 }
 
+const (
+	DefaultRetries    = 3
+	DefaultRetryDelay = 2 * time.Second
+)
+
 func NewTCPClient(address string, timeout time.Duration) *TCPClient {
 
 	if err := logger.InitGlobal("./logs"); err != nil {
@@ -26,18 +50,51 @@ func NewTCPClient(address string, timeout time.Duration) *TCPClient {
 	}
 
 	return &TCPClient{
-		address: address,
-		timeout: timeout,
+		address:    address,
+		timeout:    timeout,
+		retries:    DefaultRetries,
+		retryDelay: DefaultRetryDelay,
 	}
 }
 
+// SetSigningKey potpisuje svaki poslati kontejner datim Ed25519 ključem.
+func (c *TCPClient) SetSigningKey(key ed25519.PrivateKey) {
+	c.signingKey = key
+}
+
+// SetCredentials postavlja identitet i tajnu za servere koji traže autentifikaciju klijenta.
+func (c *TCPClient) SetCredentials(id string, secret []byte) {
+	c.clientID = id
+	c.clientSecret = secret
+}
+
+// SetRetry određuje koliko puta se prenos nastavlja posle prekida veze i
+// koliko se čeka pre ponovnog povezivanja (0 pokušaja = bez nastavka).
+func (c *TCPClient) SetRetry(retries int, delay time.Duration) {
+	c.retries = retries
+	c.retryDelay = delay
+}
+
+// SetBandwidth ograničava protok u bajtovima u sekundi po konekciji i ukupno
+// za sve konekcije ovog klijenta, uključujući ponovna povezivanja (0 = bez
+// ograničenja).
+func (c *TCPClient) SetBandwidth(perConnection, total int64) {
+	c.connBandwidth = perConnection
+	c.totalBandwidth = newBandwidthBucket(total)
+}
+
+// SetProgress prijavljuje napredak svakog poslatog i preuzetog fajla.
+func (c *TCPClient) SetProgress(report ProgressFunc) {
+	c.progress.set(report)
+}
+
 func (c *TCPClient) Connect() error {
 	logger.LogNetwork(logger.CLIENT_CONNECT, c.address,
 		"Connecting to server", true, map[string]interface{}{
 			"timeout": c.timeout.String(),
 		})
 
-	conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+	conn, err := dial(c.address, c.timeout)
 	if err != nil {
 		logger.Error(logger.CLIENT_CONNECT, "Failed to connect to server", map[string]interface{}{
 			"address": c.address,
@@ -47,7 +104,7 @@ func (c *TCPClient) Connect() error {
 		return fmt.Errorf("failed to connect to %s: %w", c.address, err)
 	}
 
-	c.conn = conn
+	c.conn = limitConn(conn, newBandwidthBucket(c.connBandwidth), c.totalBandwidth)
 	logger.LogNetwork(logger.CLIENT_CONNECT, c.address,
 		"Successfully connected to server", true, nil)
 
@@ -69,7 +126,108 @@ func (c *TCPClient) SendFile(filePath string, algorithm string, key []byte) erro
 	if c.conn == nil {
 		return fmt.Errorf("not connected to server")
 	}
+	return c.sendFile(filePath, filepath.Base(filePath), algorithm, key)
+}
+
+// FileEntry je fajl za slanje; Name je relativna putanja pod kojom ga server čuva.
+type FileEntry struct {
+	Path string
+	Name string
+}
+
+// FileResult je ishod slanja jednog fajla u sesiji.
+type FileResult struct {
+	FileEntry
+	Err error
+}
+
+// CollectFiles vraća sve obične fajlove u stablu direktorijuma sa putanjama
+// relativnim u odnosu na dir.
+func CollectFiles(dir string) ([]FileEntry, error) {
+	var entries []FileEntry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, FileEntry{Path: path, Name: filepath.ToSlash(rel)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	return entries, nil
+}
+
+// SendFiles šalje više fajlova u jednoj sesiji. Fajl koji server odbije
+// (npr. već postoji) ne prekida sesiju; greška se vraća samo kada sesija ne
+// može da se nastavi, a rezultati tada sadrže i neposlate fajlove.
+func (c *TCPClient) SendFiles(files []FileEntry, algorithm string, key []byte) ([]FileResult, error) {
+	if c.conn == nil {
+		return nil, fmt.Errorf("not connected to server")
+	}
+
+	results := make([]FileResult, 0, len(files))
+	for i, file := range files {
+		err := c.sendFile(file.Path, file.Name, algorithm, key)
+		results = append(results, FileResult{FileEntry: file, Err: err})
+		if err != nil && !fileLevelError(err) {
+			for _, rest := range files[i+1:] {
+				results = append(results, FileResult{FileEntry: rest, Err: fmt.Errorf("not sent: %w", err)})
+			}
+			return results, err
+		}
+	}
+
+	if err := c.endSession(results); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// endSession šalje END i poredi rezime servera sa rezultatima klijenta.
+func (c *TCPClient) endSession(results []FileResult) error {
+	// stari serveri ne znaju za END, sesija se završava zatvaranjem konekcije
+	if c.session == nil || !c.session.Capabilities.Has(FeatureSession) {
+		return nil
+	}
+
+	if err := c.session.SendMessage(c.conn, EndCmd, nil); err != nil {
+		return fmt.Errorf("failed to send END: %w", err)
+	}
+
+	msg, err := c.session.ReceiveMessage(c.conn)
+	if err != nil {
+		return fmt.Errorf("failed to receive SUMMARY: %w", err)
+	}
+	if msg.Command != SummaryCmd {
+		return fmt.Errorf("expected SUMMARY, got %s", msg.Command)
+	}
+
+	sent := 0
+	for _, result := range results {
+		if result.Err == nil {
+			sent++
+		}
+	}
+
+	logger.LogNetwork(logger.SEND_FILE, c.address,
+		"Session finished", sent == len(results), map[string]interface{}{
+			"session_id":     c.session.ID,
+			"sent":           sent,
+			"failed":         len(results) - sent,
+			"server_summary": string(msg.Payload),
+		})
+	return nil
+}
 
+func (c *TCPClient) sendFile(filePath, name, algorithm string, key []byte) error {
 	originalFileInfo, err := os.Stat(filePath)
 	if err != nil {
 		logger.Error(logger.SEND_FILE, "Failed to get file info", map[string]interface{}{
@@ -89,21 +247,9 @@ func (c *TCPClient) SendFile(filePath string, algorithm string, key []byte) erro
 
 	log.Printf("Starting file transfer: %s (%d bytes)", filePath, originalFileInfo.Size())
 
-	if err := c.doHandshake(algorithm); err != nil {
-		logger.Error(logger.SEND_FILE, "Handshake failed", map[string]interface{}{
-			"address":   c.address,
-			"algorithm": algorithm,
-			"error":     err.Error(),
-		})
-		return fmt.Errorf("handshake failed: %w", err)
-	}
-
-	logger.Info(logger.SEND_FILE, "Handshake successful", true, map[string]interface{}{
-		"address":   c.address,
-		"algorithm": algorithm,
-	})
-
-	encryptedPath, metadata, err := c.prepareFileForSending(filePath, algorithm, key)
+	// Fajl se šifruje dok se šalje; šifrovanje je determinističko za isti
+	// ključ podataka i IV, pa nastavak posle prekida šalje iste bajtove
+	stream, err := c.prepareFileForSending(filePath, algorithm, key)
 	if err != nil {
 		logger.Error(logger.SEND_FILE, "Failed to prepare file for sending", map[string]interface{}{
 			"file_path": filePath,
@@ -112,19 +258,8 @@ func (c *TCPClient) SendFile(filePath string, algorithm string, key []byte) erro
 		})
 		return fmt.Errorf("failed to prepare file: %w", err)
 	}
-	defer os.Remove(encryptedPath)
-
-	encryptedFileInfo, _ := os.Stat(encryptedPath)
-
-	logger.LogEncryption("encrypt", algorithm, filePath,
-		originalFileInfo.Size(), true, map[string]interface{}{
-			"encrypted_size": encryptedFileInfo.Size(),
-			"temp_file":      encryptedPath,
-			"hash_algorithm": metadata.HashAlgorithm,
-			"hash":           metadata.Hash,
-		})
 
-	metadataJSON, err := metadata.ToJSON()
+	metadataJSON, err := stream.Metadata.ToJSON()
 	if err != nil {
 		logger.Error(logger.SEND_FILE, "Failed to serialize metadata", map[string]interface{}{
 			"error": err.Error(),
@@ -132,41 +267,112 @@ func (c *TCPClient) SendFile(filePath string, algorithm string, key []byte) erro
 		return fmt.Errorf("failed to serialize metadata: %w", err)
 	}
 
-	startPayload := fmt.Sprintf("%s|%d|%d",
-		filepath.Base(filePath),
-		encryptedFileInfo.Size(),
-		len(metadataJSON))
+	transfer := &outgoingTransfer{
+		name:         name,
+		stream:       stream,
+		size:         stream.Size,
+		metadataJSON: metadataJSON,
+		metadata:     stream.Metadata,
+	}
+	if transfer.id, err = newTransferID(); err != nil {
+		return err
+	}
 
-	if err := SendMessage(c.conn, FileStartCmd, []byte(startPayload)); err != nil {
-		logger.Error(logger.SEND_FILE, "Failed to send FILE_START", map[string]interface{}{
-			"error": err.Error(),
+	transfer.progress = c.progress.track(Progress{
+		TransferID: transfer.id,
+		Name:       name,
+		Direction:  DirectionSend,
+		Remote:     c.address,
+		Total:      transfer.size,
+	})
+	err = c.sendWithRetries(transfer, algorithm, key)
+	transfer.progress.finish(err)
+	return err
+}
+
+// sendWithRetries ponavlja slanje posle prekida veze, nastavljajući od
+// poslednjeg bajta koji je server sačuvao.
+func (c *TCPClient) sendWithRetries(transfer *outgoingTransfer, algorithm string, key []byte) error {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := c.reconnect(); err != nil {
+				if attempt < c.retries && isConnectionError(err) {
+					time.Sleep(c.retryDelay)
+					continue
+				}
+				return err
+			}
+		}
+
+		err := c.sendTransfer(transfer, algorithm, key, attempt > 0)
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.retries || !isConnectionError(err) {
+			return err
+		}
+
+		logger.Warning(logger.SEND_FILE, "Connection lost, transfer will be resumed", true, map[string]interface{}{
+			"transfer_id": transfer.id,
+			"attempt":     attempt + 1,
+			"retries":     c.retries,
+			"error":       err.Error(),
 		})
-		return fmt.Errorf("failed to send FILE_START: %w", err)
+		log.Printf("Connection lost (%v), retrying in %s (%d/%d)", err, c.retryDelay, attempt+1, c.retries)
+		time.Sleep(c.retryDelay)
 	}
+}
 
-	if err := SendMessage(c.conn, "METADATA", metadataJSON); err != nil {
-		logger.Error(logger.SEND_FILE, "Failed to send metadata", map[string]interface{}{
-			"error": err.Error(),
-		})
-		return fmt.Errorf("failed to send metadata: %w", err)
+// outgoingTransfer je fajl pripremljen za slanje, isti za sve pokušaje.
+type outgoingTransfer struct {
+	id           string
+	name         string
+	stream       *core.EncryptedStream
+	size         int64
+	metadataJSON []byte
+	metadata     *core.Metadata
+	progress     *progressTracker
+}
+
+// sendTransfer izvršava jedan pokušaj slanja na trenutnoj konekciji. Kada je
+// resume postavljen, prvo se traži nastavak prekinutog prenosa.
+func (c *TCPClient) sendTransfer(transfer *outgoingTransfer, algorithm string, key []byte, resume bool) error {
+	if c.session == nil {
+		if err := c.openSession(algorithm, key); err != nil {
+			return err
+		}
 	}
 
-	logger.Info(logger.SEND_FILE, "Metadata sent", true, map[string]interface{}{
-		"metadata_size": len(metadataJSON),
-		"algorithm":     metadata.EncryptionAlgorithm,
-		"hash":          metadata.Hash[:16] + "...",
-	})
+	offset := int64(-1)
+	if resume && c.session.Capabilities.Has(FeatureResume) {
+		var err error
+		if offset, err = c.resumeTransfer(transfer); err != nil {
+			return err
+		}
+	}
+
+	if offset < 0 {
+		offset = 0
+		if err := c.startTransfer(transfer); err != nil {
+			return err
+		}
+	}
 
-	totalSent, chunkCount, err := c.sendFileInChunks(encryptedPath)
+	totalSent, chunkCount, err := c.sendFileInChunks(transfer, offset)
 	if err != nil {
 		logger.Error(logger.SEND_FILE, "Failed to send file data", map[string]interface{}{
-			"temp_file": encryptedPath,
-			"error":     err.Error(),
+			"transfer_id": transfer.id,
+			"error":       err.Error(),
 		})
+		if isConnectionError(err) {
+			if serverErr := c.pendingServerError(); serverErr != nil {
+				return fmt.Errorf("server error: %w", serverErr)
+			}
+		}
 		return fmt.Errorf("failed to send file: %w", err)
 	}
 
-	if err := SendMessage(c.conn, FileEndCmd, nil); err != nil {
+	if err := c.session.SendMessage(c.conn, FileEndCmd, nil); err != nil {
 		logger.Error(logger.SEND_FILE, "Failed to send FILE_END", map[string]interface{}{
 			"error": err.Error(),
 		})
@@ -174,7 +380,9 @@ func (c *TCPClient) SendFile(filePath string, algorithm string, key []byte) erro
 	}
 
 	logger.Info(logger.SEND_FILE, "File data sent", true, map[string]interface{}{
+		"transfer_id": transfer.id,
 		"total_bytes": totalSent,
+		"resumed_at":  offset,
 		"chunk_size":  "32KB",
 		"chunk_count": chunkCount,
 	})
@@ -182,155 +390,292 @@ func (c *TCPClient) SendFile(filePath string, algorithm string, key []byte) erro
 	return c.waitForVerification()
 }
 
-func (c *TCPClient) doHandshake(algorithm string) error {
+// openSession izvršava handshake i autentifikaciju na trenutnoj konekciji.
+func (c *TCPClient) openSession(algorithm string, key []byte) error {
+	if err := c.doHandshake(algorithm, key); err != nil {
+		logger.Error(logger.SEND_FILE, "Handshake failed", map[string]interface{}{
+			"address":   c.address,
+			"algorithm": algorithm,
+			"error":     err.Error(),
+		})
+		return fmt.Errorf("handshake failed: %w", err)
+	}
+
+	logger.Info(logger.SEND_FILE, "Handshake successful", true, map[string]interface{}{
+		"address":    c.address,
+		"algorithm":  algorithm,
+		"session_id": c.session.ID,
+	})
 
-	helloPayload := fmt.Sprintf("%s,SHA256", algorithm)
-	if err := SendMessage(c.conn, HelloCmd, []byte(helloPayload)); err != nil {
-		return fmt.Errorf("failed to send HELLO: %w", err)
+	if err := c.authenticate(); err != nil {
+		logger.Error(logger.CLIENT_AUTH, "Client authentication failed", map[string]interface{}{
+			"address":   c.address,
+			"client_id": c.clientID,
+			"error":     err.Error(),
+		})
+		return fmt.Errorf("authentication failed: %w", err)
 	}
 
-	msg, err := ReceiveMessage(c.conn)
-	if err != nil {
-		return fmt.Errorf("failed to receive READY: %w", err)
+	if c.recipient != "" {
+		if err := c.selectRecipient(); err != nil {
+			logger.Error(logger.SEND_FILE, "Recipient rejected by relay", map[string]interface{}{
+				"address":   c.address,
+				"recipient": c.recipient,
+				"error":     err.Error(),
+			})
+			return fmt.Errorf("recipient %s: %w", c.recipient, err)
+		}
 	}
+	return nil
+}
 
-	if msg.Command != ReadyCmd {
-		return fmt.Errorf("expected READY, got %s", msg.Command)
+func (c *TCPClient) startTransfer(transfer *outgoingTransfer) error {
+	startPayload := fmt.Sprintf("%s|%d|%d",
+		transfer.name,
+		transfer.size,
+		len(transfer.metadataJSON))
+	// serveri bez nastavka prenosa ne očekuju transfer ID
+	if c.session.Capabilities.Has(FeatureResume) {
+		startPayload += "|" + transfer.id
 	}
 
-	logger.Info(logger.SEND_FILE, "Server ready response", true, map[string]interface{}{
-		"server_algorithms": string(msg.Payload),
-	})
+	if err := c.session.SendMessage(c.conn, FileStartCmd, []byte(startPayload)); err != nil {
+		logger.Error(logger.SEND_FILE, "Failed to send FILE_START", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return fmt.Errorf("failed to send FILE_START: %w", err)
+	}
 
-	log.Printf("Server ready: %s", string(msg.Payload))
-	return nil
-}
+	if err := c.session.SendMessage(c.conn, "METADATA", transfer.metadataJSON); err != nil {
+		logger.Error(logger.SEND_FILE, "Failed to send metadata", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return fmt.Errorf("failed to send metadata: %w", err)
+	}
 
-func (c *TCPClient) prepareFileForSending(filePath, algorithm string, key []byte) (string, *core.Metadata, error) {
-	logger.Info(logger.ENCRYPT, "Preparing file for sending", true, map[string]interface{}{
-		"file":      filePath,
-		"algorithm": algorithm,
+	logger.Info(logger.SEND_FILE, "Metadata sent", true, map[string]interface{}{
+		"transfer_id":   transfer.id,
+		"metadata_size": len(transfer.metadataJSON),
+		"algorithm":     transfer.metadata.EncryptionAlgorithm,
+		"hash":          transfer.metadata.Hash[:16] + "...",
 	})
+	return nil
+}
 
-	tempFile := fmt.Sprintf("%s_send_%d.enc", filepath.Base(filePath), time.Now().UnixNano())
+// resumeTransfer traži od servera broj već primljenih bajtova. Vraća -1 ako
+// server ne zna za prenos (istekao je ili nije ni počeo); tada se na istoj
+// konekciji šalje FILE_START i prenos kreće iz početka.
+func (c *TCPClient) resumeTransfer(transfer *outgoingTransfer) (int64, error) {
+	if err := c.session.SendMessage(c.conn, ResumeCmd, []byte(transfer.id)); err != nil {
+		return 0, fmt.Errorf("failed to send RESUME: %w", err)
+	}
 
-	fileProcessor := core.NewFileProcessor()
-	err := fileProcessor.EncryptFileWithMetadata(filePath, tempFile, algorithm, key)
+	msg, err := c.session.ReceiveMessage(c.conn)
 	if err != nil {
-		logger.Error(logger.ENCRYPT, "Failed to encrypt file", map[string]interface{}{
-			"file":      filePath,
-			"algorithm": algorithm,
-			"error":     err.Error(),
-		})
-		return "", nil, fmt.Errorf("failed to encrypt file: %w", err)
+		return 0, fmt.Errorf("failed to receive RESUME_OK: %w", err)
 	}
 
-	data, err := os.ReadFile(tempFile)
-	if err != nil {
-		logger.Error(logger.ENCRYPT, "Failed to read encrypted file", map[string]interface{}{
-			"temp_file": tempFile,
-			"error":     err.Error(),
+	switch msg.Command {
+	case ResumeOKCmd:
+	case ErrorCmd:
+		serverErr := DecodeError(msg.Payload)
+		if serverErr.Code != ErrCodeUnknownTransfer {
+			return 0, fmt.Errorf("server error: %w", serverErr)
+		}
+		logger.Info(logger.SEND_FILE, "Server does not know the transfer, starting over", true, map[string]interface{}{
+			"transfer_id": transfer.id,
+			"reason":      serverErr.Message,
 		})
-		return "", nil, fmt.Errorf("failed to read encrypted file: %w", err)
+		return -1, nil
+	default:
+		return 0, fmt.Errorf("expected RESUME_OK, got %s", msg.Command)
+	}
+
+	offset, err := strconv.ParseInt(string(msg.Payload), 10, 64)
+	if err != nil || offset < 0 || offset > transfer.size {
+		return 0, fmt.Errorf("invalid resume offset %q", string(msg.Payload))
 	}
 
-	metadata, _, err := core.ExtractFromEncryptedFile(data)
+	logger.Info(logger.SEND_FILE, "Resuming transfer", true, map[string]interface{}{
+		"transfer_id": transfer.id,
+		"offset":      offset,
+		"size":        transfer.size,
+	})
+	log.Printf("Resuming transfer at %d/%d bytes", offset, transfer.size)
+	return offset, nil
+}
+
+func (c *TCPClient) reconnect() error {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.session = nil
+	return c.Connect()
+}
+
+func (c *TCPClient) doHandshake(algorithm string, key []byte) error {
+	psk := key
+	if c.transportKey != nil {
+		psk = c.transportKey
+	}
+	session, err := clientHandshake(c.conn, psk, c.capabilities(algorithm))
 	if err != nil {
-		logger.Error(logger.ENCRYPT, "Failed to extract metadata", map[string]interface{}{
-			"error": err.Error(),
-		})
-		return "", nil, fmt.Errorf("failed to extract metadata: %w", err)
+		return err
 	}
+	c.session = session
 
-	encryptedFileInfo, _ := os.Stat(tempFile)
+	logger.Info(logger.SEND_FILE, "Server ready response", true, map[string]interface{}{
+		"protocol_version": session.Capabilities.Version,
+		"capabilities":     session.Capabilities.String(),
+		"session_id":       session.ID,
+		"key_exchange":     "X25519",
+	})
 
-	logger.LogEncryption("encrypt", algorithm, filePath,
-		encryptedFileInfo.Size(), true, map[string]interface{}{
-			"encrypted_size": encryptedFileInfo.Size(),
-			"temp_file":      tempFile,
-			"hash_algorithm": metadata.HashAlgorithm,
-			"hash":           metadata.Hash[:16] + "...",
-			"hash_type":      "encrypted_data",
-			"verified_by":    "receiver",
-		})
+	log.Printf("Server ready: protocol v%d, %s (session %s)", session.Capabilities.Version,
+		strings.Join(session.Capabilities.Algorithms, ","), session.ID)
+	return nil
+}
 
-	return tempFile, metadata, nil
+// capabilities nudi samo algoritam kojim klijent šifruje fajlove.
+func (c *TCPClient) capabilities(algorithm string) Capabilities {
+	return Capabilities{
+		Version:     ProtocolVersion,
+		Algorithms:  []string{algorithm},
+		Hashes:      []string{HashSHA256},
+		Compression: []string{CompressionNone},
+		MaxFrame:    MaxPacketSize,
+		Features:    []string{FeatureResume, FeatureSession, FeatureDownload, FeatureRelay},
+	}
 }
 
-func (c *TCPClient) sendFileInChunks(filePath string) (int64, int, error) {
-	file, err := os.Open(filePath)
+func (c *TCPClient) authenticate() error {
+	msg, err := c.session.ReceiveMessage(c.conn)
 	if err != nil {
-		logger.Error(logger.SEND_FILE, "Failed to open file", map[string]interface{}{
-			"file_path": filePath,
-			"error":     err.Error(),
-		})
-		return 0, 0, fmt.Errorf("failed to open file: %w", err)
+		return fmt.Errorf("failed to receive authentication request: %w", err)
+	}
+
+	switch msg.Command {
+	case AuthOKCmd:
+		return nil
+	case ChallengeCmd:
+	case ErrorCmd:
+		return fmt.Errorf("server error: %w", DecodeError(msg.Payload))
+	default:
+		return fmt.Errorf("expected CHALLENGE or AUTH_OK, got %s", msg.Command)
+	}
+
+	if c.clientID == "" || c.clientSecret == nil {
+		return fmt.Errorf("server requires client authentication, client id and secret are not set")
+	}
+
+	response := fmt.Sprintf("%s|%x", c.clientID, challengeResponse(c.clientSecret, msg.Payload, c.session.ID))
+	if err := c.session.SendMessage(c.conn, AuthResponseCmd, []byte(response)); err != nil {
+		return fmt.Errorf("failed to send AUTH_RESPONSE: %w", err)
 	}
-	defer file.Close()
 
-	fileInfo, err := file.Stat()
+	msg, err = c.session.ReceiveMessage(c.conn)
 	if err != nil {
-		logger.Error(logger.SEND_FILE, "Failed to get file info", map[string]interface{}{
-			"file_path": filePath,
-			"error":     err.Error(),
-		})
-		return 0, 0, fmt.Errorf("failed to get file info: %w", err)
+		return fmt.Errorf("failed to receive authentication result: %w", err)
+	}
+	if msg.Command == ErrorCmd {
+		return fmt.Errorf("server error: %w", DecodeError(msg.Payload))
+	}
+	if msg.Command != AuthOKCmd {
+		return fmt.Errorf("expected AUTH_OK, got %s", msg.Command)
 	}
 
-	buffer := make([]byte, 32*1024)
-	var totalSent int64
-	var chunkCount int
+	logger.LogNetwork(logger.CLIENT_AUTH, c.address,
+		"Authenticated to server", true, map[string]interface{}{
+			"client_id":  c.clientID,
+			"session_id": c.session.ID,
+		})
+	return nil
+}
 
-	for {
-		n, err := file.Read(buffer)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			logger.Error(logger.SEND_FILE, "Failed to read file chunk", map[string]interface{}{
-				"file_path":   filePath,
-				"chunk_index": chunkCount,
-				"error":       err.Error(),
-			})
-			return totalSent, chunkCount, fmt.Errorf("failed to read file: %w", err)
-		}
+func (c *TCPClient) prepareFileForSending(filePath, algorithm string, key []byte) (*core.EncryptedStream, error) {
+	logger.Info(logger.ENCRYPT, "Preparing file for sending", true, map[string]interface{}{
+		"file":      filePath,
+		"algorithm": algorithm,
+	})
 
-		if err := SendMessage(c.conn, FileDataCmd, buffer[:n]); err != nil {
-			logger.Error(logger.SEND_FILE, "Failed to send file chunk", map[string]interface{}{
-				"chunk_index": chunkCount,
-				"chunk_size":  n,
-				"error":       err.Error(),
-			})
-			return totalSent, chunkCount, fmt.Errorf("failed to send file data: %w", err)
-		}
+	fileProcessor := core.NewFileProcessor()
+	fileProcessor.SetSigningKey(c.signingKey)
+	stream, err := fileProcessor.NewEncryptedStream(filePath, algorithm, key)
+	if err != nil {
+		logger.Error(logger.ENCRYPT, "Failed to encrypt file", map[string]interface{}{
+			"file":      filePath,
+			"algorithm": algorithm,
+			"error":     err.Error(),
+		})
+		return nil, fmt.Errorf("failed to encrypt file: %w", err)
+	}
 
-		totalSent += int64(n)
-		chunkCount++
+	logger.LogEncryption("encrypt", algorithm, filePath,
+		stream.Metadata.Size, true, map[string]interface{}{
+			"encrypted_size": stream.Size,
+			"hash_algorithm": stream.Metadata.HashAlgorithm,
+			"hash":           stream.Metadata.Hash[:16] + "...",
+			"hash_type":      "encrypted_data",
+			"verified_by":    "receiver",
+			"signed":         stream.Metadata.Signature != nil,
+		})
+
+	return stream, nil
+}
 
-		if chunkCount%10 == 0 {
-			progress := float64(totalSent) * 100 / float64(fileInfo.Size())
+// sendFileInChunks šifruje i šalje fajl od datog offseta (0 za novi prenos).
+func (c *TCPClient) sendFileInChunks(transfer *outgoingTransfer, offset int64) (int64, int, error) {
+	data := newDataWriter(c.conn, c.session)
+	transfer.progress.restart(offset)
+	data.onChunk = func(sent int64, chunks int) {
+		transfer.progress.update(offset + sent)
+		if chunks%10 == 0 {
+			progress := float64(offset+sent) * 100 / float64(transfer.size)
 			logger.Info(logger.SEND_FILE, "File transfer progress", true, map[string]interface{}{
-				"chunks_sent": chunkCount,
-				"bytes_sent":  totalSent,
+				"chunks_sent": chunks,
+				"bytes_sent":  sent,
 				"progress":    fmt.Sprintf("%.1f%%", progress),
 			})
 		}
 	}
 
+	_, err := transfer.stream.CopyTo(data, offset)
+	if err == nil {
+		err = data.Flush()
+	}
+	if err != nil {
+		if data.err != nil {
+			return data.sent, data.chunks, fmt.Errorf("failed to send file data: %w", data.err)
+		}
+		return data.sent, data.chunks, err
+	}
+
 	logger.Info(logger.SEND_FILE, "File transfer completed", true, map[string]interface{}{
-		"total_chunks":       chunkCount,
-		"total_bytes":        totalSent,
-		"average_chunk_size": totalSent / int64(chunkCount),
+		"total_chunks":       data.chunks,
+		"total_bytes":        data.sent,
+		"average_chunk_size": data.sent / int64(max(data.chunks, 1)),
 	})
 
-	log.Printf("Total sent: %d bytes in %d chunks", totalSent, chunkCount)
-	return totalSent, chunkCount, nil
+	log.Printf("Total sent: %d bytes in %d chunks", data.sent, data.chunks)
+	return data.sent, data.chunks, nil
+}
+
+// pendingServerError čita ERROR koji je server poslao pre nego što je prekinuo prenos.
+func (c *TCPClient) pendingServerError() *ProtocolError {
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	msg, err := c.session.ReceiveMessage(c.conn)
+	if err != nil || msg.Command != ErrorCmd {
+		return nil
+	}
+	return DecodeError(msg.Payload)
 }
 
 func (c *TCPClient) waitForVerification() error {
 	logger.Info(logger.SEND_FILE, "Waiting for server verification", true, nil)
 
-	msg, err := ReceiveMessage(c.conn)
+	msg, err := c.session.ReceiveMessage(c.conn)
 	if err != nil {
 		logger.Error(logger.SEND_FILE, "Failed to receive verification", map[string]interface{}{
 			"error": err.Error(),
@@ -349,11 +694,12 @@ func (c *TCPClient) waitForVerification() error {
 		return nil
 
 	case ErrorCmd:
-		errorMsg := string(msg.Payload)
+		serverErr := DecodeError(msg.Payload)
 		logger.Error(logger.SEND_FILE, "Server reported error", map[string]interface{}{
-			"server_error": errorMsg,
+			"error_code":   string(serverErr.Code),
+			"server_error": serverErr.Message,
 		})
-		return fmt.Errorf("server error: %s", errorMsg)
+		return fmt.Errorf("server error: %w", serverErr)
 
 	default:
 		logger.Error(logger.SEND_FILE, "Unexpected server response", map[string]interface{}{
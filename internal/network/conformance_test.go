@@ -0,0 +1,786 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+)
+
+// describe svodi poruku servera na oblik koji se poredi u testovima:
+// "ERROR <kod>", "SUMMARY <payload>" ili samo komandu.
+func describe(msg Message) string {
+	switch msg.Command {
+	case ErrorCmd:
+		return "ERROR " + string(DecodeError(msg.Payload).Code)
+	case SummaryCmd:
+		return "SUMMARY " + string(msg.Payload)
+	}
+	return msg.Command
+}
+
+func describeAll(messages []Message) []string {
+	described := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		described = append(described, describe(msg))
+	}
+	return described
+}
+
+// TestConformanceSession proverava kako server odgovara na poruke posle
+// handshake-a: pogrešan redosled, neispravne okvire i prevelike dužine.
+func TestConformanceSession(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(s *TCPServer)
+		script func(t *testing.T, c *pipeClient) []Message
+		want   []string
+	}{
+		{
+			name: "END returns summary",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(EndCmd, nil)
+				return c.finish()
+			},
+			want: []string{"SUMMARY 0|0"},
+		},
+		{
+			name: "FILE_DATA before FILE_START",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(FileDataCmd, []byte("data"))
+				return c.finish()
+			},
+			want: []string{"ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name: "FILE_END before FILE_START",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(FileEndCmd, nil)
+				return c.finish()
+			},
+			want: []string{"ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name: "METADATA before FILE_START",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send("METADATA", []byte("{}"))
+				return c.finish()
+			},
+			want: []string{"ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name: "unknown command",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send("BOGUS", nil)
+				return c.finish()
+			},
+			want: []string{"ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name: "malformed FILE_START",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(FileStartCmd, []byte("report.txt"))
+				return c.finish()
+			},
+			want: []string{"ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name: "negative size in FILE_START",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(FileStartCmd, []byte("report.txt|-1|10"))
+				return c.finish()
+			},
+			want: []string{"ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name:  "FILE_START over transfer limit",
+			setup: func(s *TCPServer) { s.SetLimits(DefaultMaxConnections, 1024) },
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(FileStartCmd, []byte("report.txt|4096|100"))
+				return c.finish()
+			},
+			want: []string{"ERROR TRANSFER_TOO_LARGE"},
+		},
+		{
+			name: "FILE_DATA instead of METADATA",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(FileStartCmd, []byte("report.txt|10|5"))
+				c.send(FileDataCmd, []byte("0123456789"))
+				return c.finish()
+			},
+			want: []string{"ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name: "GET with downloads disabled keeps the session",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(GetCmd, []byte("report.txt"))
+				c.send(EndCmd, nil)
+				return c.finish()
+			},
+			want: []string{"ERROR FORBIDDEN", "SUMMARY 0|0"},
+		},
+		{
+			name:  "GET of a missing file keeps the session",
+			setup: func(s *TCPServer) { s.SetDownloads(true) },
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(GetCmd, []byte("missing.txt"))
+				c.send(EndCmd, nil)
+				return c.finish()
+			},
+			want: []string{"ERROR NOT_FOUND", "SUMMARY 0|0"},
+		},
+		{
+			name:  "GET outside the output directory",
+			setup: func(s *TCPServer) { s.SetDownloads(true) },
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(GetCmd, []byte("../secret.txt"))
+				c.send(EndCmd, nil)
+				return c.finish()
+			},
+			want: []string{"ERROR INVALID_FILENAME", "SUMMARY 0|0"},
+		},
+		{
+			name: "RESUME of an unknown transfer expects FILE_START",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.send(ResumeCmd, []byte(strings.Repeat("ab", 16)))
+				c.send(EndCmd, nil)
+				return c.finish()
+			},
+			want: []string{"ERROR UNKNOWN_TRANSFER", "ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name: "replayed frame",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				frame := c.session.EncodeMessage(Message{Command: GetCmd, Payload: []byte("report.txt")})
+				c.Write(frame)
+				c.Write(frame)
+				return c.finish()
+			},
+			want: []string{"ERROR FORBIDDEN", "ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name: "tampered frame",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				frame := c.session.EncodeMessage(Message{Command: EndCmd})
+				frame[len(frame)-1] ^= 1
+				c.Write(frame)
+				return c.finish()
+			},
+			want: []string{"ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name: "oversized frame length",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.Write([]byte{0xff, 0xff, 0xff, 0xff})
+				return c.finish()
+			},
+			want: []string{"ERROR FRAME_TOO_LARGE"},
+		},
+		{
+			name: "truncated frame",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				frame := c.session.EncodeMessage(Message{Command: EndCmd})
+				c.Write(frame[:len(frame)-3])
+				return c.hangUp()
+			},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			if tt.setup != nil {
+				tt.setup(s)
+			}
+			c := newPipeClient(t, s)
+			if err := c.handshake(testKey); err != nil {
+				t.Fatalf("handshake: %v", err)
+			}
+
+			got := describeAll(tt.script(t, c))
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("server sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestConformanceHandshake proverava poruke pre i tokom handshake-a.
+func TestConformanceHandshake(t *testing.T) {
+	hello := EncodeMessage(Message{Command: HelloCmd, Payload: []byte("v=2;alg=LEA|00|00")})
+
+	tests := []struct {
+		name   string
+		script func(t *testing.T, c *pipeClient) []Message
+		want   []string
+	}{
+		{
+			name: "command before HELLO",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				SendMessage(c, FileStartCmd, []byte("report.txt|1|1"))
+				return c.finish()
+			},
+			want: []string{},
+		},
+		{
+			name: "oversized command length",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.Write([]byte{0xff, 0xff})
+				return c.finish()
+			},
+			want: []string{"ERROR COMMAND_TOO_LONG"},
+		},
+		{
+			name: "empty command",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.Write([]byte{0x00, 0x00})
+				return c.finish()
+			},
+			want: []string{"ERROR COMMAND_TOO_LONG"},
+		},
+		{
+			name: "oversized payload length",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.Write([]byte{0x00, 0x05, 'H', 'E', 'L', 'L', 'O', 0xff, 0xff, 0xff, 0xff})
+				return c.finish()
+			},
+			want: []string{"ERROR FRAME_TOO_LARGE"},
+		},
+		{
+			name: "truncated HELLO",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				c.Write(hello[:len(hello)/2])
+				return c.hangUp()
+			},
+			want: []string{},
+		},
+		{
+			name: "malformed HELLO",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				SendMessage(c, HelloCmd, []byte("v=2;alg=LEA|abcd"))
+				return c.finish()
+			},
+			want: []string{"ERROR PROTOCOL_ERROR"},
+		},
+		{
+			name: "protocol version 1 HELLO",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				// HELLO klijenta pre razmene ključeva i mogućnosti
+				SendMessage(c, HelloCmd, []byte("LEA-PCBC,SHA256"))
+				msgs := c.finish()
+				if len(msgs) == 1 && !strings.Contains(string(msgs[0].Payload), "protocol version 1 is not supported") {
+					t.Errorf("unreadable ERROR message %q", msgs[0].Payload)
+				}
+				return msgs
+			},
+			want: []string{"ERROR UNSUPPORTED_VERSION"},
+		},
+		{
+			name: "protocol version 1 capabilities",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				_, err := clientHandshake(c, testKey, Capabilities{
+					Version:     1,
+					Algorithms:  SupportedAlgorithms,
+					Hashes:      []string{HashSHA256},
+					Compression: []string{CompressionNone},
+					MaxFrame:    MaxPacketSize,
+				})
+				if code := errorCode(err, ""); code != ErrCodeUnsupportedVersion {
+					t.Errorf("handshake with protocol version 1: got %v, want %s", err, ErrCodeUnsupportedVersion)
+				}
+				return c.finish()
+			},
+			want: []string{},
+		},
+		{
+			name: "wrong key",
+			script: func(t *testing.T, c *pipeClient) []Message {
+				err := c.handshake(bytes.Repeat([]byte{0x24}, 16))
+				if !errors.Is(err, ErrHandshakeAuth) {
+					t.Errorf("handshake with wrong key: got %v, want %v", err, ErrHandshakeAuth)
+				}
+				return c.hangUp()
+			},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newPipeClient(t, newTestServer(t))
+			got := describeAll(tt.script(t, c))
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("server sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestConformanceTransfer šalje i preuzima fajl pravim klijentom preko net.Pipe.
+func TestConformanceTransfer(t *testing.T) {
+	s := newTestServer(t)
+	s.SetDownloads(true)
+
+	content := bytes.Repeat([]byte("conformance "), 10000)
+	input := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(input, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newPipeClient(t, s)
+	client := NewTCPClient("pipe", 0)
+	client.SetRetry(0, 0)
+	client.conn = c
+
+	results, err := client.SendFiles([]FileEntry{
+		{Path: input, Name: "report.txt"},
+		{Path: input, Name: "nested/report.txt"},
+	}, "LEA-PCBC", testKey)
+	if err != nil {
+		t.Fatalf("SendFiles: %v", err)
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("%s: %v", result.Name, result.Err)
+		}
+	}
+	if rest := c.finish(); len(rest) != 0 {
+		t.Errorf("unexpected messages after SUMMARY: %q", describeAll(rest))
+	}
+
+	for _, name := range []string{"report.txt", "nested/report.txt"} {
+		received, err := os.ReadFile(filepath.Join(s.outputDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(received, content) {
+			t.Errorf("%s: received content differs from the sent file", name)
+		}
+	}
+
+	c = newPipeClient(t, s)
+	client = NewTCPClient("pipe", 0)
+	client.conn = c
+
+	files, err := client.ListFiles("LEA-PCBC", testKey)
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("ListFiles returned %d files, want 2", len(files))
+	}
+
+	output := filepath.Join(t.TempDir(), "downloaded.txt")
+	if err := client.GetFile("nested/report.txt", output, "LEA-PCBC", testKey); err != nil {
+		t.Fatalf("GetFile: %v", err)
+	}
+	downloaded, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Error("downloaded content differs from the sent file")
+	}
+	c.hangUp()
+}
+
+// newContainer šifruje input ključem testKey i vraća zaglavlje i ceo kontejner.
+func newContainer(t testing.TB, processor *core.FileProcessor, input string) (*core.Metadata, []byte) {
+	t.Helper()
+	stream, err := processor.NewEncryptedStream(input, "LEA-PCBC", testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var container bytes.Buffer
+	if _, err := stream.CopyTo(&container, 0); err != nil {
+		t.Fatal(err)
+	}
+	return stream.Metadata, container.Bytes()
+}
+
+// sendContainer šalje jedan fajl kao klijent: FILE_START, METADATA, podaci i FILE_END.
+func sendContainer(c *pipeClient, name string, metadataJSON, container []byte) {
+	c.send(FileStartCmd, []byte(name+"|"+strconv.Itoa(len(container))+"|"+strconv.Itoa(len(metadataJSON))))
+	c.send("METADATA", metadataJSON)
+	c.send(FileDataCmd, container)
+	c.send(FileEndCmd, nil)
+}
+
+// TestConformanceHeaderMismatch šalje ispravno potpisan kontejner sa METADATA
+// porukom koja se ne slaže sa zaglavljem; server ga ne sme sačuvati.
+func TestConformanceHeaderMismatch(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(input, []byte("signed content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		start  string
+		forge  func(m *core.Metadata)
+		want   string
+		stored string
+	}{
+		{
+			name:   "matching metadata",
+			start:  "report.txt",
+			forge:  func(m *core.Metadata) {},
+			want:   "SUCCESS",
+			stored: "report.txt",
+		},
+		{
+			name:  "different file name",
+			start: "evil.sh",
+			forge: func(m *core.Metadata) { m.Filename = "evil.sh" },
+			want:  "ERROR INVALID_FILENAME",
+		},
+		{
+			name:  "different algorithm",
+			start: "report.txt",
+			forge: func(m *core.Metadata) { m.EncryptionAlgorithm = "LEA" },
+			want:  "ERROR VERIFICATION_FAILED",
+		},
+		{
+			name:  "different key fingerprint",
+			start: "report.txt",
+			forge: func(m *core.Metadata) { m.KeyInfo = "" },
+			want:  "ERROR VERIFICATION_FAILED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := core.NewFileProcessor()
+			processor.SetSigningKey(private)
+			metadata, container := newContainer(t, processor, input)
+			forged := *metadata
+			tt.forge(&forged)
+			metadataJSON, err := forged.ToJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := newTestServer(t)
+			s.SetTrustedSigners([]ed25519.PublicKey{public})
+			c := newPipeClient(t, s)
+			if err := c.handshake(testKey); err != nil {
+				t.Fatalf("handshake: %v", err)
+			}
+			sendContainer(c, tt.start, metadataJSON, container)
+			c.send(EndCmd, nil)
+
+			got := describeAll(c.finish())
+			if len(got) == 0 || got[0] != tt.want {
+				t.Fatalf("server sent %q, want %s first", got, tt.want)
+			}
+
+			entries, err := os.ReadDir(s.outputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stored []string
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					stored = append(stored, entry.Name())
+				}
+			}
+			if strings.Join(stored, ",") != tt.stored {
+				t.Errorf("stored files %q, want %q", stored, tt.stored)
+			}
+		})
+	}
+}
+
+// TestConformanceStagingQuota proverava da server odbija nove prenose kada
+// nedovršeni prenosi klijenta pređu kvotu, a da sesija nastavlja.
+func TestConformanceStagingQuota(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(input, bytes.Repeat([]byte("quota "), 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	metadata, container := newContainer(t, core.NewFileProcessor(), input)
+	metadataJSON, err := metadata.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ograničenje koje dozvoljava ovaj fajl, ali ne i uz prenos od 900 bajtova
+	limit := int64(len(container)) + 500
+
+	tests := []struct {
+		name   string
+		limits StagingLimits
+		owner  string
+		staged int64
+		want   []string
+	}{
+		{"within quota", StagingLimits{MaxIdentity: 1 << 20, MaxTransfers: 2}, "", 100, []string{"SUCCESS", "SUMMARY 1|0"}},
+		{"too many transfers", StagingLimits{MaxTransfers: 1}, "", 100, []string{"ERROR QUOTA_EXCEEDED", "SUMMARY 0|1"}},
+		{"other client's transfers", StagingLimits{MaxIdentity: limit, MaxTransfers: 1}, "bob", 900, []string{"SUCCESS", "SUMMARY 1|0"}},
+		{"identity bytes", StagingLimits{MaxIdentity: limit}, "", 900, []string{"ERROR QUOTA_EXCEEDED", "SUMMARY 0|1"}},
+		{"total bytes", StagingLimits{MaxIdentity: limit, MaxTotal: limit}, "bob", 900, []string{"ERROR QUOTA_EXCEEDED", "SUMMARY 0|1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.SetStagingLimits(tt.limits)
+
+			// napušten prenos; klijent bez fajla klijenata ima prazan identitet
+			part, err := s.staging.create(&stagedTransfer{ID: strings.Repeat("ab", 16), Filename: "old.txt", Size: tt.staged, Identity: tt.owner})
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Close()
+
+			c := newPipeClient(t, s)
+			if err := c.handshake(testKey); err != nil {
+				t.Fatalf("handshake: %v", err)
+			}
+			sendContainer(c, "report.txt", metadataJSON, container)
+			c.send(EndCmd, nil)
+
+			got := describeAll(c.finish())
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("server sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestConformanceEventUsesHeader proverava da se podaci o primljenom fajlu
+// uzimaju iz proverenog zaglavlja, a ne iz METADATA poruke.
+func TestConformanceEventUsesHeader(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(input, []byte("event content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	metadata, container := newContainer(t, core.NewFileProcessor(), input)
+	forged := *metadata
+	forged.Hash = strings.Repeat("0", len(metadata.Hash))
+	metadataJSON, err := forged.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spool := t.TempDir()
+	s := newTestServer(t)
+	s.SetReceiveHook(ReceiveHook{SpoolDir: spool})
+	c := newPipeClient(t, s)
+	if err := c.handshake(testKey); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	sendContainer(c, "nested/report.txt", metadataJSON, container)
+	c.send(EndCmd, nil)
+	if got := describeAll(c.finish()); strings.Join(got, ", ") != "SUCCESS, SUMMARY 1|0" {
+		t.Fatalf("server sent %q", got)
+	}
+	s.wg.Wait()
+
+	events, err := filepath.Glob(filepath.Join(spool, "*.json"))
+	if err != nil || len(events) != 1 {
+		t.Fatalf("found spool events %v, %v; want one", events, err)
+	}
+	data, err := os.ReadFile(events[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event ReceiveEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Hash != metadata.Hash {
+		t.Errorf("event hash %s, want %s from the container header", event.Hash, metadata.Hash)
+	}
+	if event.File != "nested/report.txt" {
+		t.Errorf("event file %q, want nested/report.txt", event.File)
+	}
+}
+
+// newRelayTestServer vraća relay server sa klijentima alice i bob i njihove tajne.
+func newRelayTestServer(t testing.TB) (*TCPServer, map[string][]byte) {
+	t.Helper()
+	s := NewRelayServer("pipe", t.TempDir(), testKey, RelayLimits{})
+	s.SetTimeouts(2*time.Second, 2*time.Second)
+	s.active = true
+
+	registry := NewClientRegistry(filepath.Join(t.TempDir(), "clients.json"))
+	secrets := make(map[string][]byte)
+	for _, id := range []string{"alice", "bob"} {
+		secret, err := registry.Add(id, nil, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets[id] = secret
+	}
+	s.SetClients(registry)
+	return s, secrets
+}
+
+// newRecipientsContainer šifruje input za count X25519 primalaca i vraća ceo kontejner.
+func newRecipientsContainer(t testing.TB, input string, count int) ([]byte, error) {
+	t.Helper()
+	recipients := make([]*ecdh.PublicKey, count)
+	for i := range recipients {
+		private, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recipients[i] = private.PublicKey()
+	}
+
+	output := input + ".enc"
+	if err := core.NewFileProcessor().EncryptFileForRecipients(input, output, "LEA-PCBC", nil, recipients); err != nil {
+		return nil, err
+	}
+	container, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return container, nil
+}
+
+// TestConformanceRelayHeaderLimit proverava da relay prenosi zaglavlje sa
+// mnogo primalaca do zajedničkog ograničenja core.MaxHeaderSize i da veće
+// zaglavlje odbija kodiranom greškom.
+func TestConformanceRelayHeaderLimit(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(input, []byte("relay content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newRecipientsContainer(t, input, 400); !errors.Is(err, core.ErrHeaderTooLarge) {
+		t.Fatalf("encrypting for 400 recipients returned %v, want ErrHeaderTooLarge", err)
+	}
+
+	container, err := newRecipientsContainer(t, input, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata, _, err := core.ExtractFromEncryptedFile(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadataJSON, err := metadata.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := int(binary.LittleEndian.Uint32(container))
+	if header <= core.MaxHeaderSize/2 {
+		t.Fatalf("header of %d bytes is too small for this test", header)
+	}
+
+	s, secrets := newRelayTestServer(t)
+
+	t.Run("multi-recipient header", func(t *testing.T) {
+		c := newPipeClient(t, s)
+		if err := c.handshakeAs(testKey, "alice", secrets["alice"]); err != nil {
+			t.Fatalf("handshake: %v", err)
+		}
+		c.send(RecipientCmd, []byte("bob"))
+		sendContainer(c, "report.txt", metadataJSON, container)
+		c.send(EndCmd, nil)
+		if got := describeAll(c.finish()); strings.Join(got, ", ") != "SUCCESS, SUCCESS, SUMMARY 1|0" {
+			t.Fatalf("server sent %q", got)
+		}
+
+		c = newPipeClient(t, s)
+		if err := c.handshakeAs(testKey, "bob", secrets["bob"]); err != nil {
+			t.Fatalf("handshake: %v", err)
+		}
+		c.send(ListCmd, nil)
+		msg, err := c.receive()
+		if err != nil || msg.Command != ListItemCmd {
+			t.Fatalf("LIST returned %v, %v", describe(msg), err)
+		}
+		fields := strings.SplitN(string(msg.Payload), "|", 3)
+		if len(fields) != 3 {
+			t.Fatalf("malformed LIST item %q", msg.Payload)
+		}
+		if msg, err = c.receive(); err != nil || msg.Command != ListEndCmd {
+			t.Fatalf("LIST ended with %v, %v", describe(msg), err)
+		}
+
+		c.send(GetCmd, []byte(fields[2]))
+		var received []byte
+		var receivedHeader []byte
+		for {
+			msg, err := c.receive()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Command == FileEndCmd {
+				break
+			}
+			switch msg.Command {
+			case FileStartCmd:
+			case "METADATA":
+				receivedHeader = msg.Payload
+			case FileDataCmd:
+				received = append(received, msg.Payload...)
+			default:
+				t.Fatalf("GET returned %s", describe(msg))
+			}
+		}
+		c.hangUp()
+
+		if !bytes.Equal(received, container) {
+			t.Error("downloaded container differs from the uploaded one")
+		}
+		if !bytes.Equal(receivedHeader, container[4:4+header]) {
+			t.Error("METADATA differs from the container header")
+		}
+	})
+
+	t.Run("oversize header", func(t *testing.T) {
+		// zaglavlje preko ograničenja koje core više ne bi napravio
+		oversize := *metadata
+		for len(oversize.Recipients) < 400 {
+			oversize.Recipients = append(oversize.Recipients, metadata.Recipients...)
+		}
+		oversizeJSON, err := json.Marshal(&oversize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(oversizeJSON) <= core.MaxHeaderSize {
+			t.Fatalf("header of %d bytes is not over the limit", len(oversizeJSON))
+		}
+		forged := binary.LittleEndian.AppendUint32(nil, uint32(len(oversizeJSON)))
+		forged = append(forged, oversizeJSON...)
+		forged = append(forged, container[4+header:]...)
+
+		c := newPipeClient(t, s)
+		if err := c.handshakeAs(testKey, "alice", secrets["alice"]); err != nil {
+			t.Fatalf("handshake: %v", err)
+		}
+		c.send(RecipientCmd, []byte("bob"))
+		c.send(FileStartCmd, []byte("report.txt|"+strconv.Itoa(len(forged))+"|"+strconv.Itoa(len(metadataJSON))))
+		c.send("METADATA", metadataJSON)
+		for data := forged; len(data) > 0; {
+			chunk := data[:min(len(data), MaxPacketSize/2)]
+			c.send(FileDataCmd, chunk)
+			data = data[len(chunk):]
+		}
+		c.send(FileEndCmd, nil)
+		// TRANSFER_TOO_LARGE završava sesiju, kao i prekoračenje ograničenja prenosa
+		if got := describeAll(c.finish()); strings.Join(got, ", ") != "SUCCESS, ERROR TRANSFER_TOO_LARGE" {
+			t.Fatalf("server sent %q", got)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// deadlineConn postavlja rok pre svakog čitanja i pisanja, pa klijent koji
+// stane usred prenosa ne drži konekciju otvorenom zauvek.
+type deadlineConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if c.readTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	if c.writeTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	return c.Conn.Write(p)
+}
+
+// lingerTimeout je koliko dugo server nakon greške čita preostale podatke klijenta.
+const lingerTimeout = 2 * time.Second
+
+// closeLingering zatvara stranu za pisanje i kratko odbacuje preostale podatke,
+// da klijent koji još šalje primi ERROR umesto RST-a.
+func (c *deadlineConn) closeLingering() error {
+	raw := c.Conn
+	if limited, ok := raw.(*limitedConn); ok {
+		// preostali podaci se odbacuju bez ograničenja protoka
+		raw = limited.Conn
+	}
+	// TCP i Unix konekcije mogu da zatvore samo stranu za pisanje
+	if halfCloser, ok := raw.(interface {
+		net.Conn
+		CloseWrite() error
+	}); ok {
+		halfCloser.CloseWrite()
+		halfCloser.SetReadDeadline(time.Now().Add(lingerTimeout))
+		io.Copy(io.Discard, halfCloser)
+	}
+	return c.Conn.Close()
+}
+
+// isConnectionError razlikuje prekid veze (prenos može da se nastavi) od
+// greške u protokolu ili podacima.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.As(err, &netErr)
+}
@@ -0,0 +1,372 @@
+package network
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+const (
+	ListCmd     = "LIST"
+	ListItemCmd = "LIST_ITEM"
+	ListEndCmd  = "LIST_END"
+	GetCmd      = "GET"
+
+	downloadAlgorithm = "LEA-PCBC"
+)
+
+// RemoteFile je fajl u izlaznom direktorijumu servera.
+type RemoteFile struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+}
+
+// canDownload proverava da li server dozvoljava LIST/GET i da li ih klijent sme da koristi.
+func (s *TCPServer) canDownload(identity string) error {
+	if !s.allowDownload {
+		return NewProtocolError(ErrCodeForbidden, "downloads are disabled on this server")
+	}
+	if s.clientRegistry != nil && !s.clientRegistry.CanDownload(identity) {
+		return NewProtocolError(ErrCodeForbidden, "client %s is not allowed to download", identity)
+	}
+	return nil
+}
+
+// handleList šalje po jednu LIST_ITEM poruku "<veličina>|<unix vreme>|<putanja>"
+// za svaki fajl u izlaznom direktorijumu, pa LIST_END sa brojem fajlova.
+func (s *TCPServer) handleList(conn *deadlineConn, session *Session, identity string) error {
+	if err := s.canDownload(identity); err != nil {
+		return s.sendError(conn, session, ErrCodeForbidden, err)
+	}
+
+	count := 0
+	err := filepath.WalkDir(s.outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == stagingDirName {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.outputDir, path)
+		if err != nil {
+			return err
+		}
+
+		item := fmt.Sprintf("%d|%d|%s", info.Size(), info.ModTime().Unix(), filepath.ToSlash(rel))
+		if err := session.SendMessage(conn, ListItemCmd, []byte(item)); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeInternal, fmt.Errorf("failed to list files: %w", err))
+	}
+
+	logger.LogNetwork(logger.SEND_FILE, conn.RemoteAddr().String(),
+		"File list sent", true, map[string]interface{}{
+			"identity": identity,
+			"files":    count,
+		})
+
+	return session.SendMessage(conn, ListEndCmd, []byte(strconv.Itoa(count)))
+}
+
+// handleGet šifruje traženi fajl ključem servera i šalje ga istim
+// FILE_START/METADATA/FILE_DATA/FILE_END porukama kao pri slanju.
+func (s *TCPServer) handleGet(conn *deadlineConn, session *Session, identity string, name string) error {
+	remoteAddr := conn.RemoteAddr().String()
+
+	if err := s.canDownload(identity); err != nil {
+		return s.sendError(conn, session, ErrCodeForbidden, err)
+	}
+
+	relPath, err := SanitizePath(name)
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeInvalidFilename, err)
+	}
+
+	sourcePath, err := s.resolveDownload(relPath)
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeNotFound, err)
+	}
+
+	fileProcessor := core.NewFileProcessor()
+	stream, err := fileProcessor.NewEncryptedStream(sourcePath, downloadAlgorithm, s.key)
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeInternal, err)
+	}
+	metadataJSON, err := stream.Metadata.ToJSON()
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeInternal, err)
+	}
+
+	startPayload := fmt.Sprintf("%s|%d|%d", relPath, stream.Size, len(metadataJSON))
+	if err := session.SendMessage(conn, FileStartCmd, []byte(startPayload)); err != nil {
+		return err
+	}
+	if err := session.SendMessage(conn, "METADATA", metadataJSON); err != nil {
+		return err
+	}
+
+	progress := s.progress.track(Progress{
+		Name:      relPath,
+		Direction: DirectionSend,
+		Remote:    remoteAddr,
+		Total:     stream.Size,
+	})
+	data := newDataWriter(conn, session)
+	data.onChunk = func(sent int64, chunks int) {
+		progress.update(sent)
+	}
+	_, err = stream.CopyTo(data, 0)
+	if err == nil {
+		err = data.Flush()
+	}
+	if err != nil {
+		progress.finish(err)
+		if data.err != nil {
+			return err
+		}
+		// klijent prekida preuzimanje kada umesto FILE_DATA stigne ERROR
+		return s.sendError(conn, session, ErrCodeInternal, err)
+	}
+
+	if err := session.SendMessage(conn, FileEndCmd, nil); err != nil {
+		progress.finish(err)
+		return err
+	}
+	progress.finish(nil)
+
+	logger.LogNetwork(logger.SEND_FILE, remoteAddr,
+		"File sent to client", true, map[string]interface{}{
+			"identity":       identity,
+			"file":           relPath,
+			"encrypted_size": stream.Size,
+			"algorithm":      downloadAlgorithm,
+		})
+	log.Printf("File sent to %s: %s (%d bytes)", remoteAddr, relPath, stream.Size)
+	return nil
+}
+
+// resolveDownload vraća putanju fajla samo ako je običan fajl unutar
+// izlaznog direktorijuma (bez simboličkih linkova koji vode van njega).
+func (s *TCPServer) resolveDownload(relPath string) (string, error) {
+	if strings.SplitN(relPath, "/", 2)[0] == stagingDirName {
+		return "", fmt.Errorf("file not found: %s", relPath)
+	}
+
+	root, err := filepath.EvalSymlinks(s.outputDir)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", relPath)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(s.outputDir, filepath.FromSlash(relPath)))
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", relPath)
+	}
+	if !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("file not found: %s", relPath)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("file not found: %s", relPath)
+	}
+	return resolved, nil
+}
+
+// ListFiles vraća fajlove iz izlaznog direktorijuma servera.
+func (c *TCPClient) ListFiles(algorithm string, key []byte) ([]RemoteFile, error) {
+	if c.conn == nil {
+		return nil, fmt.Errorf("not connected to server")
+	}
+	if c.session == nil {
+		if err := c.openSession(algorithm, key); err != nil {
+			return nil, err
+		}
+	}
+	if !c.session.Capabilities.Has(FeatureDownload) {
+		return nil, NewProtocolError(ErrCodeForbidden, "server does not offer downloads")
+	}
+
+	if err := c.session.SendMessage(c.conn, ListCmd, nil); err != nil {
+		return nil, fmt.Errorf("failed to send LIST: %w", err)
+	}
+
+	var files []RemoteFile
+	for {
+		msg, err := c.session.ReceiveMessage(c.conn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive file list: %w", err)
+		}
+
+		switch msg.Command {
+		case ListItemCmd:
+			file, err := parseListItem(msg.Payload)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+		case ListEndCmd:
+			logger.Info(logger.CLIENT_CONNECT, "File list received", true, map[string]interface{}{
+				"address": c.address,
+				"files":   len(files),
+			})
+			return files, nil
+		case ErrorCmd:
+			return nil, fmt.Errorf("server error: %w", DecodeError(msg.Payload))
+		default:
+			return nil, fmt.Errorf("expected LIST_ITEM or LIST_END, got %s", msg.Command)
+		}
+	}
+}
+
+func parseListItem(payload []byte) (RemoteFile, error) {
+	parts := strings.SplitN(string(payload), "|", 3)
+	if len(parts) != 3 {
+		return RemoteFile{}, fmt.Errorf("malformed LIST_ITEM")
+	}
+	size, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return RemoteFile{}, fmt.Errorf("malformed LIST_ITEM size: %w", err)
+	}
+	modified, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return RemoteFile{}, fmt.Errorf("malformed LIST_ITEM time: %w", err)
+	}
+	return RemoteFile{Name: parts[2], Size: size, Modified: time.Unix(modified, 0)}, nil
+}
+
+// GetFile preuzima fajl sa servera i dekriptuje ga u outputPath. Fajl putuje
+// šifrovan ključem servera, pa key mora biti isti ključ.
+func (c *TCPClient) GetFile(name, outputPath, algorithm string, key []byte) error {
+	if c.conn == nil {
+		return fmt.Errorf("not connected to server")
+	}
+	if c.session == nil {
+		if err := c.openSession(algorithm, key); err != nil {
+			return err
+		}
+	}
+	if !c.session.Capabilities.Has(FeatureDownload) {
+		return NewProtocolError(ErrCodeForbidden, "server does not offer downloads")
+	}
+
+	if err := c.session.SendMessage(c.conn, GetCmd, []byte(name)); err != nil {
+		return fmt.Errorf("failed to send GET: %w", err)
+	}
+
+	startMsg, err := c.session.ReceiveMessage(c.conn)
+	if err != nil {
+		return fmt.Errorf("failed to receive FILE_START: %w", err)
+	}
+	if startMsg.Command == ErrorCmd {
+		return fmt.Errorf("server error: %w", DecodeError(startMsg.Payload))
+	}
+	if startMsg.Command != FileStartCmd {
+		return fmt.Errorf("expected FILE_START, got %s", startMsg.Command)
+	}
+	_, size, _, err := parseFileStart(startMsg.Payload)
+	if err != nil {
+		return fmt.Errorf("malformed FILE_START: %w", err)
+	}
+
+	progress := c.progress.track(Progress{
+		Name:      name,
+		Direction: DirectionReceive,
+		Remote:    c.address,
+		Total:     size,
+	})
+	err = c.receiveDownload(name, outputPath, size, key, progress)
+	progress.finish(err)
+	return err
+}
+
+// receiveDownload prima kontejner posle FILE_START i upisuje ga u outputPath
+// tek kada provera uspe.
+func (c *TCPClient) receiveDownload(name, outputPath string, size int64, key []byte, progress *progressTracker) error {
+	metadataMsg, err := c.session.ReceiveMessage(c.conn)
+	if err != nil {
+		return fmt.Errorf("failed to receive metadata: %w", err)
+	}
+	metadata, err := core.FromJSON(metadataMsg.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	// dekriptovan sadržaj postaje outputPath tek posle provere heša
+	partPath := outputPath + ".part"
+	file, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(partPath)
+	defer file.Close()
+
+	container := core.NewContainerWriter(file, key)
+	var received int64
+	for {
+		msg, err := c.session.ReceiveMessage(c.conn)
+		if err != nil {
+			return fmt.Errorf("failed to receive file data: %w", err)
+		}
+		if msg.Command == FileEndCmd {
+			break
+		}
+		if msg.Command == ErrorCmd {
+			return fmt.Errorf("server error: %w", DecodeError(msg.Payload))
+		}
+		if msg.Command != FileDataCmd {
+			return fmt.Errorf("expected FILE_DATA or FILE_END, got %s", msg.Command)
+		}
+		if received+int64(len(msg.Payload)) > size {
+			return fmt.Errorf("server sent more than the declared %d bytes", size)
+		}
+		if _, err := container.Write(msg.Payload); err != nil {
+			return fmt.Errorf("decryption/verification failed: %w", err)
+		}
+		received += int64(len(msg.Payload))
+		progress.update(received)
+	}
+	if received != size {
+		return fmt.Errorf("received %d of the declared %d bytes", received, size)
+	}
+	if err := container.Close(); err != nil {
+		return fmt.Errorf("decryption/verification failed: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Chmod(partPath, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, outputPath); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	logger.LogNetwork(logger.RECEIVE_FILE, c.address,
+		"File downloaded from server", true, map[string]interface{}{
+			"file":        name,
+			"output_file": outputPath,
+			"size":        metadata.Size,
+			"algorithm":   metadata.EncryptionAlgorithm,
+		})
+	return nil
+}
@@ -0,0 +1,93 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrorCode je razlog greške koji se šalje u ERROR poruci kao "<kod>|<poruka>".
+type ErrorCode string
+
+const (
+	ErrCodeProtocol           ErrorCode = "PROTOCOL_ERROR"
+	ErrCodeFrameTooLarge      ErrorCode = "FRAME_TOO_LARGE"
+	ErrCodeCommandTooLong     ErrorCode = "COMMAND_TOO_LONG"
+	ErrCodeTransferTooLarge   ErrorCode = "TRANSFER_TOO_LARGE"
+	ErrCodeTimeout            ErrorCode = "TIMEOUT"
+	ErrCodeTooManyConnections ErrorCode = "TOO_MANY_CONNECTIONS"
+	ErrCodeRateLimited        ErrorCode = "RATE_LIMITED"
+	ErrCodeAuthFailed         ErrorCode = "AUTH_FAILED"
+	ErrCodeKeyMismatch        ErrorCode = "KEY_MISMATCH"
+	ErrCodeSignatureRejected  ErrorCode = "SIGNATURE_REJECTED"
+	ErrCodeVerification       ErrorCode = "VERIFICATION_FAILED"
+	ErrCodeInvalidFilename    ErrorCode = "INVALID_FILENAME"
+	ErrCodeFileExists         ErrorCode = "FILE_EXISTS"
+	ErrCodeUnknownTransfer    ErrorCode = "UNKNOWN_TRANSFER"
+	ErrCodeNotFound           ErrorCode = "NOT_FOUND"
+	ErrCodeForbidden          ErrorCode = "FORBIDDEN"
+	ErrCodeUnsupportedVersion ErrorCode = "UNSUPPORTED_VERSION"
+	ErrCodeNoCommonAlgorithm  ErrorCode = "NO_COMMON_ALGORITHM"
+	ErrCodeQuotaExceeded      ErrorCode = "QUOTA_EXCEEDED"
+	ErrCodeShuttingDown       ErrorCode = "SHUTTING_DOWN"
+	ErrCodeInternal           ErrorCode = "INTERNAL_ERROR"
+)
+
+type ProtocolError struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func NewProtocolError(code ErrorCode, format string, args ...interface{}) *ProtocolError {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// errorCode vraća kod za grešku; fallback se koristi za greške bez koda.
+func errorCode(err error, fallback ErrorCode) ErrorCode {
+	var protocolErr *ProtocolError
+	if errors.As(err, &protocolErr) {
+		return protocolErr.Code
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrCodeTimeout
+	}
+
+	return fallback
+}
+
+func EncodeError(code ErrorCode, err error) []byte {
+	message := err.Error()
+	var protocolErr *ProtocolError
+	if errors.As(err, &protocolErr) {
+		message = protocolErr.Message
+	}
+	return []byte(string(code) + "|" + message)
+}
+
+// DecodeError čita ERROR payload; stari serveri šalju samo poruku bez koda.
+func DecodeError(payload []byte) *ProtocolError {
+	code, message, found := strings.Cut(string(payload), "|")
+	if !found || code == "" || strings.ToUpper(code) != code || strings.Contains(code, " ") {
+		return &ProtocolError{Code: ErrCodeProtocol, Message: string(payload)}
+	}
+	return &ProtocolError{Code: ErrorCode(code), Message: message}
+}
+
+// fileLevelError su greške koje odbijaju jedan fajl ili zahtev, a sesija se
+// nastavlja sa sledećim. Ostale greške zatvaraju konekciju.
+func fileLevelError(err error) bool {
+	switch errorCode(err, "") {
+	case ErrCodeKeyMismatch, ErrCodeSignatureRejected, ErrCodeVerification,
+		ErrCodeInvalidFilename, ErrCodeFileExists, ErrCodeNotFound, ErrCodeForbidden,
+		ErrCodeQuotaExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CollisionPolicy određuje šta server radi kada primljeni fajl već postoji.
+type CollisionPolicy string
+
+const (
+	CollisionRename    CollisionPolicy = "rename"
+	CollisionOverwrite CollisionPolicy = "overwrite"
+	CollisionReject    CollisionPolicy = "reject"
+)
+
+const (
+	maxFilenameLength = 255
+	maxPathDepth      = 32
+)
+
+var ErrInvalidFilename = errors.New("invalid filename")
+
+// Windows rezerviše ova imena bez obzira na ekstenziju (npr. "NUL.txt").
+var reservedDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true, "CONIN$": true, "CONOUT$": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+func ParseCollisionPolicy(value string) (CollisionPolicy, error) {
+	switch policy := CollisionPolicy(strings.ToLower(value)); policy {
+	case CollisionRename, CollisionOverwrite, CollisionReject:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown collision policy %q (use rename, overwrite or reject)", value)
+}
+
+// SanitizeFilename pretvara ime koje je poslao klijent u bezbedno ime fajla
+// unutar izlaznog direktorijuma. Apsolutne putanje, "..", imena uređaja i
+// kontrolni karakteri se odbijaju; od relativne putanje ostaje samo ime fajla.
+func SanitizeFilename(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("%w: empty name", ErrInvalidFilename)
+	}
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("%w: not valid UTF-8", ErrInvalidFilename)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("%w: control character in %q", ErrInvalidFilename, name)
+		}
+	}
+
+	normalized := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(normalized, "/") || filepath.IsAbs(name) || hasDriveLetter(normalized) {
+		return "", fmt.Errorf("%w: absolute path %q", ErrInvalidFilename, name)
+	}
+
+	parts := strings.Split(normalized, "/")
+	for _, part := range parts {
+		if part == ".." {
+			return "", fmt.Errorf("%w: parent directory reference in %q", ErrInvalidFilename, name)
+		}
+	}
+
+	base := parts[len(parts)-1]
+	// Windows ignoriše tačke i razmake na kraju imena
+	trimmed := strings.TrimRight(base, ". ")
+	if trimmed == "" {
+		return "", fmt.Errorf("%w: %q has no file name", ErrInvalidFilename, name)
+	}
+	if strings.ContainsAny(base, `<>:"|?*`) {
+		return "", fmt.Errorf("%w: reserved character in %q", ErrInvalidFilename, name)
+	}
+
+	stem, _, _ := strings.Cut(trimmed, ".")
+	if reservedDeviceNames[strings.ToUpper(strings.TrimSpace(stem))] {
+		return "", fmt.Errorf("%w: %q is a device name", ErrInvalidFilename, name)
+	}
+
+	if len(base) > maxFilenameLength {
+		return "", fmt.Errorf("%w: name longer than %d bytes", ErrInvalidFilename, maxFilenameLength)
+	}
+
+	return base, nil
+}
+
+// SanitizePath proverava relativnu putanju fajla iz direktorijuma koji klijent
+// šalje. Svaki deo mora da prođe SanitizeFilename; rezultat koristi "/".
+func SanitizePath(name string) (string, error) {
+	normalized := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(normalized, "/") || filepath.IsAbs(name) || hasDriveLetter(normalized) {
+		return "", fmt.Errorf("%w: absolute path %q", ErrInvalidFilename, name)
+	}
+
+	var parts []string
+	for _, part := range strings.Split(normalized, "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		clean, err := SanitizeFilename(part)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, clean)
+	}
+
+	if len(parts) == 0 {
+		return "", fmt.Errorf("%w: %q has no file name", ErrInvalidFilename, name)
+	}
+	if parts[0] == stagingDirName {
+		return "", fmt.Errorf("%w: %s is reserved for the server", ErrInvalidFilename, stagingDirName)
+	}
+	if len(parts) > maxPathDepth {
+		return "", fmt.Errorf("%w: more than %d directory levels", ErrInvalidFilename, maxPathDepth)
+	}
+
+	return strings.Join(parts, "/"), nil
+}
+
+func hasDriveLetter(path string) bool {
+	return len(path) >= 2 && path[1] == ':' &&
+		((path[0] >= 'a' && path[0] <= 'z') || (path[0] >= 'A' && path[0] <= 'Z'))
+}
+
+// reserveOutputPath vraća putanju za dekriptovani fajl prema politici kolizija.
+// Za rename i reject fajl se odmah kreira (O_EXCL), pa dve konekcije ne mogu
+// dobiti isto ime; pozivalac ga briše ako dekripcija ne uspe.
+func reserveOutputPath(dir, name string, policy CollisionPolicy) (string, error) {
+	return claimOutputPath(dir, name, policy, func(candidate string) error {
+		file, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			file.Close()
+		}
+		return err
+	})
+}
+
+// publishOutputPath premešta gotov fajl src pod ime name u dir prema politici
+// kolizija. Za rename i reject ime se zauzima hard linkom, koji ne uspeva ako
+// ime postoji, pa se fajl pojavljuje tek ceo i bez praznog fajla koji bi
+// LIST i GET videli dok prenos traje.
+func publishOutputPath(src, dir, name string, policy CollisionPolicy) (string, error) {
+	path, err := claimOutputPath(dir, name, policy, func(candidate string) error {
+		return os.Link(src, candidate)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if policy != CollisionOverwrite {
+		// fajl je već objavljen; zaostali .out briše removeOutputs
+		os.Remove(src)
+		return path, nil
+	}
+	if err := os.Rename(src, path); err != nil {
+		return "", fmt.Errorf("failed to move file to output directory: %w", err)
+	}
+	return path, nil
+}
+
+// claimOutputPath traži slobodno ime za name i zauzima ga funkcijom claim,
+// koja mora da vrati os.ErrExist ako ime postoji. Za overwrite se ime ne zauzima.
+func claimOutputPath(dir, name string, policy CollisionPolicy, claim func(candidate string) error) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	if policy == CollisionOverwrite {
+		return path, nil
+	}
+
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+
+	for i := 0; ; i++ {
+		candidate := path
+		if i > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+		}
+
+		err := claim(candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", fmt.Errorf("failed to create output file: %w", err)
+		}
+
+		if policy == CollisionReject {
+			return "", NewProtocolError(ErrCodeFileExists, "file %s already exists on server", name)
+		}
+		if i >= 1000 {
+			return "", fmt.Errorf("no free name for %s after %d attempts", name, i)
+		}
+	}
+}
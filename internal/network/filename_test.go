@@ -0,0 +1,77 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPublishOutputPath(t *testing.T) {
+	tests := []struct {
+		policy  CollisionPolicy
+		want    string
+		content string
+		code    ErrorCode
+	}{
+		{policy: CollisionRename, want: "docs/report (1).txt", content: "new"},
+		{policy: CollisionOverwrite, want: "docs/report.txt", content: "new"},
+		{policy: CollisionReject, code: ErrCodeFileExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			dir := t.TempDir()
+			existing := filepath.Join(dir, "docs", "report.txt")
+			if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(existing, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			src := filepath.Join(t.TempDir(), "staged.out")
+			if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			path, err := publishOutputPath(src, dir, "docs/report.txt", tt.policy)
+			if tt.code != "" {
+				if errorCode(err, "") != tt.code {
+					t.Fatalf("got %v, want code %s", err, tt.code)
+				}
+				if data, _ := os.ReadFile(existing); string(data) != "old" {
+					t.Errorf("existing file changed to %q", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("publishOutputPath: %v", err)
+			}
+
+			if path != filepath.Join(dir, filepath.FromSlash(tt.want)) {
+				t.Errorf("published to %s, want %s", path, tt.want)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.content {
+				t.Errorf("published file contains %q, want %q", data, tt.content)
+			}
+			if _, err := os.Stat(src); !os.IsNotExist(err) {
+				t.Errorf("staged file still exists: %v", err)
+			}
+		})
+	}
+}
+
+// TestPublishOutputPathNoPlaceholder proverava da se ime ne zauzima praznim
+// fajlom: dok fajl nije objavljen, u direktorijumu nema ničega.
+func TestPublishOutputPathNoPlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := publishOutputPath(filepath.Join(t.TempDir(), "missing.out"), dir, "report.txt", CollisionRename); err == nil {
+		t.Fatal("publishing a missing file succeeded")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("output directory contains %d entries after a failed publish", len(entries))
+	}
+}
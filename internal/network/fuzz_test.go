@@ -0,0 +1,198 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+)
+
+// addGoldenSeeds dodaje okvire iz testdata/frames kao početni korpus.
+func addGoldenSeeds(f *testing.F, prefix string) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "frames", prefix+"*.golden"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		frame, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			f.Fatalf("%s: %v", path, err)
+		}
+		f.Add(frame)
+	}
+}
+
+func FuzzDecodeMessage(f *testing.F) {
+	addGoldenSeeds(f, "")
+	f.Add([]byte{})
+	f.Add([]byte{0x00, 0x00})
+	f.Add([]byte{0xff, 0xff})
+	f.Add([]byte{0x00, 0x03, 'E', 'N', 'D', 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader := bytes.NewReader(data)
+		msg, err := DecodeMessage(reader)
+		if err != nil {
+			return
+		}
+
+		if len(msg.Command) == 0 || len(msg.Command) > MaxCommandLength {
+			t.Fatalf("accepted command of %d bytes", len(msg.Command))
+		}
+		if len(msg.Payload) > MaxPacketSize {
+			t.Fatalf("accepted payload of %d bytes", len(msg.Payload))
+		}
+
+		// prihvaćen okvir se mora kodirati u tačno pročitane bajtove
+		consumed := data[:len(data)-reader.Len()]
+		if !bytes.Equal(EncodeMessage(msg), consumed) {
+			t.Fatalf("re-encoded frame differs from input %x", consumed)
+		}
+	})
+}
+
+func FuzzSessionDecodeMessage(f *testing.F) {
+	addGoldenSeeds(f, "sealed_")
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sender, receiver := goldenSession(t)
+		reader := bytes.NewReader(data)
+
+		for {
+			before := len(data) - reader.Len()
+			msg, err := receiver.DecodeMessage(reader)
+			if err != nil {
+				return
+			}
+
+			// prihvataju se samo okviri koje je pošiljalac zaista zapečatio tim redom
+			consumed := data[before : len(data)-reader.Len()]
+			if !bytes.Equal(sender.EncodeMessage(msg), consumed) {
+				t.Fatalf("accepted a frame the sender did not produce: %x", consumed)
+			}
+		}
+	})
+}
+
+// fuzzCommands su komande koje FuzzServerStateMachine šalje posle handshake-a.
+var fuzzCommands = []string{
+	FileStartCmd, "METADATA", FileDataCmd, FileEndCmd, ResumeCmd,
+	ListCmd, GetCmd, EndCmd, RecipientCmd, DeleteCmd, HelloCmd, "BOGUS",
+}
+
+// serverCommands su sve komande koje server sme da pošalje klijentu.
+var serverCommands = []string{
+	ErrorCmd, SuccessCmd, SummaryCmd, ResumeOKCmd, ListItemCmd, ListEndCmd,
+	FileStartCmd, "METADATA", FileDataCmd, FileEndCmd,
+}
+
+// encodeScript zapisuje poruke u oblik koji čita decodeScript.
+func encodeScript(messages ...Message) []byte {
+	var script []byte
+	for _, msg := range messages {
+		script = append(script, byte(slices.Index(fuzzCommands, msg.Command)))
+		script = binary.BigEndian.AppendUint16(script, uint16(len(msg.Payload)))
+		script = append(script, msg.Payload...)
+	}
+	return script
+}
+
+// decodeScript pretvara ulaz fuzzera u niz poruka: bajt bira komandu, dva
+// bajta dužinu, a ostatak je payload (skraćen ako ulaz ranije završi).
+func decodeScript(data []byte) []Message {
+	var messages []Message
+	for len(data) >= 3 {
+		command := fuzzCommands[int(data[0])%len(fuzzCommands)]
+		length := int(binary.BigEndian.Uint16(data[1:3]))
+		data = data[3:]
+		length = min(length, len(data))
+		messages = append(messages, Message{Command: command, Payload: data[:length]})
+		data = data[length:]
+	}
+	return messages
+}
+
+// validTransferScript je ispravan prenos jednog fajla, kao početna tačka za fuzzer.
+func validTransferScript(f *testing.F) []byte {
+	input := filepath.Join(f.TempDir(), "seed.txt")
+	if err := os.WriteFile(input, []byte("fuzz seed content"), 0644); err != nil {
+		f.Fatal(err)
+	}
+	stream, err := core.NewFileProcessor().NewEncryptedStream(input, "LEA-PCBC", testKey)
+	if err != nil {
+		f.Fatal(err)
+	}
+	metadataJSON, err := stream.Metadata.ToJSON()
+	if err != nil {
+		f.Fatal(err)
+	}
+	var container bytes.Buffer
+	if _, err := stream.CopyTo(&container, 0); err != nil {
+		f.Fatal(err)
+	}
+
+	start := []byte(strings.Join([]string{
+		"seed.txt", strconv.FormatInt(stream.Size, 10), strconv.Itoa(len(metadataJSON)), strings.Repeat("5a", 16),
+	}, "|"))
+	return encodeScript(
+		Message{Command: FileStartCmd, Payload: start},
+		Message{Command: "METADATA", Payload: metadataJSON},
+		Message{Command: FileDataCmd, Payload: container.Bytes()},
+		Message{Command: FileEndCmd},
+		Message{Command: EndCmd},
+	)
+}
+
+// FuzzServerStateMachine šalje serveru proizvoljan niz zapečaćenih poruka
+// posle ispravnog handshake-a. Server mora da završi konekciju, da odgovara
+// samo poznatim komandama i da ne ostavi dekriptovan deo fajla u staging-u.
+func FuzzServerStateMachine(f *testing.F) {
+	f.Add(validTransferScript(f))
+	f.Add(encodeScript(Message{Command: EndCmd}))
+	f.Add(encodeScript(Message{Command: ListCmd}, Message{Command: GetCmd, Payload: []byte("hello.txt")}, Message{Command: EndCmd}))
+	f.Add(encodeScript(Message{Command: FileStartCmd, Payload: []byte("a.txt|10|5")}, Message{Command: FileDataCmd, Payload: []byte("0123456789")}))
+	f.Add(encodeScript(Message{Command: ResumeCmd, Payload: []byte(strings.Repeat("ab", 16))}, Message{Command: EndCmd}))
+	f.Add(encodeScript(Message{Command: GetCmd, Payload: []byte("../../etc/passwd")}, Message{Command: DeleteCmd, Payload: []byte("x")}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := newTestServer(t)
+		s.SetDownloads(true)
+		s.SetLimits(DefaultMaxConnections, 1<<20)
+		if err := os.WriteFile(filepath.Join(s.outputDir, "hello.txt"), []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		c := newPipeClient(t, s)
+		if err := c.handshake(testKey); err != nil {
+			t.Fatalf("handshake: %v", err)
+		}
+		for _, msg := range decodeScript(data) {
+			if err := c.send(msg.Command, msg.Payload); err != nil {
+				break
+			}
+		}
+
+		for _, msg := range c.hangUp() {
+			if !slices.Contains(serverCommands, msg.Command) {
+				t.Fatalf("server sent unexpected command %q", msg.Command)
+			}
+		}
+
+		leftovers, _ := filepath.Glob(filepath.Join(s.staging.dir, "*.out"))
+		if len(leftovers) > 0 {
+			t.Fatalf("decrypted data left in staging: %v", leftovers)
+		}
+	})
+}
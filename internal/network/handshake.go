@@ -0,0 +1,250 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+)
+
+const (
+	AuthCmd = "AUTH"
+
+	handshakeNonceSize = 32
+	handshakeAuthInfo  = "zastitaprojekat handshake auth v1"
+	sessionKeysInfo    = "zastitaprojekat session keys v1"
+)
+
+var ErrHandshakeAuth = errors.New("handshake authentication failed: peers do not share the same key")
+
+// handshakeState čuva efemerni ključ i transkript jedne strane.
+type handshakeState struct {
+	authKey    []byte
+	ephemeral  *ecdh.PrivateKey
+	transcript []byte
+}
+
+func newHandshakeState(psk []byte) (*handshakeState, error) {
+	authKey, err := hkdf.Key(sha256.New, psk, nil, handshakeAuthInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive handshake key: %w", err)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	return &handshakeState{authKey: authKey, ephemeral: ephemeral}, nil
+}
+
+// offer je deo HELLO/READY poruke: "<mogućnosti>|<efemerni ključ>|<nonce>".
+// MAC nad transkriptom pokriva i mogućnosti, pa ih napadač ne može oboriti.
+func (h *handshakeState) offer(capabilities string) (string, error) {
+	nonce := make([]byte, handshakeNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return fmt.Sprintf("%s|%x|%x", capabilities, h.ephemeral.PublicKey().Bytes(), nonce), nil
+}
+
+func (h *handshakeState) mac(role string) []byte {
+	mac := sha256.HMAC(h.authKey, append([]byte(role+"|"), h.transcript...))
+	return mac[:]
+}
+
+func (h *handshakeState) verifyMAC(role string, encoded string) error {
+	received, err := hex.DecodeString(encoded)
+	if err != nil || !hmac.Equal(received, h.mac(role)) {
+		return ErrHandshakeAuth
+	}
+	return nil
+}
+
+// sessionKeys izvodi ključeve sesije iz efemerne razmene; statički ključ
+// služi samo za autentifikaciju, pa njegovo otkrivanje ne otkriva stare sesije.
+func (h *handshakeState) sessionKeys(peerOffer string, isClient bool) (*Session, error) {
+	_, peerKeyHex, _, err := parseOffer(peerOffer)
+	if err != nil {
+		return nil, err
+	}
+
+	peerKeyBytes, err := hex.DecodeString(peerKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer ephemeral key: %w", err)
+	}
+	peerKey, err := ecdh.X25519().NewPublicKey(peerKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer ephemeral key: %w", err)
+	}
+
+	shared, err := h.ephemeral.ECDH(peerKey)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+
+	transcriptHash := sha256.HashBytes(h.transcript)
+	keys, err := hkdf.Key(sha256.New, shared, transcriptHash[:], sessionKeysInfo, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive session keys: %w", err)
+	}
+
+	clientKey, serverKey := keys[:32], keys[32:]
+	if isClient {
+		return newSession(clientKey, serverKey, transcriptHash[:8])
+	}
+	return newSession(serverKey, clientKey, transcriptHash[:8])
+}
+
+func parseOffer(offer string) (string, string, string, error) {
+	parts := strings.Split(offer, "|")
+	if len(parts) != 3 {
+		return "", "", "", NewProtocolError(ErrCodeProtocol, "malformed handshake message")
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// clientHandshake: HELLO -> READY(+MAC servera) -> AUTH(MAC klijenta).
+// Sesija nosi mogućnosti oko kojih su se strane dogovorile.
+func clientHandshake(rw io.ReadWriter, psk []byte, local Capabilities) (*Session, error) {
+	state, err := newHandshakeState(psk)
+	if err != nil {
+		return nil, err
+	}
+
+	hello, err := state.offer(local.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := SendMessage(rw, HelloCmd, []byte(hello)); err != nil {
+		return nil, fmt.Errorf("failed to send HELLO: %w", err)
+	}
+
+	msg, err := ReceiveMessage(rw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive READY: %w", err)
+	}
+	if msg.Command == ErrorCmd {
+		return nil, fmt.Errorf("server error: %w", DecodeError(msg.Payload))
+	}
+	if msg.Command != ReadyCmd {
+		return nil, fmt.Errorf("expected READY, got %s", msg.Command)
+	}
+
+	idx := bytes.LastIndexByte(msg.Payload, '|')
+	if idx == -1 {
+		return nil, fmt.Errorf("malformed READY message")
+	}
+	ready := string(msg.Payload[:idx])
+	serverOffer, _, _, err := parseOffer(ready)
+	if err != nil {
+		return nil, err
+	}
+
+	state.transcript = []byte(hello + "|" + ready)
+	if err := state.verifyMAC("server", string(msg.Payload[idx+1:])); err != nil {
+		return nil, err
+	}
+
+	// server šalje već izabran skup, negotiate ga proverava
+	serverCapabilities, err := ParseCapabilities(serverOffer)
+	if err != nil {
+		return nil, err
+	}
+	agreed, err := negotiate(local, serverCapabilities)
+	if err != nil {
+		SendMessage(rw, ErrorCmd, EncodeError(errorCode(err, ErrCodeProtocol), err))
+		return nil, err
+	}
+
+	session, err := state.sessionKeys(ready, true)
+	if err != nil {
+		return nil, err
+	}
+	session.Capabilities = agreed
+
+	if err := SendMessage(rw, AuthCmd, []byte(hex.EncodeToString(state.mac("client")))); err != nil {
+		return nil, fmt.Errorf("failed to send AUTH: %w", err)
+	}
+
+	return session, nil
+}
+
+// serverHandshake bira zajedničke mogućnosti iz HELLO poruke i šalje ih u READY.
+func serverHandshake(rw io.ReadWriter, psk []byte, local Capabilities) (*Session, error) {
+	msg, err := ReceiveMessage(rw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive HELLO: %w", err)
+	}
+	if msg.Command != HelloCmd {
+		return nil, fmt.Errorf("expected HELLO, got %s", msg.Command)
+	}
+
+	hello := string(msg.Payload)
+	if !strings.Contains(hello, "|") {
+		// klijent verzije 1 šalje samo "<algoritam>,SHA256"
+		return nil, NewProtocolError(ErrCodeUnsupportedVersion,
+			"protocol version 1 is not supported, update the client to protocol version %d", ProtocolVersion)
+	}
+	clientOffer, _, _, err := parseOffer(hello)
+	if err != nil {
+		return nil, err
+	}
+
+	clientCapabilities, err := ParseCapabilities(clientOffer)
+	if err != nil {
+		return nil, err
+	}
+	agreed, err := negotiate(local, clientCapabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := newHandshakeState(psk)
+	if err != nil {
+		return nil, err
+	}
+
+	ready, err := state.offer(agreed.String())
+	if err != nil {
+		return nil, err
+	}
+	state.transcript = []byte(hello + "|" + ready)
+
+	readyPayload := ready + "|" + hex.EncodeToString(state.mac("server"))
+	if err := SendMessage(rw, ReadyCmd, []byte(readyPayload)); err != nil {
+		return nil, fmt.Errorf("failed to send READY: %w", err)
+	}
+
+	msg, err = ReceiveMessage(rw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive AUTH: %w", err)
+	}
+	if msg.Command == ErrorCmd {
+		// klijent ne prihvata izabrane mogućnosti
+		return nil, fmt.Errorf("client rejected handshake: %s", DecodeError(msg.Payload))
+	}
+	if msg.Command != AuthCmd {
+		return nil, fmt.Errorf("expected AUTH, got %s", msg.Command)
+	}
+	if err := state.verifyMAC("client", string(msg.Payload)); err != nil {
+		return nil, err
+	}
+
+	session, err := state.sessionKeys(hello, false)
+	if err != nil {
+		return nil, err
+	}
+	session.Capabilities = agreed
+
+	return session, nil
+}
@@ -0,0 +1,226 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+const (
+	DefaultHookTimeout = 30 * time.Second
+
+	// hookOutputLimit je broj poslednjih bajtova izlaza komande koji se beleže u log.
+	hookOutputLimit = 2048
+
+	eventIDSize = 16
+)
+
+// ReceiveHook određuje šta server radi posle svakog uspešno primljenog
+// fajla: pokreće Command sa podacima o fajlu u promenljivama okruženja
+// CRYPTO_* i/ili upisuje JSON događaj u SpoolDir. Komanda koja traje duže
+// od Timeout se prekida (0 = bez roka).
+type ReceiveHook struct {
+	Command  string
+	SpoolDir string
+	Timeout  time.Duration
+}
+
+// ReceiveEvent opisuje primljen fajl. ID bira server za svaki događaj, a
+// TransferID je identifikator prenosa koji klijent može i sam da zada pri
+// nastavku. Hash je heš šifrovanih podataka iz zaglavlja, proveren pri prijemu.
+type ReceiveEvent struct {
+	ID            string    `json:"id"`
+	TransferID    string    `json:"transfer_id"`
+	File          string    `json:"file"`
+	Path          string    `json:"path"`
+	Size          int64     `json:"size"`
+	Sender        string    `json:"sender,omitempty"`
+	RemoteAddr    string    `json:"remote_addr"`
+	Algorithm     string    `json:"algorithm"`
+	HashAlgorithm string    `json:"hash_algorithm,omitempty"`
+	Hash          string    `json:"hash,omitempty"`
+	Signer        string    `json:"signer,omitempty"`
+	Encrypted     bool      `json:"encrypted"`
+	Received      time.Time `json:"received"`
+}
+
+// SetReceiveHook postavlja akcije posle prijema fajla. Izvršavaju se u
+// pozadini, pa ne usporavaju odgovor klijentu, a gašenje servera ih čeka.
+func (s *TCPServer) SetReceiveHook(hook ReceiveHook) {
+	s.hook = hook
+}
+
+// postReceive pokreće akcije za primljen fajl; poziva se iz obrade konekcije.
+func (s *TCPServer) postReceive(event ReceiveEvent) {
+	if s.hook.Command == "" && s.hook.SpoolDir == "" {
+		return
+	}
+
+	id, err := newEventID()
+	if err != nil {
+		logger.Error(logger.POST_RECEIVE, "Failed to create receive event", map[string]interface{}{
+			"file":  event.File,
+			"error": err.Error(),
+		})
+		log.Printf("Post-receive actions for %s skipped: %v", event.File, err)
+		return
+	}
+	event.ID = id
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if s.hook.SpoolDir != "" {
+			s.writeSpoolEvent(event)
+		}
+		if s.hook.Command != "" {
+			s.runHookCommand(event)
+		}
+	}()
+}
+
+// newEventID vraća nasumičan identifikator događaja.
+func newEventID() (string, error) {
+	id := make([]byte, eventIDSize)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return "", fmt.Errorf("failed to generate event id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// writeSpoolEvent upisuje događaj kao <id>.json. Fajl se upisuje pod
+// privremenim imenom i povezuje pod konačnim, da ga čitač ne bi video napola
+// upisanog; os.Link ne prepisuje postojeći fajl, pa se raniji događaj ne gubi.
+func (s *TCPServer) writeSpoolEvent(event ReceiveEvent) {
+	path := filepath.Join(s.hook.SpoolDir, event.ID+".json")
+
+	err := func() error {
+		data, err := json.MarshalIndent(event, "", "  ")
+		if err != nil {
+			return err
+		}
+		tmp := filepath.Join(s.hook.SpoolDir, "."+event.ID+".json.tmp")
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return err
+		}
+		defer os.Remove(tmp)
+		return os.Link(tmp, path)
+	}()
+
+	if err != nil {
+		logger.Error(logger.POST_RECEIVE, "Failed to write spool event", map[string]interface{}{
+			"file":      event.File,
+			"spool_dir": s.hook.SpoolDir,
+			"error":     err.Error(),
+		})
+		log.Printf("Post-receive spool event for %s failed: %v", event.File, err)
+		return
+	}
+
+	logger.Info(logger.POST_RECEIVE, "Spool event written", true, map[string]interface{}{
+		"file":       event.File,
+		"event_file": path,
+	})
+}
+
+// runHookCommand izvršava komandu kroz shell i beleži ishod, trajanje i kraj izlaza.
+func (s *TCPServer) runHookCommand(event ReceiveEvent) {
+	ctx := context.Background()
+	if s.hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.hook.Timeout)
+		defer cancel()
+	}
+
+	cmd := hookShellCommand(ctx, s.hook.Command)
+	cmd.Env = append(os.Environ(), event.environment()...)
+	output := &tailBuffer{limit: hookOutputLimit}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// potprocesi koji drže izlaz otvorenim ne smeju da zadrže hook posle roka
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	duration := time.Since(start).Round(time.Millisecond)
+
+	details := map[string]interface{}{
+		"file":      event.File,
+		"command":   s.hook.Command,
+		"exit_code": cmd.ProcessState.ExitCode(),
+		"duration":  duration.String(),
+		"output":    output.String(),
+	}
+
+	if err == nil {
+		logger.Info(logger.POST_RECEIVE, "Post-receive command succeeded", true, details)
+		return
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", s.hook.Timeout)
+	}
+	details["error"] = err.Error()
+	logger.Error(logger.POST_RECEIVE, "Post-receive command failed", details)
+	log.Printf("Post-receive command for %s failed: %v", event.File, err)
+}
+
+// environment vraća promenljive okruženja sa podacima o fajlu za komandu.
+func (e ReceiveEvent) environment() []string {
+	encrypted := "0"
+	if e.Encrypted {
+		encrypted = "1"
+	}
+	return []string{
+		"CRYPTO_EVENT_ID=" + e.ID,
+		"CRYPTO_TRANSFER_ID=" + e.TransferID,
+		"CRYPTO_FILE=" + e.File,
+		"CRYPTO_PATH=" + e.Path,
+		"CRYPTO_SIZE=" + strconv.FormatInt(e.Size, 10),
+		"CRYPTO_SENDER=" + e.Sender,
+		"CRYPTO_REMOTE_ADDR=" + e.RemoteAddr,
+		"CRYPTO_ALGORITHM=" + e.Algorithm,
+		"CRYPTO_HASH_ALGORITHM=" + e.HashAlgorithm,
+		"CRYPTO_HASH=" + e.Hash,
+		"CRYPTO_SIGNER=" + e.Signer,
+		"CRYPTO_ENCRYPTED=" + encrypted,
+	}
+}
+
+func hookShellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// tailBuffer čuva samo poslednjih limit bajtova upisanog izlaza.
+type tailBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf.Write(p)
+	if extra := t.buf.Len() - t.limit; extra > 0 {
+		t.buf.Next(extra)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(bytes.TrimSpace(t.buf.Bytes()))
+}
@@ -0,0 +1,56 @@
+package network
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSpoolEventsNotOverwritten proverava da dva događaja sa istim
+// identifikatorom prenosa ostaju u SpoolDir kao dva fajla.
+func TestSpoolEventsNotOverwritten(t *testing.T) {
+	spool := t.TempDir()
+	s := newTestServer(t)
+	s.SetReceiveHook(ReceiveHook{SpoolDir: spool})
+
+	transferID, err := newTransferID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"first.txt", "second.txt"} {
+		s.postReceive(ReceiveEvent{TransferID: transferID, File: name})
+	}
+	s.wg.Wait()
+
+	events, err := filepath.Glob(filepath.Join(spool, "*.json"))
+	if err != nil || len(events) != 2 {
+		t.Fatalf("found spool events %v, %v; want two", events, err)
+	}
+	files := map[string]bool{}
+	for _, path := range events {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var event ReceiveEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.TransferID != transferID {
+			t.Errorf("%s: transfer id %q, want %q", path, event.TransferID, transferID)
+		}
+		if filepath.Base(path) != event.ID+".json" {
+			t.Errorf("%s: file name does not match event id %q", path, event.ID)
+		}
+		files[event.File] = true
+	}
+	if !files["first.txt"] || !files["second.txt"] {
+		t.Errorf("spool events are for %v, want first.txt and second.txt", files)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(spool, ".*.tmp"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left in spool: %v", leftovers)
+	}
+}
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+)
+
+// EncryptedSuffix se dodaje imenu fajla koji server u inbox režimu čuva šifrovan.
+const EncryptedSuffix = ".enc"
+
+// SetInbox uključuje inbox režim: server proverava heš i potpis primljenog
+// kontejnera, ali ga ne dekriptuje, već ga čuva kao <ime>.enc, pa otvoren
+// tekst postoji tek kada operater pokrene decrypt-file. Sa storageKey se
+// ključ podataka premotava pod taj ključ (šifrat ostaje isti), pa za
+// dekripciju više nije dovoljan ključ kojim klijenti šalju fajlove.
+func (s *TCPServer) SetInbox(enabled bool, storageKey []byte) {
+	s.inbox = enabled
+	s.storageKey = storageKey
+}
+
+// storedName vraća ime pod kojim se primljeni fajl čuva u izlaznom direktorijumu.
+func (s *TCPServer) storedName(filename string) string {
+	if s.inbox {
+		return filename + EncryptedSuffix
+	}
+	return filename
+}
+
+// storageKeyInfo vraća otisak ključa pod kojim su sačuvani fajlovi.
+func (s *TCPServer) storageKeyInfo() string {
+	if s.storageKey != nil {
+		return core.KeyFingerprint(s.storageKey)
+	}
+	return core.KeyFingerprint(s.key)
+}
+
+// checkStorable rano odbija fajl čiji ključ podataka ne može da se premota
+// pod ključ za čuvanje (stari kontejneri i fajlovi samo za primaoce).
+func (s *TCPServer) checkStorable(metadata *core.Metadata) error {
+	if !s.inbox || s.storageKey == nil || metadata.WrappedKey != "" {
+		return nil
+	}
+	return NewProtocolError(ErrCodeForbidden,
+		"file %s has no wrapped data key and cannot be stored under the storage key", metadata.Filename)
+}
+
+// storeContainer premešta proveren kontejner iz .part fajla u outputPath,
+// sa novim zaglavljem kada je postavljen ključ za čuvanje.
+func (s *TCPServer) storeContainer(partPath, outputPath string) error {
+	if s.storageKey == nil {
+		if err := os.Rename(partPath, outputPath); err != nil {
+			return fmt.Errorf("failed to move container: %w", err)
+		}
+		return nil
+	}
+
+	in, err := os.Open(partPath)
+	if err != nil {
+		return fmt.Errorf("failed to open staging file: %w", err)
+	}
+	defer in.Close()
+
+	metadataJSON, err := readContainerHeader(in)
+	if err != nil {
+		return err
+	}
+	metadata, err := core.FromJSON(metadataJSON)
+	if err != nil {
+		return fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	rewrapped, err := metadata.Rewrap(s.key, s.storageKey)
+	if err != nil {
+		return fmt.Errorf("failed to re-wrap data key: %w", err)
+	}
+	header, err := rewrapped.ContainerHeader()
+	if err != nil {
+		return fmt.Errorf("failed to add metadata header: %w", err)
+	}
+
+	out, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	_, err = out.Write(header)
+	if err == nil {
+		_, err = io.Copy(out, in)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("failed to write container: %w", err)
+	}
+
+	in.Close()
+	os.Remove(partPath)
+	return nil
+}
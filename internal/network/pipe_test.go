@@ -0,0 +1,216 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, 16)
+
+// TestMain šalje log paketa logger u privremeni direktorijum, bez ispisa na
+// konzolu, da testovi i fuzzing ne bi punili izlaz.
+func TestMain(m *testing.M) {
+	logDir, err := os.MkdirTemp("", "network-test-logs")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	err = logger.InitGlobal(logDir)
+	os.Stdout = stdout
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+
+	code := m.Run()
+	os.RemoveAll(logDir)
+	os.Exit(code)
+}
+
+// newTestServer vraća server koji obrađuje konekcije iz newPipeClient, bez listenera.
+func newTestServer(t testing.TB) *TCPServer {
+	s := NewTCPServer("pipe", t.TempDir(), testKey)
+	s.SetTimeouts(2*time.Second, 2*time.Second)
+	s.active = true
+	return s
+}
+
+// pipeClient je strana klijenta net.Pipe konekcije ka handleConnection.
+// Sve što server pošalje odmah se čita u bafer, pa server nikada ne čeka
+// na test, a test čita poruke kada mu odgovara.
+type pipeClient struct {
+	net.Conn
+	t       testing.TB
+	session *Session
+	done    chan struct{}
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	buf      bytes.Buffer
+	readErr  error
+	readDone chan struct{}
+}
+
+func newPipeClient(t testing.TB, s *TCPServer) *pipeClient {
+	serverConn, clientConn := net.Pipe()
+
+	s.mu.Lock()
+	s.clients[serverConn] = false
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	c := &pipeClient{Conn: clientConn, t: t, done: make(chan struct{}), readDone: make(chan struct{})}
+	c.cond = sync.NewCond(&c.mu)
+
+	go func() {
+		defer close(c.done)
+		s.handleConnection(&deadlineConn{Conn: serverConn, readTimeout: s.readTimeout, writeTimeout: s.writeTimeout})
+	}()
+	go c.readLoop()
+	return c
+}
+
+func (c *pipeClient) readLoop() {
+	defer close(c.readDone)
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := c.Conn.Read(chunk)
+		c.mu.Lock()
+		c.buf.Write(chunk[:n])
+		if err != nil {
+			c.readErr = io.EOF
+		}
+		c.cond.Broadcast()
+		c.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read čita iz bafera i čeka dok server ne pošalje podatke ili zatvori konekciju.
+func (c *pipeClient) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.buf.Len() == 0 && c.readErr == nil {
+		c.cond.Wait()
+	}
+	if c.buf.Len() > 0 {
+		return c.buf.Read(p)
+	}
+	return 0, c.readErr
+}
+
+// handshake obavlja handshake ključem key i prihvata AUTH_OK servera bez fajla klijenata.
+func (c *pipeClient) handshake(key []byte) error {
+	if err := c.startSession(key); err != nil {
+		return err
+	}
+
+	msg, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if msg.Command != AuthOKCmd {
+		return errors.New("expected AUTH_OK, got " + msg.Command)
+	}
+	return nil
+}
+
+// handshakeAs obavlja handshake i prijavljuje se identitetom id iz fajla klijenata.
+func (c *pipeClient) handshakeAs(key []byte, id string, secret []byte) error {
+	if err := c.startSession(key); err != nil {
+		return err
+	}
+
+	msg, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if msg.Command != ChallengeCmd {
+		return errors.New("expected CHALLENGE, got " + msg.Command)
+	}
+	response := fmt.Sprintf("%s|%x", id, challengeResponse(secret, msg.Payload, c.session.ID))
+	if err := c.send(AuthResponseCmd, []byte(response)); err != nil {
+		return err
+	}
+
+	if msg, err = c.receive(); err != nil {
+		return err
+	}
+	if msg.Command != AuthOKCmd {
+		return errors.New("expected AUTH_OK, got " + msg.Command)
+	}
+	return nil
+}
+
+func (c *pipeClient) startSession(key []byte) error {
+	session, err := clientHandshake(c, key, Capabilities{
+		Version:     ProtocolVersion,
+		Algorithms:  SupportedAlgorithms,
+		Hashes:      []string{HashSHA256},
+		Compression: []string{CompressionNone},
+		MaxFrame:    MaxPacketSize,
+		Features:    []string{FeatureResume, FeatureSession, FeatureDownload},
+	})
+	if err != nil {
+		return err
+	}
+	c.session = session
+	return nil
+}
+
+// send šalje zapečaćenu poruku; greška znači da je server već zatvorio konekciju.
+func (c *pipeClient) send(command string, payload []byte) error {
+	return c.session.SendMessage(c, command, payload)
+}
+
+// receive čita sledeću poruku servera, zapečaćenu posle handshake-a.
+func (c *pipeClient) receive() (Message, error) {
+	if c.session != nil {
+		return c.session.ReceiveMessage(c)
+	}
+	return ReceiveMessage(c)
+}
+
+// finish čeka da server završi konekciju i vraća sve poruke koje je poslao
+// a test ih još nije pročitao.
+func (c *pipeClient) finish() []Message {
+	c.t.Helper()
+	select {
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+		c.Conn.Close()
+		c.t.Fatal("server did not close the connection")
+	}
+	c.Conn.Close()
+	<-c.readDone
+
+	var messages []Message
+	for {
+		msg, err := c.receive()
+		if err != nil {
+			return messages
+		}
+		messages = append(messages, msg)
+	}
+}
+
+// hangUp zatvara konekciju sa strane klijenta, npr. usred okvira, i čeka server.
+func (c *pipeClient) hangUp() []Message {
+	c.t.Helper()
+	c.Conn.Close()
+	return c.finish()
+}
@@ -0,0 +1,141 @@
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DirectionSend    = "send"
+	DirectionReceive = "receive"
+
+	progressInterval = 200 * time.Millisecond
+)
+
+// Progress je stanje jednog prenosa koje klijent i server javljaju preko
+// ProgressFunc. Done i Total su bajtovi kontejnera, Rate je u bajtovima po
+// sekundi, a ETA je nula dok brzina nije poznata.
+type Progress struct {
+	TransferID string
+	Name       string
+	Direction  string
+	Remote     string
+	Done       int64
+	Total      int64
+	Rate       float64
+	ETA        time.Duration
+	Finished   bool
+	Err        error
+}
+
+// Percent vraća udeo prenetih bajtova u procentima.
+func (p Progress) Percent() float64 {
+	if p.Total <= 0 {
+		if p.Finished && p.Err == nil {
+			return 100
+		}
+		return 0
+	}
+	return float64(p.Done) * 100 / float64(p.Total)
+}
+
+// ProgressFunc prima izveštaje o napretku. Server je poziva iz gorutina
+// pojedinačnih konekcija, pa mora biti bezbedna za istovremene pozive i ne
+// sme da blokira prenos.
+type ProgressFunc func(Progress)
+
+// progressTracker računa brzinu i preostalo vreme i ograničava koliko često
+// se ProgressFunc poziva.
+type progressTracker struct {
+	report   ProgressFunc
+	progress Progress
+	start    time.Time
+	last     time.Time
+	base     int64
+}
+
+func newProgressTracker(report ProgressFunc, progress Progress) *progressTracker {
+	if report == nil {
+		return nil
+	}
+	now := time.Now()
+	t := &progressTracker{report: report, progress: progress, start: now, base: progress.Done}
+	t.last = now
+	report(progress)
+	return t
+}
+
+// restart počinje novo merenje brzine, na primer posle nastavka prekinutog prenosa.
+func (t *progressTracker) restart(done int64) {
+	if t == nil {
+		return
+	}
+	t.start = time.Now()
+	t.base = done
+	t.progress.Done = done
+	t.progress.Rate = 0
+	t.progress.ETA = 0
+}
+
+// update beleži da je preneto done bajtova od početka fajla.
+func (t *progressTracker) update(done int64) {
+	if t == nil {
+		return
+	}
+	t.progress.Done = done
+
+	now := time.Now()
+	if now.Sub(t.last) < progressInterval && done < t.progress.Total {
+		return
+	}
+	t.last = now
+	t.measure(now)
+	t.report(t.progress)
+}
+
+// finish šalje poslednji izveštaj, uspešan ako je err nil.
+func (t *progressTracker) finish(err error) {
+	if t == nil {
+		return
+	}
+	t.measure(time.Now())
+	t.progress.Finished = true
+	t.progress.Err = err
+	t.progress.ETA = 0
+	t.report(t.progress)
+}
+
+// measure računa prosečnu brzinu od početka ovog pokušaja, da nastavljeni
+// prenos ne bi prikazivao brzinu uvećanu za već poslate bajtove.
+func (t *progressTracker) measure(now time.Time) {
+	elapsed := now.Sub(t.start).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	t.progress.Rate = float64(t.progress.Done-t.base) / elapsed
+	if t.progress.Rate > 0 && t.progress.Total > t.progress.Done {
+		remaining := float64(t.progress.Total-t.progress.Done) / t.progress.Rate
+		t.progress.ETA = time.Duration(remaining * float64(time.Second))
+	} else {
+		t.progress.ETA = 0
+	}
+}
+
+// progressHandler čuva ProgressFunc koju postavlja korisnik klijenta ili servera.
+type progressHandler struct {
+	mu     sync.RWMutex
+	report ProgressFunc
+}
+
+func (h *progressHandler) set(report ProgressFunc) {
+	h.mu.Lock()
+	h.report = report
+	h.mu.Unlock()
+}
+
+func (h *progressHandler) track(progress Progress) *progressTracker {
+	h.mu.RLock()
+	report := h.report
+	h.mu.RUnlock()
+	return newProgressTracker(report, progress)
+}
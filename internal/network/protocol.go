@@ -4,6 +4,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -16,8 +18,13 @@ const (
 	SuccessCmd   = "SUCCESS"
 	FailCmd      = "FAIL"
 	ErrorCmd     = "ERROR"
+	EndCmd       = "END"
+	SummaryCmd   = "SUMMARY"
 
-	MaxPacketSize = 64 * 1024
+	// MaxPacketSize mora biti najmanje core.MaxHeaderSize, jer se zaglavlje
+	// kontejnera šalje u jednoj METADATA poruci.
+	MaxPacketSize    = 64 * 1024
+	MaxCommandLength = 32
 )
 
 type Message struct {
@@ -55,6 +62,10 @@ func DecodeMessage(reader io.Reader) (Message, error) {
 		return msg, fmt.Errorf("failed to read command length: %w", err)
 	}
 
+	if cmdLen == 0 || cmdLen > MaxCommandLength {
+		return msg, NewProtocolError(ErrCodeCommandTooLong, "command length %d exceeds limit of %d", cmdLen, MaxCommandLength)
+	}
+
 	cmdBytes := make([]byte, cmdLen)
 	if _, err := io.ReadFull(reader, cmdBytes); err != nil {
 		return msg, fmt.Errorf("failed to read command: %w", err)
@@ -66,6 +77,10 @@ func DecodeMessage(reader io.Reader) (Message, error) {
 		return msg, fmt.Errorf("failed to read payload length: %w", err)
 	}
 
+	if payloadLen > MaxPacketSize {
+		return msg, NewProtocolError(ErrCodeFrameTooLarge, "payload of %d bytes exceeds limit of %d", payloadLen, MaxPacketSize)
+	}
+
 	if payloadLen > 0 {
 		msg.Payload = make([]byte, payloadLen)
 		if _, err := io.ReadFull(reader, msg.Payload); err != nil {
@@ -87,3 +102,23 @@ func SendMessage(writer io.Writer, command string, payload []byte) error {
 func ReceiveMessage(reader io.Reader) (Message, error) {
 	return DecodeMessage(reader)
 }
+
+// parseFileStart čita FILE_START poruku "<ime>|<veličina>|<dužina metadata>[|<transfer id>]".
+// Ime je relativna putanja sa "/"; stari klijenti ne šalju transfer ID.
+func parseFileStart(payload []byte) (string, int64, string, error) {
+	parts := strings.Split(string(payload), "|")
+	if len(parts) != 3 && len(parts) != 4 {
+		return "", 0, "", fmt.Errorf("expected 3 or 4 fields, got %d", len(parts))
+	}
+	size, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || size < 0 {
+		return "", 0, "", fmt.Errorf("invalid size %q", parts[1])
+	}
+	if len(parts) == 3 {
+		return parts[0], size, "", nil
+	}
+	if !validTransferID(parts[3]) {
+		return "", 0, "", fmt.Errorf("invalid transfer id %q", parts[3])
+	}
+	return parts[0], size, parts[3], nil
+}
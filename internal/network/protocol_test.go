@@ -0,0 +1,216 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden frames in testdata/frames")
+
+// goldenSession vraća par sesija sa fiksnim ključevima, pa su zapečaćeni
+// okviri uvek isti.
+func goldenSession(t testing.TB) (sender, receiver *Session) {
+	clientKey := bytes.Repeat([]byte{0x11}, 32)
+	serverKey := bytes.Repeat([]byte{0x22}, 32)
+	id := []byte("golden!!")
+
+	sender, err := newSession(clientKey, serverKey, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err = newSession(serverKey, clientKey, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sender, receiver
+}
+
+// goldenFrames su poruke čiji se kodiran oblik ne sme menjati bez nove
+// verzije protokola.
+func goldenFrames() []struct {
+	name string
+	msg  Message
+} {
+	serverCapabilities := Capabilities{
+		Version:     ProtocolVersion,
+		Algorithms:  SupportedAlgorithms,
+		Hashes:      []string{HashSHA256},
+		Compression: []string{CompressionNone},
+		MaxFrame:    MaxPacketSize,
+		Features:    []string{FeatureResume, FeatureSession, FeatureDownload},
+	}
+	return []struct {
+		name string
+		msg  Message
+	}{
+		{"hello", Message{Command: HelloCmd, Payload: []byte(serverCapabilities.String() + "|" + strings.Repeat("ab", 32) + "|" + strings.Repeat("cd", 32))}},
+		{"auth_ok", Message{Command: AuthOKCmd}},
+		{"file_start", Message{Command: FileStartCmd, Payload: []byte("docs/report.txt|1234|456|" + strings.Repeat("0f", 16))}},
+		{"file_data", Message{Command: FileDataCmd, Payload: []byte{0x00, 0x01, 0x02, 0xfd, 0xfe, 0xff}}},
+		{"file_end", Message{Command: FileEndCmd}},
+		{"resume", Message{Command: ResumeCmd, Payload: []byte(strings.Repeat("0f", 16))}},
+		{"resume_ok", Message{Command: ResumeOKCmd, Payload: []byte("65536")}},
+		{"error", Message{Command: ErrorCmd, Payload: EncodeError(ErrCodeKeyMismatch, NewProtocolError(ErrCodeKeyMismatch, "wrong key"))}},
+		{"end", Message{Command: EndCmd}},
+		{"summary", Message{Command: SummaryCmd, Payload: []byte("3|1")}},
+		{"list_item", Message{Command: ListItemCmd, Payload: []byte("1234|1700000000|docs/report.txt")}},
+	}
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "frames", name+".golden")
+	encoded := hex.EncodeToString(got) + "\n"
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(encoded), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -run Golden -update to create it)", err)
+	}
+	if string(want) != encoded {
+		t.Errorf("%s: encoded frame changed\n got: %s want: %s", name, encoded, want)
+	}
+}
+
+func TestGoldenFrames(t *testing.T) {
+	for _, tt := range goldenFrames() {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := EncodeMessage(tt.msg)
+			checkGolden(t, tt.name, frame)
+
+			decoded, err := DecodeMessage(bytes.NewReader(frame))
+			if err != nil {
+				t.Fatalf("DecodeMessage: %v", err)
+			}
+			if decoded.Command != tt.msg.Command || !bytes.Equal(decoded.Payload, tt.msg.Payload) {
+				t.Errorf("decoded %q %q, want %q %q", decoded.Command, decoded.Payload, tt.msg.Command, tt.msg.Payload)
+			}
+		})
+	}
+}
+
+// TestGoldenSealedFrames proverava zapečaćene okvire, uključujući redni broj
+// poruke koji ulazi u nonce; zato se okviri obrađuju redom, bez podtestova.
+func TestGoldenSealedFrames(t *testing.T) {
+	sender, receiver := goldenSession(t)
+
+	for i, tt := range goldenFrames() {
+		name := fmt.Sprintf("sealed_%02d_%s", i, tt.name)
+		frame := sender.EncodeMessage(tt.msg)
+		checkGolden(t, name, frame)
+
+		decoded, err := receiver.DecodeMessage(bytes.NewReader(frame))
+		if err != nil {
+			t.Fatalf("%s: DecodeMessage: %v", name, err)
+		}
+		if decoded.Command != tt.msg.Command || !bytes.Equal(decoded.Payload, tt.msg.Payload) {
+			t.Errorf("%s: decoded %q %q, want %q %q", name, decoded.Command, decoded.Payload, tt.msg.Command, tt.msg.Payload)
+		}
+	}
+}
+
+func TestDecodeMessageErrors(t *testing.T) {
+	frame := EncodeMessage(Message{Command: FileStartCmd, Payload: []byte("report.txt|10|5")})
+
+	tests := []struct {
+		name  string
+		input []byte
+		code  ErrorCode
+		eof   bool
+	}{
+		{name: "empty input", input: nil, eof: true},
+		{name: "truncated command length", input: frame[:1], eof: true},
+		{name: "truncated command", input: frame[:5], eof: true},
+		{name: "truncated payload length", input: frame[:2+len(FileStartCmd)+2], eof: true},
+		{name: "truncated payload", input: frame[:len(frame)-1], eof: true},
+		{name: "empty command", input: []byte{0x00, 0x00}, code: ErrCodeCommandTooLong},
+		{name: "command too long", input: []byte{0x00, MaxCommandLength + 1}, code: ErrCodeCommandTooLong},
+		{name: "maximum command length", input: []byte{0xff, 0xff}, code: ErrCodeCommandTooLong},
+		{name: "payload too large", input: []byte{0x00, 0x03, 'E', 'N', 'D', 0x00, 0x01, 0x00, 0x01}, code: ErrCodeFrameTooLarge},
+		{name: "maximum payload length", input: []byte{0x00, 0x03, 'E', 'N', 'D', 0xff, 0xff, 0xff, 0xff}, code: ErrCodeFrameTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeMessage(bytes.NewReader(tt.input))
+			if err == nil {
+				t.Fatal("DecodeMessage accepted an invalid frame")
+			}
+			if tt.eof && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("got %v, want an EOF error", err)
+			}
+			if tt.code != "" && errorCode(err, "") != tt.code {
+				t.Errorf("got %v, want code %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestDecodeMessageLimits(t *testing.T) {
+	msg := Message{Command: strings.Repeat("C", MaxCommandLength), Payload: bytes.Repeat([]byte{0x5a}, MaxPacketSize)}
+	decoded, err := DecodeMessage(bytes.NewReader(EncodeMessage(msg)))
+	if err != nil {
+		t.Fatalf("frame at the limits rejected: %v", err)
+	}
+	if decoded.Command != msg.Command || !bytes.Equal(decoded.Payload, msg.Payload) {
+		t.Error("frame at the limits decoded incorrectly")
+	}
+}
+
+func TestSessionDecodeMessageErrors(t *testing.T) {
+	sender, _ := goldenSession(t)
+	first := sender.EncodeMessage(Message{Command: EndCmd})
+	second := sender.EncodeMessage(Message{Command: EndCmd})
+
+	tamperedLength := bytes.Clone(first)
+	tamperedLength[3]--
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  error
+		code  ErrorCode
+	}{
+		{name: "replayed frame", input: append(bytes.Clone(first), first...), want: ErrFrameAuth},
+		{name: "reordered frame", input: second, want: ErrFrameAuth},
+		{name: "tampered length", input: tamperedLength, want: ErrFrameAuth},
+		{name: "oversized frame", input: []byte{0xff, 0xff, 0xff, 0xff}, code: ErrCodeFrameTooLarge},
+		{name: "truncated frame", input: first[:len(first)-1], want: io.ErrUnexpectedEOF},
+		{name: "plain frame", input: EncodeMessage(Message{Command: EndCmd}), code: ErrCodeFrameTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, receiver := goldenSession(t)
+			reader := bytes.NewReader(tt.input)
+
+			var err error
+			for err == nil {
+				_, err = receiver.DecodeMessage(reader)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+			if tt.code != "" && errorCode(err, "") != tt.code {
+				t.Errorf("got %v, want code %s", err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// tokenBucket ograničava protok na rate jedinica u sekundi uz nalet od
+// najviše burst. Zahtev veći od raspoloživog stanja ide u minus, pa se
+// veliki okviri ne dele, a sledeći zahtevi čekaju dok se dug ne vrati.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// newBandwidthBucket pravi bucket za bytesPerSecond, sa naletom od jedne sekunde.
+func newBandwidthBucket(bytesPerSecond int64) *tokenBucket {
+	return newTokenBucket(float64(bytesPerSecond), float64(bytesPerSecond))
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// reserve uzima n jedinica i vraća koliko treba sačekati pre njihove upotrebe.
+func (b *tokenBucket) reserve(n int) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// allow uzima jednu jedinicu ako je ima, bez čekanja.
+func (b *tokenBucket) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// idle javlja da bucket duže od d nije korišćen.
+func (b *tokenBucket) idle(now time.Time, d time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Sub(b.last) > d
+}
+
+// limitedConn propušta podatke kroz bucket-e konekcije i ukupnog protoka.
+// Pisanje čeka pre slanja, a čitanje posle prijema, pa TCP usporava
+// pošiljaoca kada se bafer napuni.
+type limitedConn struct {
+	net.Conn
+	buckets []*tokenBucket
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// limitConn vraća conn neizmenjen kada nijedno ograničenje nije postavljeno.
+func limitConn(conn net.Conn, buckets ...*tokenBucket) net.Conn {
+	var active []*tokenBucket
+	for _, bucket := range buckets {
+		if bucket != nil {
+			active = append(active, bucket)
+		}
+	}
+	if len(active) == 0 {
+		return conn
+	}
+	return &limitedConn{Conn: conn, buckets: active, closed: make(chan struct{})}
+}
+
+func (c *limitedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.wait(n)
+	}
+	return n, err
+}
+
+func (c *limitedConn) Write(p []byte) (int, error) {
+	if err := c.wait(len(p)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(p)
+}
+
+// Close prekida i čekanje na bucket, da gašenje servera ne bi čekalo spor prenos.
+func (c *limitedConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+func (c *limitedConn) wait(n int) error {
+	var delay time.Duration
+	for _, bucket := range c.buckets {
+		delay = max(delay, bucket.reserve(n))
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.closed:
+		return net.ErrClosed
+	}
+}
+
+// maxTrackedIPs je broj adresa posle kog connectionLimiter briše neaktivne.
+const maxTrackedIPs = 1024
+
+// connectionLimiter ograničava broj novih konekcija u minuti po IP adresi.
+type connectionLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	buckets   map[string]*tokenBucket
+}
+
+func newConnectionLimiter(perMinute int) *connectionLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &connectionLimiter{perMinute: perMinute, buckets: make(map[string]*tokenBucket)}
+}
+
+func (l *connectionLimiter) allow(ip string) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[ip]
+	if !ok {
+		if len(l.buckets) >= maxTrackedIPs {
+			l.prune()
+		}
+		bucket = newTokenBucket(float64(l.perMinute)/60, float64(l.perMinute))
+		l.buckets[ip] = bucket
+	}
+	return bucket.allow()
+}
+
+// prune briše adrese koje se minut nisu javile; njihov bucket je ponovo pun,
+// isto kao novi.
+func (l *connectionLimiter) prune() {
+	now := time.Now()
+	for ip, bucket := range l.buckets {
+		if bucket.idle(now, time.Minute) {
+			delete(l.buckets, ip)
+		}
+	}
+}
@@ -0,0 +1,720 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+const (
+	RecipientCmd = "RECIPIENT"
+	DeleteCmd    = "DELETE"
+
+	DefaultRelayExpiry = 7 * 24 * time.Hour
+)
+
+// RelayLimits određuju koliko dugo i koliko podataka relay čuva (0 = bez ograničenja).
+type RelayLimits struct {
+	Expiry     time.Duration
+	MaxMailbox int64
+	MaxTotal   int64
+}
+
+// relayItem je jedan fajl koji čeka primaoca, čuva se kao <id>.json pored <id>.enc.
+type relayItem struct {
+	ID        string    `json:"id"`
+	Recipient string    `json:"recipient"`
+	Sender    string    `json:"sender"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Created   time.Time `json:"created"`
+}
+
+// listName je ime pod kojim primalac vidi fajl: "<id>/<putanja>".
+func (i *relayItem) listName() string {
+	return i.ID + "/" + i.Name
+}
+
+// relayStore čuva šifrovane kontejnere u sandučetu svakog primaoca. Relay
+// nema ključ fajlova, pa ih nikada ne dekriptuje.
+type relayStore struct {
+	dir      string
+	limits   RelayLimits
+	mu       sync.Mutex
+	reserved map[string]int64
+}
+
+func newRelayStore(dir string, limits RelayLimits) *relayStore {
+	return &relayStore{dir: dir, limits: limits, reserved: make(map[string]int64)}
+}
+
+// NewRelayServer pravi server koji prima šifrovane fajlove za primaoce i čuva
+// ih dok ih primaoci ne preuzmu. key služi samo za handshake, a pošiljaoci i
+// primaoci se prijavljuju identitetom iz fajla klijenata (SetClients).
+func NewRelayServer(address, storageDir string, key []byte, limits RelayLimits) *TCPServer {
+	s := NewTCPServer(address, storageDir, key)
+	s.relay = newRelayStore(storageDir, limits)
+	return s
+}
+
+// mailboxDir koristi hex zapis identiteta, jer identitet može sadržati bilo
+// koji znak osim "|".
+func (r *relayStore) mailboxDir(recipient string) string {
+	return filepath.Join(r.dir, hex.EncodeToString([]byte(recipient)))
+}
+
+func (r *relayStore) containerPath(recipient, id string) string {
+	return filepath.Join(r.mailboxDir(recipient), id+".enc")
+}
+
+func (r *relayStore) itemPath(recipient, id string) string {
+	return filepath.Join(r.mailboxDir(recipient), id+".json")
+}
+
+// reserve proverava kvote za fajl od size bajtova i rezerviše prostor dok
+// prenos traje. Vraćena funkcija oslobađa rezervaciju.
+func (r *relayStore) reserve(recipient string, size int64) (func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mailbox, total, err := r.usage(recipient)
+	if err != nil {
+		return nil, NewProtocolError(ErrCodeInternal, "failed to check relay storage: %v", err)
+	}
+	if r.limits.MaxMailbox > 0 && mailbox+size > r.limits.MaxMailbox {
+		return nil, NewProtocolError(ErrCodeQuotaExceeded,
+			"mailbox of %s is full (%d of %d bytes used)", recipient, mailbox, r.limits.MaxMailbox)
+	}
+	if r.limits.MaxTotal > 0 && total+size > r.limits.MaxTotal {
+		return nil, NewProtocolError(ErrCodeQuotaExceeded, "relay storage is full")
+	}
+
+	r.reserved[recipient] += size
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.reserved[recipient] -= size; r.reserved[recipient] <= 0 {
+			delete(r.reserved, recipient)
+		}
+	}, nil
+}
+
+// usage sabira sačuvane i rezervisane bajtove za primaoca i za ceo relay.
+func (r *relayStore) usage(recipient string) (int64, int64, error) {
+	var mailbox, total int64
+	for name, size := range r.reserved {
+		total += size
+		if name == recipient {
+			mailbox += size
+		}
+	}
+
+	dirs, err := os.ReadDir(r.dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	own := filepath.Base(r.mailboxDir(recipient))
+	for _, dir := range dirs {
+		if !dir.IsDir() || dir.Name() == stagingDirName {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(r.dir, dir.Name()))
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, entry := range entries {
+			if filepath.Ext(entry.Name()) != ".enc" {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			total += info.Size()
+			if dir.Name() == own {
+				mailbox += info.Size()
+			}
+		}
+	}
+	return mailbox, total, nil
+}
+
+// store premešta proveren kontejner u sanduče primaoca.
+func (r *relayStore) store(item *relayItem, containerPath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.mailboxDir(item.Recipient), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(containerPath, r.containerPath(item.Recipient, item.ID)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.itemPath(item.Recipient, item.ID), data, 0600); err != nil {
+		os.Remove(r.containerPath(item.Recipient, item.ID))
+		return err
+	}
+	return nil
+}
+
+// list vraća fajlove koji čekaju primaoca, od najstarijeg.
+func (r *relayStore) list(recipient string) ([]*relayItem, error) {
+	entries, err := os.ReadDir(r.mailboxDir(recipient))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*relayItem
+	for _, entry := range entries {
+		id, found := strings.CutSuffix(entry.Name(), ".json")
+		if !found {
+			continue
+		}
+		item, err := r.item(recipient, id)
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Created.Before(items[j].Created) })
+	return items, nil
+}
+
+// item čita opis fajla iz imena "<id>" ili "<id>/<putanja>" iz LIST odgovora.
+func (r *relayStore) item(recipient, name string) (*relayItem, error) {
+	id, _, _ := strings.Cut(name, "/")
+	if !validTransferID(id) {
+		return nil, NewProtocolError(ErrCodeNotFound, "no file %s for %s", name, recipient)
+	}
+
+	data, err := os.ReadFile(r.itemPath(recipient, id))
+	if err != nil {
+		return nil, NewProtocolError(ErrCodeNotFound, "no file %s for %s", name, recipient)
+	}
+	var item relayItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("corrupt relay item %s: %w", id, err)
+	}
+	return &item, nil
+}
+
+func (r *relayStore) remove(recipient, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	os.Remove(r.itemPath(recipient, id))
+	os.Remove(r.containerPath(recipient, id))
+	// prazno sanduče se briše, os.Remove ne briše direktorijum sa fajlovima
+	os.Remove(r.mailboxDir(recipient))
+}
+
+// cleanExpired briše fajlove koje primaoci nisu preuzeli na vreme i
+// kontejnere bez opisa ostale posle pada servera.
+func (r *relayStore) cleanExpired() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dirs, err := os.ReadDir(r.dir)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-r.limits.Expiry)
+	removed := 0
+	for _, dir := range dirs {
+		if !dir.IsDir() || dir.Name() == stagingDirName {
+			continue
+		}
+		mailbox := filepath.Join(r.dir, dir.Name())
+		entries, err := os.ReadDir(mailbox)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			id, found := strings.CutSuffix(entry.Name(), ".enc")
+			if !found {
+				continue
+			}
+			itemPath := filepath.Join(mailbox, id+".json")
+			info, err := os.Stat(itemPath)
+			if err == nil && (r.limits.Expiry <= 0 || info.ModTime().After(cutoff)) {
+				continue
+			}
+			os.Remove(itemPath)
+			os.Remove(filepath.Join(mailbox, entry.Name()))
+			removed++
+		}
+		os.Remove(mailbox)
+	}
+
+	if removed > 0 {
+		logger.Info(logger.RECEIVE_FILE, "Expired relay files removed", true, map[string]interface{}{
+			"relay_dir": r.dir,
+			"removed":   removed,
+			"expiry":    r.limits.Expiry.String(),
+		})
+	}
+}
+
+// handleRecipient postavlja primaoca za sledeće fajlove u sesiji.
+func (s *TCPServer) handleRecipient(conn *deadlineConn, session *Session, payload []byte) (string, error) {
+	if s.relay == nil {
+		return "", s.sendError(conn, session, ErrCodeProtocol, fmt.Errorf("server is not a relay"))
+	}
+
+	recipient := string(payload)
+	if !s.clientRegistry.Has(recipient) {
+		return "", s.sendError(conn, session, ErrCodeNotFound, fmt.Errorf("unknown recipient %q", recipient))
+	}
+	return recipient, session.SendMessage(conn, SuccessCmd, payload)
+}
+
+// handleRelayUpload prima kontejner za primaoca i čuva ga neizmenjen. Heš (i
+// potpis, ako su zadati pouzdani potpisnici) se proverava bez ključa.
+func (s *TCPServer) handleRelayUpload(conn *deadlineConn, session *Session, identity, recipient string, startMsg Message) error {
+	remoteAddr := conn.RemoteAddr().String()
+
+	item, release, err := s.startRelayUpload(conn, session, identity, recipient, startMsg)
+	if err != nil {
+		if fileLevelError(err) {
+			// klijent već šalje podatke; odbacuju se da sesija može da nastavi
+			if discardErr := discardFileData(conn, session, s.maxTransferSize); discardErr != nil {
+				return discardErr
+			}
+		}
+		logger.Error(logger.RECEIVE_FILE, "Relay upload rejected", map[string]interface{}{
+			"remote_addr": remoteAddr,
+			"identity":    identity,
+			"recipient":   recipient,
+			"error":       err.Error(),
+		})
+		return s.sendError(conn, session, ErrCodeProtocol, err)
+	}
+	defer release()
+
+	incomingPath := s.staging.outputPath(item.ID)
+	err = s.receiveRelayContainer(conn, session, item, incomingPath)
+	if err == nil {
+		err = s.relay.store(item, incomingPath)
+	}
+	if err != nil {
+		os.Remove(incomingPath)
+		logger.Error(logger.RECEIVE_FILE, "Relay upload failed", map[string]interface{}{
+			"remote_addr": remoteAddr,
+			"identity":    identity,
+			"recipient":   recipient,
+			"file":        item.Name,
+			"error":       err.Error(),
+		})
+		log.Printf("Relay upload from %s failed: %v", remoteAddr, err)
+		return s.sendError(conn, session, ErrCodeProtocol, err)
+	}
+
+	logger.LogNetwork(logger.RECEIVE_FILE, remoteAddr,
+		"File stored for recipient", true, map[string]interface{}{
+			"identity":  identity,
+			"recipient": recipient,
+			"file":      item.Name,
+			"relay_id":  item.ID,
+			"size":      item.Size,
+		})
+	log.Printf("Stored %s from %s for %s (%d bytes)", item.Name, identity, recipient, item.Size)
+	return session.SendMessage(conn, SuccessCmd, []byte(item.Name))
+}
+
+func (s *TCPServer) startRelayUpload(conn *deadlineConn, session *Session, identity, recipient string, startMsg Message) (*relayItem, func(), error) {
+	if startMsg.Command != FileStartCmd {
+		return nil, nil, fmt.Errorf("expected FILE_START, got %s", startMsg.Command)
+	}
+	name, size, _, err := parseFileStart(startMsg.Payload)
+	if err != nil {
+		return nil, nil, NewProtocolError(ErrCodeProtocol, "malformed FILE_START: %v", err)
+	}
+	if recipient == "" {
+		return nil, nil, NewProtocolError(ErrCodeProtocol, "no recipient set, send RECIPIENT first")
+	}
+	if s.maxTransferSize > 0 && size > s.maxTransferSize {
+		return nil, nil, NewProtocolError(ErrCodeTransferTooLarge,
+			"file of %d bytes exceeds transfer limit of %d bytes", size, s.maxTransferSize)
+	}
+
+	metadataMsg, err := session.ReceiveMessage(conn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to receive metadata: %w", err)
+	}
+	if _, err := core.FromJSON(metadataMsg.Payload); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	relPath, err := SanitizePath(name)
+	if err != nil {
+		return nil, nil, NewProtocolError(ErrCodeInvalidFilename, "%v", err)
+	}
+
+	release, err := s.relay.reserve(recipient, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := newTransferID()
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	return &relayItem{
+		ID:        id,
+		Recipient: recipient,
+		Sender:    identity,
+		Name:      relPath,
+		Size:      size,
+		Created:   time.Now().UTC(),
+	}, release, nil
+}
+
+// receiveRelayContainer upisuje FILE_DATA u containerPath i proverava heš kontejnera.
+func (s *TCPServer) receiveRelayContainer(conn *deadlineConn, session *Session, item *relayItem, containerPath string) error {
+	if err := os.MkdirAll(filepath.Dir(containerPath), 0700); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	file, err := os.OpenFile(containerPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create relay file: %w", err)
+	}
+	defer file.Close()
+
+	checker := core.NewContainerChecker()
+	if len(s.trustedSigners) > 0 {
+		checker.RequireSignature(s.trustedSigners)
+	}
+
+	progress := s.progress.track(Progress{
+		TransferID: item.ID,
+		Name:       item.Name,
+		Direction:  DirectionReceive,
+		Remote:     conn.RemoteAddr().String(),
+		Total:      item.Size,
+	})
+
+	err = receiveContainer(conn, session, item.Size, io.MultiWriter(outputFile{file}, checker), progress)
+	if err == nil {
+		if err = checker.Close(); err != nil {
+			err = containerError(err)
+		}
+	}
+	if err == nil && checker.Metadata.Filename != path.Base(item.Name) {
+		err = NewProtocolError(ErrCodeInvalidFilename,
+			"transfer name %q does not match file name %q in metadata", item.Name, checker.Metadata.Filename)
+	}
+	if err == nil {
+		if err = file.Close(); err != nil {
+			err = NewProtocolError(ErrCodeInternal, "failed to write relay file: %v", err)
+		}
+	}
+	progress.finish(err)
+	return err
+}
+
+// receiveContainer prima FILE_DATA poruke do FILE_END i piše ih u w.
+func receiveContainer(conn *deadlineConn, session *Session, size int64, w io.Writer, progress *progressTracker) error {
+	var received int64
+	for {
+		msg, err := session.ReceiveMessage(conn)
+		if err != nil {
+			return fmt.Errorf("failed to receive file data: %w", err)
+		}
+		if msg.Command == FileEndCmd {
+			break
+		}
+		if msg.Command != FileDataCmd {
+			return fmt.Errorf("expected FILE_DATA or FILE_END, got %s", msg.Command)
+		}
+		if received+int64(len(msg.Payload)) > size {
+			return NewProtocolError(ErrCodeProtocol, "received more than the declared %d bytes", size)
+		}
+
+		if _, err := w.Write(msg.Payload); err != nil {
+			err = containerError(err)
+			if fileLevelError(err) {
+				if discardErr := discardFileData(conn, session, size); discardErr != nil {
+					return discardErr
+				}
+			}
+			return err
+		}
+		received += int64(len(msg.Payload))
+		progress.update(received)
+	}
+
+	if received != size {
+		return NewProtocolError(ErrCodeProtocol, "received %d of the declared %d bytes", received, size)
+	}
+	return nil
+}
+
+// handleMailboxList šalje primaocu listu fajlova koji ga čekaju, u istom
+// formatu kao LIST na običnom serveru.
+func (s *TCPServer) handleMailboxList(conn *deadlineConn, session *Session, identity string) error {
+	items, err := s.relay.list(identity)
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeInternal, fmt.Errorf("failed to list mailbox: %w", err))
+	}
+
+	for _, item := range items {
+		listItem := fmt.Sprintf("%d|%d|%s", item.Size, item.Created.Unix(), item.listName())
+		if err := session.SendMessage(conn, ListItemCmd, []byte(listItem)); err != nil {
+			return err
+		}
+	}
+
+	logger.LogNetwork(logger.SEND_FILE, conn.RemoteAddr().String(),
+		"Mailbox list sent", true, map[string]interface{}{
+			"identity": identity,
+			"files":    len(items),
+		})
+	return session.SendMessage(conn, ListEndCmd, []byte(strconv.Itoa(len(items))))
+}
+
+// handleMailboxGet šalje sačuvan kontejner primaocu neizmenjen. Fajl ostaje
+// na relay-u dok ga primalac ne obriše sa DELETE posle uspešne dekripcije.
+func (s *TCPServer) handleMailboxGet(conn *deadlineConn, session *Session, identity, name string) error {
+	item, err := s.relay.item(identity, name)
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeNotFound, err)
+	}
+
+	file, err := os.Open(s.relay.containerPath(identity, item.ID))
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeNotFound, fmt.Errorf("no file %s for %s", name, identity))
+	}
+	defer file.Close()
+
+	metadataJSON, err := readContainerHeader(file)
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeInternal, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return s.sendError(conn, session, ErrCodeInternal, err)
+	}
+
+	startPayload := fmt.Sprintf("%s|%d|%d", item.Name, item.Size, len(metadataJSON))
+	if err := session.SendMessage(conn, FileStartCmd, []byte(startPayload)); err != nil {
+		return err
+	}
+	if err := session.SendMessage(conn, "METADATA", metadataJSON); err != nil {
+		return err
+	}
+
+	progress := s.progress.track(Progress{
+		TransferID: item.ID,
+		Name:       item.Name,
+		Direction:  DirectionSend,
+		Remote:     conn.RemoteAddr().String(),
+		Total:      item.Size,
+	})
+	data := newDataWriter(conn, session)
+	data.onChunk = func(sent int64, chunks int) {
+		progress.update(sent)
+	}
+	_, err = io.Copy(data, file)
+	if err == nil {
+		err = data.Flush()
+	}
+	if err == nil {
+		err = session.SendMessage(conn, FileEndCmd, nil)
+	}
+	progress.finish(err)
+	if err != nil {
+		if data.err != nil {
+			return err
+		}
+		return s.sendError(conn, session, ErrCodeInternal, err)
+	}
+
+	logger.LogNetwork(logger.SEND_FILE, conn.RemoteAddr().String(),
+		"Relay file delivered", true, map[string]interface{}{
+			"identity": identity,
+			"file":     item.Name,
+			"relay_id": item.ID,
+			"sender":   item.Sender,
+		})
+	return nil
+}
+
+// handleDelete briše preuzet fajl iz sandučeta primaoca.
+func (s *TCPServer) handleDelete(conn *deadlineConn, session *Session, identity, name string) error {
+	if s.relay == nil {
+		return s.sendError(conn, session, ErrCodeProtocol, fmt.Errorf("server is not a relay"))
+	}
+	item, err := s.relay.item(identity, name)
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeNotFound, err)
+	}
+	s.relay.remove(identity, item.ID)
+
+	logger.Info(logger.RECEIVE_FILE, "Relay file removed by recipient", true, map[string]interface{}{
+		"identity": identity,
+		"file":     item.Name,
+		"relay_id": item.ID,
+	})
+	return session.SendMessage(conn, SuccessCmd, []byte(name))
+}
+
+// readContainerHeader čita JSON metapodatke sa početka kontejnera.
+func readContainerHeader(r io.Reader) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, fmt.Errorf("failed to read container header: %w", err)
+	}
+	metadataLen := uint32(length[0]) | uint32(length[1])<<8 | uint32(length[2])<<16 | uint32(length[3])<<24
+	if metadataLen == 0 {
+		return nil, fmt.Errorf("invalid metadata length %d", metadataLen)
+	}
+	if metadataLen > core.MaxHeaderSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit is %d", core.ErrHeaderTooLarge, metadataLen, core.MaxHeaderSize)
+	}
+
+	metadataJSON := make([]byte, metadataLen)
+	if _, err := io.ReadFull(r, metadataJSON); err != nil {
+		return nil, fmt.Errorf("failed to read container header: %w", err)
+	}
+	return metadataJSON, nil
+}
+
+// SetTransportKey postavlja ključ za handshake sa relay serverom, različit od
+// ključa kojim se fajlovi šifruju za primaoca.
+func (c *TCPClient) SetTransportKey(key []byte) {
+	c.transportKey = key
+}
+
+// SetRecipient šalje fajlove preko relay servera klijentu sa datim identitetom.
+func (c *TCPClient) SetRecipient(id string) {
+	c.recipient = id
+}
+
+// selectRecipient javlja relay serveru kome su namenjeni fajlovi u sesiji.
+func (c *TCPClient) selectRecipient() error {
+	if !c.session.Capabilities.Has(FeatureRelay) {
+		return NewProtocolError(ErrCodeForbidden, "server is not a relay")
+	}
+	if err := c.session.SendMessage(c.conn, RecipientCmd, []byte(c.recipient)); err != nil {
+		return fmt.Errorf("failed to send RECIPIENT: %w", err)
+	}
+
+	msg, err := c.session.ReceiveMessage(c.conn)
+	if err != nil {
+		return fmt.Errorf("failed to receive RECIPIENT response: %w", err)
+	}
+	if msg.Command == ErrorCmd {
+		return fmt.Errorf("server error: %w", DecodeError(msg.Payload))
+	}
+	if msg.Command != SuccessCmd {
+		return fmt.Errorf("expected SUCCESS, got %s", msg.Command)
+	}
+	return nil
+}
+
+// PullFiles preuzima sve fajlove koji na relay serveru čekaju ovog klijenta
+// u outputDir i briše sa relay-a one koji su uspešno dekriptovani.
+func (c *TCPClient) PullFiles(outputDir, algorithm string, key []byte) ([]FileResult, error) {
+	items, err := c.ListFiles(algorithm, key)
+	if err != nil {
+		return nil, err
+	}
+	if !c.session.Capabilities.Has(FeatureRelay) {
+		return nil, NewProtocolError(ErrCodeForbidden, "server is not a relay")
+	}
+
+	results := make([]FileResult, 0, len(items))
+	for i, item := range items {
+		result, err := c.pullFile(item, outputDir, algorithm, key)
+		results = append(results, result)
+		if err != nil && !fileLevelError(err) {
+			for _, rest := range items[i+1:] {
+				_, name, _ := strings.Cut(rest.Name, "/")
+				results = append(results, FileResult{
+					FileEntry: FileEntry{Name: name},
+					Err:       fmt.Errorf("not downloaded: %w", err),
+				})
+			}
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+func (c *TCPClient) pullFile(item RemoteFile, outputDir, algorithm string, key []byte) (FileResult, error) {
+	result := FileResult{FileEntry: FileEntry{Name: item.Name}}
+
+	_, name, _ := strings.Cut(item.Name, "/")
+	relPath, err := SanitizePath(name)
+	if err != nil {
+		result.Err = NewProtocolError(ErrCodeInvalidFilename, "%v", err)
+		return result, result.Err
+	}
+	result.Name = relPath
+
+	outputPath, err := reserveOutputPath(outputDir, relPath, CollisionRename)
+	if err != nil {
+		result.Err = err
+		return result, err
+	}
+	result.Path = outputPath
+
+	if err := c.GetFile(item.Name, outputPath, algorithm, key); err != nil {
+		// prazan fajl koji je rezervisao ime
+		os.Remove(outputPath)
+		result.Err = err
+		return result, err
+	}
+
+	if err := c.deleteRemote(item.Name); err != nil {
+		result.Err = fmt.Errorf("downloaded, but not removed from relay: %w", err)
+		return result, err
+	}
+
+	logger.LogNetwork(logger.RECEIVE_FILE, c.address,
+		"File pulled from relay", true, map[string]interface{}{
+			"file":        relPath,
+			"output_file": outputPath,
+			"size":        item.Size,
+		})
+	return result, nil
+}
+
+func (c *TCPClient) deleteRemote(name string) error {
+	if err := c.session.SendMessage(c.conn, DeleteCmd, []byte(name)); err != nil {
+		return fmt.Errorf("failed to send DELETE: %w", err)
+	}
+
+	msg, err := c.session.ReceiveMessage(c.conn)
+	if err != nil {
+		return fmt.Errorf("failed to receive DELETE response: %w", err)
+	}
+	if msg.Command == ErrorCmd {
+		return fmt.Errorf("server error: %w", DecodeError(msg.Payload))
+	}
+	if msg.Command != SuccessCmd {
+		return fmt.Errorf("expected SUCCESS, got %s", msg.Command)
+	}
+	return nil
+}
@@ -2,16 +2,24 @@
 package network
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
 	"github.com/AleksaS003/zastitaprojekat/internal/core"
 	"github.com/AleksaS003/zastitaprojekat/internal/logger"
 )
@@ -20,14 +28,44 @@ import (
 type TCPServer struct {
 	address   string
 	listener  net.Listener
-	clients   map[net.Conn]bool
+	clients   map[net.Conn]bool // true dok konekcija obrađuje zahtev
 	mu        sync.RWMutex
 	active    bool
 	stopChan  chan struct{}
+	wg        sync.WaitGroup
 	outputDir string
 	key       []byte
+
+	trustedSigners []ed25519.PublicKey
+	clientRegistry *ClientRegistry
+
+	maxConnections  int
+	maxTransferSize int64
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	collisionPolicy CollisionPolicy
+	staging         *stagingArea
+	allowDownload   bool
+	socketMode      fs.FileMode
+	inbox           bool
+	storageKey      []byte
+
+	connBandwidth  int64
+	totalBandwidth *tokenBucket
+	connLimiter    *connectionLimiter
+
+	relay *relayStore
+	hook  ReceiveHook
+
+	progress progressHandler
 }
 
+const (
+	DefaultMaxConnections  = 32
+	DefaultMaxTransferSize = 1 << 30
+	DefaultTimeout         = 60 * time.Second
+)
+
 func NewTCPServer(address, outputDir string, key []byte) *TCPServer {
 	if err := logger.InitGlobal("./logs"); err != nil {
 		log.Printf("Failed to initialize logger: %v", err)
@@ -37,17 +75,100 @@ func NewTCPServer(address, outputDir string, key []byte) *TCPServer {
 		address:   address,
 		clients:   make(map[net.Conn]bool),
 		active:    false,
-		stopChan:  make(chan struct{}),
 		outputDir: outputDir,
 		key:       key,
+
+		maxConnections:  DefaultMaxConnections,
+		maxTransferSize: DefaultMaxTransferSize,
+		readTimeout:     DefaultTimeout,
+		writeTimeout:    DefaultTimeout,
+		collisionPolicy: CollisionRename,
+		staging:         newStagingArea(outputDir, DefaultStagingExpiry),
+		socketMode:      DefaultSocketMode,
 	}
 }
 
+// SetTrustedSigners uključuje obaveznu proveru potpisa: prihvataju se samo
+// fajlovi potpisani nekim od datih ključeva.
+func (s *TCPServer) SetTrustedSigners(keys []ed25519.PublicKey) {
+	s.trustedSigners = keys
+}
+
+// SetClients uključuje autentifikaciju klijenata i IP/CIDR liste iz fajla klijenata.
+func (s *TCPServer) SetClients(registry *ClientRegistry) {
+	s.clientRegistry = registry
+}
+
+// SetLimits postavlja najveći broj istovremenih konekcija i najveću veličinu
+// jednog prenosa u bajtovima (0 = bez ograničenja).
+func (s *TCPServer) SetLimits(maxConnections int, maxTransferSize int64) {
+	s.maxConnections = maxConnections
+	s.maxTransferSize = maxTransferSize
+}
+
+// SetBandwidth ograničava protok u bajtovima u sekundi po konekciji i ukupno
+// za sve konekcije (0 = bez ograničenja).
+func (s *TCPServer) SetBandwidth(perConnection, total int64) {
+	s.connBandwidth = perConnection
+	s.totalBandwidth = newBandwidthBucket(total)
+}
+
+// SetConnectionRate ograničava broj novih konekcija u minuti sa jedne IP
+// adrese (0 = bez ograničenja).
+func (s *TCPServer) SetConnectionRate(perMinute int) {
+	s.connLimiter = newConnectionLimiter(perMinute)
+}
+
+// SetTimeouts postavlja rokove za svako čitanje i pisanje na konekciji (0 = bez roka).
+func (s *TCPServer) SetTimeouts(read, write time.Duration) {
+	s.readTimeout = read
+	s.writeTimeout = write
+}
+
+// SetCollisionPolicy određuje šta se radi kada fajl sa istim imenom već postoji.
+func (s *TCPServer) SetCollisionPolicy(policy CollisionPolicy) {
+	s.collisionPolicy = policy
+}
+
+// SetProgress prijavljuje napredak svakog primljenog i poslatog fajla.
+// Funkcija se poziva iz gorutina konekcija, istovremeno za više prenosa.
+func (s *TCPServer) SetProgress(report ProgressFunc) {
+	s.progress.set(report)
+}
+
+// SetStagingExpiry određuje koliko dugo se čuvaju prekinuti prenosi (0 = zauvek).
+func (s *TCPServer) SetStagingExpiry(expiry time.Duration) {
+	s.staging.expiry = expiry
+}
+
+// SetStagingLimits postavlja kvote za nedovršene prenose.
+func (s *TCPServer) SetStagingLimits(limits StagingLimits) {
+	s.staging.limits = limits
+}
+
+// SetDownloads uključuje LIST i GET. Sa fajlom klijenata samo klijenti sa
+// dozvolom "download" mogu da ih koriste.
+func (s *TCPServer) SetDownloads(enabled bool) {
+	s.allowDownload = enabled
+}
+
+// SetSocketMode postavlja prava nad fajlom Unix socket-a (adresa unix://...),
+// npr. 0660 da bi server mogli da koriste i članovi grupe.
+func (s *TCPServer) SetSocketMode(mode fs.FileMode) {
+	s.socketMode = mode
+}
+
 // Start pokreće server
 func (s *TCPServer) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.active {
 		return fmt.Errorf("server is already running")
 	}
+	if s.relay != nil && s.clientRegistry == nil {
+		return fmt.Errorf("relay requires a clients file to identify senders and recipients")
+	}
 
 	if err := os.MkdirAll(s.outputDir, 0755); err != nil {
 		logger.Error(logger.SERVER_START, "Failed to create output directory", map[string]interface{}{
@@ -56,8 +177,13 @@ func (s *TCPServer) Start() error {
 		})
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
+	if s.hook.SpoolDir != "" {
+		if err := os.MkdirAll(s.hook.SpoolDir, 0755); err != nil {
+			return fmt.Errorf("failed to create spool directory: %w", err)
+		}
+	}
 
-	listener, err := net.Listen("tcp", s.address)
+	listener, err := listen(s.address, s.socketMode)
 	if err != nil {
 		logger.Error(logger.SERVER_START, "Failed to start TCP listener", map[string]interface{}{
 			"address": s.address,
@@ -68,88 +194,256 @@ func (s *TCPServer) Start() error {
 
 	s.listener = listener
 	s.active = true
+	s.stopChan = make(chan struct{})
 
 	logger.LogNetwork(logger.SERVER_START, s.address,
 		"TCP Server started successfully", true, map[string]interface{}{
-			"output_dir": s.outputDir,
+			"output_dir":      s.outputDir,
+			"key_info":        core.KeyFingerprint(s.key),
+			"trusted_signers": len(s.trustedSigners),
+			"client_auth":     s.clientRegistry != nil,
+			"max_connections": s.maxConnections,
+			"max_transfer":    s.maxTransferSize,
+			"read_timeout":    s.readTimeout.String(),
 		})
 
 	log.Printf("🚀 TCP Server started on %s", s.address)
 	log.Printf("   Output directory: %s", s.outputDir)
 
-	// Accept loop - OVAJ LOOP MORA DA TRAJE DOK SE SERVER NE ZAUSTAVI
-	go s.acceptLoop()
+	s.staging.removeOutputs()
+	s.staging.cleanExpired()
+	if s.relay != nil {
+		s.relay.cleanExpired()
+	}
+
+	s.wg.Add(2)
+	go s.stagingCleanupLoop(s.stopChan)
+	go s.acceptLoop(listener, s.stopChan)
 
 	return nil
 }
 
-// Stop zaustavlja server
+// Stop odmah zatvara sve konekcije i čeka da se njihove gorutine završe.
+// Prekinuti prenosi ostaju u staging-u i klijenti mogu da ih nastave.
 func (s *TCPServer) Stop() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.Shutdown(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown prestaje da prihvata konekcije, zatvara konekcije koje čekaju
+// sledeći zahtev i pušta zahteve u toku da se završe. Kada ctx istekne,
+// preostale konekcije se zatvaraju. Vraća se tek kada sve gorutine servera
+// izađu; posle toga u staging-u nema delimično dekriptovanih fajlova.
+func (s *TCPServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
 	if !s.active {
+		s.mu.Unlock()
 		return fmt.Errorf("server is not running")
 	}
-
-	close(s.stopChan)
 	s.active = false
+	close(s.stopChan)
+	listenerErr := s.listener.Close()
+
+	var idle, busy int
+	for conn, working := range s.clients {
+		if working {
+			busy++
+			continue
+		}
+		conn.Close()
+		idle++
+	}
+	s.mu.Unlock()
+
+	logger.LogNetwork(logger.SERVER_STOP, s.address,
+		"TCP Server shutting down", true, map[string]interface{}{
+			"idle_closed": idle,
+			"in_flight":   busy,
+		})
+	if busy > 0 {
+		log.Printf("Waiting for %d transfers in progress...", busy)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+
+		s.mu.Lock()
+		forced := len(s.clients)
+		for conn := range s.clients {
+			conn.Close()
+		}
+		s.mu.Unlock()
+
+		if forced > 0 {
+			logger.Warning(logger.SERVER_STOP, "Connections closed before their transfers finished", true, map[string]interface{}{
+				"connections": forced,
+				"reason":      err.Error(),
+			})
+			log.Printf("Closed %d connections with transfers in progress", forced)
+		}
+		<-done
+	}
+
+	// delimični prenosi ostaju kao .part za nastavak, dekriptovani delovi se brišu
+	s.staging.removeOutputs()
 
 	logger.LogNetwork(logger.SERVER_STOP, s.address,
 		"TCP Server stopped", true, nil)
+	log.Printf("Server stopped.")
 
-	s.mu.Lock()
-	clientCount := len(s.clients)
-	for conn := range s.clients {
-		conn.Close()
-		delete(s.clients, conn)
+	if err == nil && listenerErr != nil && !errors.Is(listenerErr, net.ErrClosed) {
+		return listenerErr
 	}
-	s.mu.Unlock()
+	return err
+}
 
-	log.Printf("Server stopped. Disconnected %d clients.", clientCount)
+// stopping javlja da je gašenje servera počelo.
+func (s *TCPServer) stopping() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !s.active
+}
 
-	if s.listener != nil {
-		return s.listener.Close()
+// setBusy označava da konekcija obrađuje zahtev (busy) ili čeka sledeći.
+// Shutdown odmah zatvara konekcije koje čekaju. Vraća false kada je gašenje
+// već počelo, pa konekcija ne treba da prima nove zahteve.
+func (s *TCPServer) setBusy(conn net.Conn, busy bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.active {
+		return false
 	}
+	s.clients[conn] = busy
+	return true
+}
 
-	return nil
+// stagingCleanupLoop periodično briše istekle prekinute prenose
+func (s *TCPServer) stagingCleanupLoop(stop <-chan struct{}) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(stagingCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.staging.cleanExpired()
+			if s.relay != nil {
+				s.relay.cleanExpired()
+			}
+		}
+	}
 }
 
-// acceptLoop prihvata nove konekcije
-func (s *TCPServer) acceptLoop() {
+// acceptLoop prihvata nove konekcije dok se listener ne zatvori. Prolazne
+// greške (npr. previše otvorenih fajlova) se ponavljaju sa rastućom pauzom.
+func (s *TCPServer) acceptLoop(listener net.Listener, stop <-chan struct{}) {
+	defer s.wg.Done()
+
 	logger.Info(logger.SERVER_START, "Server accept loop started", true, map[string]interface{}{
 		"address": s.address,
 	})
 
-	for s.active {
-		conn, err := s.listener.Accept()
+	var delay time.Duration
+	for {
+		conn, err := listener.Accept()
 		if err != nil {
-			if s.active {
-				logger.Error(logger.CLIENT_CONNECT, "Accept error", map[string]interface{}{
-					"address": s.address,
-					"error":   err.Error(),
-				})
-				log.Printf("Accept error: %v", err)
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			logger.Error(logger.CLIENT_CONNECT, "Accept error", map[string]interface{}{
+				"address": s.address,
+				"error":   err.Error(),
+			})
+			log.Printf("Accept error: %v", err)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+			select {
+			case <-stop:
+				return
+			case <-time.After(delay):
 			}
-			// NE IZLAZI IZ PETLJE - SAMO LOGUJ I NASTAVI
 			continue
 		}
+		delay = 0
+
+		if s.clientRegistry != nil && !s.clientRegistry.AllowsIP(remoteIP(conn.RemoteAddr())) {
+			logger.LogNetwork(logger.CLIENT_AUTH, conn.RemoteAddr().String(),
+				"Connection rejected: address not in any client allowlist", false, nil)
+			conn.Close()
+			continue
+		}
+
+		if !s.connLimiter.allow(remoteIP(conn.RemoteAddr()).String()) {
+			logger.LogNetwork(logger.CLIENT_CONNECT, conn.RemoteAddr().String(),
+				"Connection rejected: connection rate limit", false, map[string]interface{}{
+					"per_minute": s.connLimiter.perMinute,
+				})
+			s.sendError(conn, nil, ErrCodeRateLimited,
+				fmt.Errorf("too many connections from this address, limit is %d per minute", s.connLimiter.perMinute))
+			conn.Close()
+			continue
+		}
+		conn = limitConn(conn, newBandwidthBucket(s.connBandwidth), s.totalBandwidth)
 
 		s.mu.Lock()
-		s.clients[conn] = true
+		if !s.active {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		if s.maxConnections > 0 && len(s.clients) >= s.maxConnections {
+			s.mu.Unlock()
+			logger.LogNetwork(logger.CLIENT_CONNECT, conn.RemoteAddr().String(),
+				"Connection rejected: too many connections", false, map[string]interface{}{
+					"max_connections": s.maxConnections,
+				})
+			s.sendError(conn, nil, ErrCodeTooManyConnections,
+				fmt.Errorf("server is at its limit of %d connections", s.maxConnections))
+			conn.Close()
+			continue
+		}
+		s.clients[conn] = false
+		s.wg.Add(1)
 		s.mu.Unlock()
 
 		// Svaka konekcija dobija svoju gorutinu
-		go s.handleConnection(conn)
+		go s.handleConnection(&deadlineConn{Conn: conn, readTimeout: s.readTimeout, writeTimeout: s.writeTimeout})
 	}
 }
 
 // handleConnection obrađuje konekciju sa klijentom
-func (s *TCPServer) handleConnection(conn net.Conn) {
+func (s *TCPServer) handleConnection(conn *deadlineConn) {
 	remoteAddr := conn.RemoteAddr().String()
 
 	defer func() {
-		conn.Close()
+		conn.closeLingering()
 		s.mu.Lock()
-		delete(s.clients, conn)
+		delete(s.clients, conn.Conn)
 		s.mu.Unlock()
+		s.wg.Done()
 
 		logger.Info(logger.CLIENT_CONNECT, "Client disconnected", true, map[string]interface{}{
 			"remote_addr": remoteAddr,
@@ -164,7 +458,11 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 	log.Printf("New client connected: %s", remoteAddr)
 
 	// 1. Handshake
-	if err := s.doHandshake(conn); err != nil {
+	session, err := s.doHandshake(conn)
+	if err != nil {
+		if s.stopping() {
+			return
+		}
 		logger.Error(logger.CLIENT_CONNECT, "Handshake failed", map[string]interface{}{
 			"remote_addr": remoteAddr,
 			"error":       err.Error(),
@@ -175,124 +473,460 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 
 	logger.Info(logger.CLIENT_CONNECT, "Handshake successful", true, map[string]interface{}{
 		"remote_addr": remoteAddr,
+		"session_id":  session.ID,
 	})
 
-	// 2. Prijem fajla
-	filePath, metadata, err := s.receiveFile(conn)
+	// 2. Autentifikacija klijenta
+	identity, err := s.authenticateClient(conn, session)
+	if err != nil {
+		logger.LogNetwork(logger.CLIENT_AUTH, remoteAddr,
+			"Client rejected", false, map[string]interface{}{
+				"identity":   identity,
+				"session_id": session.ID,
+				"error":      err.Error(),
+			})
+		log.Printf("Client %s rejected: %v", remoteAddr, err)
+		s.sendError(conn, session, ErrCodeAuthFailed, err)
+		return
+	}
+
+	if s.clientRegistry != nil {
+		logger.LogNetwork(logger.CLIENT_AUTH, remoteAddr,
+			"Client authenticated", true, map[string]interface{}{
+				"identity":   identity,
+				"session_id": session.ID,
+			})
+		log.Printf("Client %s authenticated as %s", remoteAddr, identity)
+	}
+
+	// 3. Zahtevi (prijem fajlova, LIST, GET) dok klijent ne pošalje END ili ne zatvori konekciju
+	var received, failed, served int
+	var recipient string
+	for {
+		// konekcija koja čeka sledeći zahtev se zatvara odmah pri gašenju
+		if !s.setBusy(conn.Conn, false) {
+			break
+		}
+		msg, err := session.ReceiveMessage(conn)
+		if err != nil {
+			if s.stopping() {
+				break
+			}
+			// stari klijenti šalju jedan fajl i zatvaraju konekciju
+			if received+failed+served == 0 || !errors.Is(err, io.EOF) {
+				logger.Error(logger.RECEIVE_FILE, "File receive failed", map[string]interface{}{
+					"remote_addr": remoteAddr,
+					"error":       err.Error(),
+				})
+				log.Printf("File receive failed from %s: %v", remoteAddr, err)
+				s.sendError(conn, session, ErrCodeProtocol, err)
+			}
+			break
+		}
+		if !s.setBusy(conn.Conn, true) {
+			s.sendError(conn, session, ErrCodeShuttingDown, fmt.Errorf("server is shutting down"))
+			break
+		}
+
+		switch msg.Command {
+		case EndCmd:
+			summary := fmt.Sprintf("%d|%d", received, failed)
+			session.SendMessage(conn, SummaryCmd, []byte(summary))
+		case RecipientCmd:
+			recipient, err = s.handleRecipient(conn, session, msg.Payload)
+		case DeleteCmd:
+			err = s.handleDelete(conn, session, identity, string(msg.Payload))
+			served++
+		case ListCmd:
+			if s.relay != nil {
+				err = s.handleMailboxList(conn, session, identity)
+			} else {
+				err = s.handleList(conn, session, identity)
+			}
+			served++
+		case GetCmd:
+			if s.relay != nil {
+				err = s.handleMailboxGet(conn, session, identity, string(msg.Payload))
+			} else {
+				err = s.handleGet(conn, session, identity, string(msg.Payload))
+			}
+			served++
+		default:
+			if s.relay != nil {
+				err = s.handleRelayUpload(conn, session, identity, recipient, msg)
+			} else {
+				err = s.handleTransfer(conn, session, identity, msg)
+			}
+			if err != nil {
+				failed++
+			} else {
+				received++
+			}
+		}
+
+		if msg.Command == EndCmd || (err != nil && !fileLevelError(err)) {
+			break
+		}
+	}
+
+	logger.LogNetwork(logger.RECEIVE_FILE, remoteAddr,
+		"Client session finished", failed == 0, map[string]interface{}{
+			"session_id": session.ID,
+			"identity":   identity,
+			"received":   received,
+			"failed":     failed,
+			"served":     served,
+		})
+
+	// NE GASI SERVER! Samo zatvori konekciju (defer će to uraditi)
+	// Server nastavlja da radi i čeka nove konekcije
+}
+
+// handleTransfer prima, proverava i dekriptuje jedan fajl i odgovara sa
+// SUCCESS ili ERROR. Vraćena greška nosi kod poslat klijentu.
+func (s *TCPServer) handleTransfer(conn *deadlineConn, session *Session, identity string, startMsg Message) error {
+	remoteAddr := conn.RemoteAddr().String()
+
+	// 1. Prijem uz dekripciju, proveru heša i potpisa
+	received, err := s.receiveFile(conn, session, identity, startMsg)
 	if err != nil {
 		logger.Error(logger.RECEIVE_FILE, "File receive failed", map[string]interface{}{
 			"remote_addr": remoteAddr,
 			"error":       err.Error(),
 		})
 		log.Printf("File receive failed from %s: %v", remoteAddr, err)
-		SendMessage(conn, ErrorCmd, []byte(err.Error()))
-		return
+		return s.sendError(conn, session, ErrCodeProtocol, err)
 	}
+	metadata := received.metadata
 
-	logger.Info(logger.RECEIVE_FILE, "File received from client", true, map[string]interface{}{
-		"remote_addr":    remoteAddr,
-		"temp_file":      filePath,
-		"original_file":  metadata.Filename,
-		"algorithm":      metadata.EncryptionAlgorithm,
-		"hash_algorithm": metadata.HashAlgorithm,
-	})
-
-	// 3. Verifikacija i dekripcija
-	if err := s.verifyAndDecrypt(filePath, metadata); err != nil {
-		logger.Error(logger.RECEIVE_FILE, "File verification/decryption failed", map[string]interface{}{
+	if received.signer != "" {
+		logger.Info(logger.RECEIVE_FILE, "Signature verified", true, map[string]interface{}{
 			"remote_addr": remoteAddr,
-			"file":        metadata.Filename,
-			"error":       err.Error(),
+			"file":        received.name,
+			"signer":      received.signer,
 		})
-		log.Printf("Verification failed for %s: %v", remoteAddr, err)
-		SendMessage(conn, ErrorCmd, []byte(err.Error()))
-		return
 	}
 
-	// 4. Success
-	outputPath := filepath.Join(s.outputDir, metadata.Filename)
-	fileInfo, _ := os.Stat(outputPath)
-
-	logger.LogEncryption("decrypt", metadata.EncryptionAlgorithm, outputPath,
-		fileInfo.Size(), true, map[string]interface{}{
-			"remote_addr":    remoteAddr,
-			"hash_verified":  true,
-			"hash_algorithm": metadata.HashAlgorithm,
+	// 2. Premeštanje proverenog fajla na konačno mesto
+	outputPath, err := s.publishFile(received.path, received.name)
+	if err != nil {
+		logger.Error(logger.RECEIVE_FILE, "Output file not available", map[string]interface{}{
+			"remote_addr":      remoteAddr,
+			"file":             received.name,
+			"collision_policy": string(s.collisionPolicy),
+			"error":            err.Error(),
 		})
+		received.progress.finish(err)
+		return s.sendError(conn, session, ErrCodeInternal, err)
+	}
+	received.progress.finish(nil)
+
+	// 3. Success
+	fileInfo, err := os.Stat(outputPath)
+	if err != nil {
+		return s.sendError(conn, session, ErrCodeInternal, err)
+	}
 
-	log.Printf("File successfully received from %s: %s", remoteAddr, metadata.Filename)
-	SendMessage(conn, SuccessCmd, []byte("File received and verified"))
+	if s.inbox {
+		logger.LogNetwork(logger.RECEIVE_FILE, remoteAddr,
+			"Encrypted file stored", true, map[string]interface{}{
+				"output_file":    outputPath,
+				"size":           fileInfo.Size(),
+				"algorithm":      metadata.EncryptionAlgorithm,
+				"hash_verified":  metadata.Hash != "",
+				"storage_key_fp": s.storageKeyInfo(),
+				"signer":         received.signer,
+			})
+	} else {
+		logger.LogEncryption("decrypt", metadata.EncryptionAlgorithm, outputPath,
+			fileInfo.Size(), true, map[string]interface{}{
+				"remote_addr":    remoteAddr,
+				"hash_verified":  metadata.Hash != "",
+				"hash_algorithm": metadata.HashAlgorithm,
+				"iv_used":        metadata.IV != "",
+				"signer":         received.signer,
+			})
+	}
 
-	// 5. NE GASI SERVER! Samo zatvori konekciju (defer će to uraditi)
-	// Server nastavlja da radi i čeka nove konekcije
+	log.Printf("✅ File successfully received from %s: %s (%d bytes)", remoteAddr, received.name, fileInfo.Size())
+
+	if absPath, err := filepath.Abs(outputPath); err == nil {
+		outputPath = absPath
+	}
+	s.postReceive(ReceiveEvent{
+		TransferID:    received.id,
+		File:          received.name,
+		Path:          outputPath,
+		Size:          fileInfo.Size(),
+		Sender:        identity,
+		RemoteAddr:    remoteAddr,
+		Algorithm:     metadata.EncryptionAlgorithm,
+		HashAlgorithm: metadata.HashAlgorithm,
+		Hash:          metadata.Hash,
+		Signer:        received.signer,
+		Encrypted:     s.inbox,
+		Received:      time.Now().UTC(),
+	})
+	return session.SendMessage(conn, SuccessCmd, []byte(received.name))
 }
 
-// doHandshake izvršava inicijalni handshake
-func (s *TCPServer) doHandshake(conn net.Conn) error {
-	msg, err := ReceiveMessage(conn)
+// doHandshake izvršava razmenu efemernih ključeva autentifikovanu ključem
+// servera i dogovor o mogućnostima.
+func (s *TCPServer) doHandshake(conn *deadlineConn) (*Session, error) {
+	session, err := serverHandshake(conn, s.key, s.capabilities())
 	if err != nil {
-		return fmt.Errorf("failed to receive HELLO: %w", err)
+		if errors.Is(err, ErrHandshakeAuth) {
+			s.sendError(conn, nil, ErrCodeAuthFailed, err)
+		} else if code := errorCode(err, ""); code != "" {
+			s.sendError(conn, nil, code, err)
+		}
+		return nil, err
 	}
 
-	if msg.Command != HelloCmd {
-		return fmt.Errorf("expected HELLO, got %s", msg.Command)
+	logger.Info(logger.CLIENT_CONNECT, "Client capabilities", true, map[string]interface{}{
+		"remote_addr":      conn.RemoteAddr().String(),
+		"protocol_version": session.Capabilities.Version,
+		"capabilities":     session.Capabilities.String(),
+		"session_id":       session.ID,
+		"key_exchange":     "X25519",
+	})
+
+	log.Printf("Client protocol v%d, algorithms %s (session %s)", session.Capabilities.Version,
+		strings.Join(session.Capabilities.Algorithms, ","), session.ID)
+	return session, nil
+}
+
+func (s *TCPServer) capabilities() Capabilities {
+	features := []string{FeatureResume, FeatureSession}
+	if s.relay != nil {
+		// relay ne čuva prekinute prenose, a svaki primalac preuzima svoje fajlove
+		features = []string{FeatureSession, FeatureDownload, FeatureRelay}
+	} else if s.allowDownload {
+		features = append(features, FeatureDownload)
+	}
+	return Capabilities{
+		Version:     ProtocolVersion,
+		Algorithms:  SupportedAlgorithms,
+		Hashes:      []string{HashSHA256},
+		Compression: []string{CompressionNone},
+		MaxFrame:    MaxPacketSize,
+		Features:    features,
 	}
+}
 
-	logger.Info(logger.CLIENT_CONNECT, "Client algorithms", true, map[string]interface{}{
-		"remote_addr": conn.RemoteAddr().String(),
-		"algorithms":  string(msg.Payload),
-	})
+// authenticateClient traži od klijenta HMAC nad izazovom servera tajnom
+// iz fajla klijenata. Bez fajla klijenata svi klijenti su dozvoljeni.
+func (s *TCPServer) authenticateClient(conn *deadlineConn, session *Session) (string, error) {
+	if s.clientRegistry == nil {
+		return "", session.SendMessage(conn, AuthOKCmd, nil)
+	}
+
+	challenge, err := newChallenge()
+	if err != nil {
+		return "", err
+	}
+	if err := session.SendMessage(conn, ChallengeCmd, challenge); err != nil {
+		return "", fmt.Errorf("failed to send CHALLENGE: %w", err)
+	}
+
+	msg, err := session.ReceiveMessage(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to receive AUTH_RESPONSE: %w", err)
+	}
+	if msg.Command != AuthResponseCmd {
+		return "", fmt.Errorf("expected AUTH_RESPONSE, got %s", msg.Command)
+	}
 
-	log.Printf("Client algorithms: %s", string(msg.Payload))
+	identity, responseHex, found := strings.Cut(string(msg.Payload), "|")
+	if !found {
+		return "", fmt.Errorf("%w: malformed response", ErrClientAuth)
+	}
+	response, err := hex.DecodeString(responseHex)
+	if err != nil {
+		return identity, fmt.Errorf("%w: malformed response", ErrClientAuth)
+	}
+
+	if err := s.clientRegistry.Verify(identity, response, challenge, session.ID, remoteIP(conn.RemoteAddr())); err != nil {
+		return identity, err
+	}
 
-	return SendMessage(conn, ReadyCmd, []byte("LEA,PCBC,SHA256"))
+	return identity, session.SendMessage(conn, AuthOKCmd, []byte(identity))
 }
 
-// receiveFile prima fajl od klijenta
-func (s *TCPServer) receiveFile(conn net.Conn) (string, *core.Metadata, error) {
+// sendError šalje ERROR sa kodom razloga; pre handshake-a bez sesije.
+// Vraća grešku sa kodom koji je poslat.
+func (s *TCPServer) sendError(conn net.Conn, session *Session, fallback ErrorCode, err error) *ProtocolError {
+	code := errorCode(err, fallback)
+	payload := EncodeError(code, err)
+	if session != nil {
+		session.SendMessage(conn, ErrorCmd, payload)
+	} else {
+		SendMessage(conn, ErrorCmd, payload)
+	}
+	return DecodeError(payload)
+}
+
+// receivedFile je dekriptovan i proveren fajl koji još čeka u staging-u.
+// metadata je zaglavlje kontejnera, a name putanja iz FILE_START koja se
+// završava imenom iz zaglavlja.
+type receivedFile struct {
+	id       string
+	path     string
+	name     string
+	metadata *core.Metadata
+	signer   string
+	progress *progressTracker
+}
+
+// receiveFile prima fajl od klijenta i dekriptuje ga dok stiže: šifrovani
+// podaci idu u .part (za nastavak posle prekida), a dekriptovani u staging
+// fajl koji postaje vidljiv tek kada heš i potpis budu provereni. Novi
+// prenos počinje sa FILE_START, a prekinuti se nastavlja sa RESUME od
+// poslednjeg primljenog bajta.
+func (s *TCPServer) receiveFile(conn *deadlineConn, session *Session, identity string, startMsg Message) (*receivedFile, error) {
 	remoteAddr := conn.RemoteAddr().String()
 
-	// 1. FILE_START poruka
-	startMsg, err := ReceiveMessage(conn)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to receive FILE_START: %w", err)
+	var (
+		err      error
+		state    *stagedTransfer
+		metadata *core.Metadata
+		part     *os.File
+		offset   int64
+	)
+
+	if startMsg.Command == ResumeCmd {
+		state, part, offset, err = s.staging.resume(string(startMsg.Payload), identity)
+		if errorCode(err, "") == ErrCodeUnknownTransfer {
+			// klijent posle ovoga počinje prenos iz početka na istoj konekciji
+			s.sendError(conn, session, ErrCodeUnknownTransfer, err)
+			if startMsg, err = session.ReceiveMessage(conn); err != nil {
+				return nil, fmt.Errorf("failed to receive FILE_START: %w", err)
+			}
+			if startMsg.Command != FileStartCmd {
+				return nil, fmt.Errorf("expected FILE_START, got %s", startMsg.Command)
+			}
+		} else if err != nil {
+			return nil, err
+		}
 	}
 
-	if startMsg.Command != FileStartCmd {
-		return "", nil, fmt.Errorf("expected FILE_START, got %s", startMsg.Command)
+	switch startMsg.Command {
+	case FileStartCmd:
+		state, metadata, err = s.startTransfer(conn, session, startMsg, identity)
+		if err == nil {
+			part, err = s.staging.create(state)
+		}
+		if err != nil {
+			if fileLevelError(err) {
+				// klijent već šalje podatke; odbacuju se da sesija može da nastavi
+				if discardErr := discardFileData(conn, session, s.maxTransferSize); discardErr != nil {
+					return nil, discardErr
+				}
+			}
+			return nil, err
+		}
+
+		logger.Info(logger.RECEIVE_FILE, "File transfer started", true, map[string]interface{}{
+			"remote_addr": remoteAddr,
+			"start_info":  string(startMsg.Payload),
+			"transfer_id": state.ID,
+		})
+
+	case ResumeCmd:
+		metadata, err = core.FromJSON(state.Metadata)
+		if err == nil {
+			err = s.checkStorable(metadata)
+		}
+		if err == nil {
+			err = s.checkCollision(state.Filename)
+		}
+		if err != nil {
+			part.Close()
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("expected FILE_START or RESUME, got %s", startMsg.Command)
+	}
+	defer part.Close()
+
+	// u inbox režimu se kontejner samo proverava, a čuva se .part fajl
+	var (
+		out       *os.File
+		container *core.ContainerWriter
+	)
+	if s.inbox {
+		container = core.NewContainerChecker()
+	} else {
+		out, err = os.OpenFile(s.staging.outputPath(state.ID), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			s.staging.remove(state.ID)
+			return nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer out.Close()
+		container = core.NewContainerWriter(outputFile{out}, s.key)
+	}
+	if len(s.trustedSigners) > 0 {
+		container.RequireSignature(s.trustedSigners)
 	}
 
-	logger.Info(logger.RECEIVE_FILE, "File transfer started", true, map[string]interface{}{
-		"remote_addr": remoteAddr,
-		"start_info":  string(startMsg.Payload),
+	progress := s.progress.track(Progress{
+		TransferID: state.ID,
+		Name:       state.Filename,
+		Direction:  DirectionReceive,
+		Remote:     remoteAddr,
+		Done:       offset,
+		Total:      state.Size,
 	})
 
-	// 2. Prijem metadata
-	metadataMsg, err := ReceiveMessage(conn)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to receive metadata: %w", err)
+	fail := func(err error) (*receivedFile, error) {
+		progress.finish(err)
+		// posle prekida veze klijent može da nastavi iz .part fajla, inače se
+		// prenos odbacuje; dekriptovan deo se ne čuva ni u jednom slučaju
+		if out != nil {
+			out.Close()
+		}
+		if isConnectionError(err) {
+			os.Remove(s.staging.outputPath(state.ID))
+		} else {
+			part.Close()
+			s.staging.remove(state.ID)
+		}
+		return nil, err
 	}
 
-	metadata, err := core.FromJSON(metadataMsg.Payload)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to parse metadata: %w", err)
-	}
+	if startMsg.Command == ResumeCmd {
+		// već primljen deo se ponovo dekriptuje da bi heš i lanac šifre
+		// nastavili od mesta prekida
+		if err := s.replayStaged(state.ID, offset, container); err != nil {
+			return fail(err)
+		}
+		if container.Metadata != nil {
+			if err := s.checkContainerHeader(metadata, container.Metadata); err != nil {
+				return fail(err)
+			}
+		}
+		if err := session.SendMessage(conn, ResumeOKCmd, []byte(strconv.FormatInt(offset, 10))); err != nil {
+			return fail(err)
+		}
 
-	// 3. Generiši putanju za privremeni fajl
-	tempFile := filepath.Join(s.outputDir, fmt.Sprintf("temp_%d.enc", time.Now().UnixNano()))
-	file, err := os.Create(tempFile)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+		logger.Info(logger.RECEIVE_FILE, "File transfer resumed", true, map[string]interface{}{
+			"remote_addr": remoteAddr,
+			"transfer_id": state.ID,
+			"offset":      offset,
+			"size":        state.Size,
+		})
+		log.Printf("Resuming transfer %s at %d/%d bytes", state.ID, offset, state.Size)
 	}
-	defer file.Close()
 
-	// 4. Prijem fajla (chunkovano)
-	var totalReceived int64
+	// 2. Prijem fajla (chunkovano)
+	totalReceived := offset
+	headerChecked := container.Metadata != nil
 
 	for {
-		msg, err := ReceiveMessage(conn)
+		msg, err := session.ReceiveMessage(conn)
 		if err != nil {
-			return "", nil, fmt.Errorf("failed to receive file data: %w", err)
+			return fail(fmt.Errorf("failed to receive file data: %w", err))
 		}
 
 		if msg.Command == FileEndCmd {
@@ -300,99 +934,235 @@ func (s *TCPServer) receiveFile(conn net.Conn) (string, *core.Metadata, error) {
 		}
 
 		if msg.Command != FileDataCmd {
-			return "", nil, fmt.Errorf("expected FILE_DATA or FILE_END, got %s", msg.Command)
+			return fail(fmt.Errorf("expected FILE_DATA or FILE_END, got %s", msg.Command))
 		}
 
-		n, err := file.Write(msg.Payload)
-		if err != nil {
-			return "", nil, fmt.Errorf("failed to write to file: %w", err)
+		if totalReceived+int64(len(msg.Payload)) > state.Size {
+			return fail(NewProtocolError(ErrCodeProtocol,
+				"received more than the declared %d bytes", state.Size))
 		}
 
+		n, err := part.Write(msg.Payload)
+		if err != nil {
+			return fail(fmt.Errorf("failed to write to file: %w", err))
+		}
 		totalReceived += int64(n)
+		progress.update(totalReceived)
+
+		_, err = container.Write(msg.Payload)
+		if err != nil {
+			err = containerError(err)
+		} else if !headerChecked && container.Metadata != nil {
+			headerChecked = true
+			err = s.checkContainerHeader(metadata, container.Metadata)
+		}
+		if err != nil {
+			if fileLevelError(err) {
+				if discardErr := discardFileData(conn, session, s.maxTransferSize); discardErr != nil {
+					return fail(discardErr)
+				}
+			}
+			return fail(err)
+		}
 	}
 
+	if totalReceived != state.Size {
+		return fail(NewProtocolError(ErrCodeProtocol,
+			"received %d of the declared %d bytes", totalReceived, state.Size))
+	}
+	if err := container.Close(); err != nil {
+		return fail(containerError(err))
+	}
+	if s.inbox {
+		part.Close()
+		if err := s.storeContainer(s.staging.partPath(state.ID), s.staging.outputPath(state.ID)); err != nil {
+			return fail(NewProtocolError(ErrCodeInternal, "%v", err))
+		}
+	} else if err := out.Close(); err != nil {
+		return fail(NewProtocolError(ErrCodeInternal, "failed to write output file: %v", err))
+	}
+
+	// prenos je završen, ostaje samo dekriptovan (ili u inbox režimu šifrovan) fajl
+	part.Close()
+	os.Remove(s.staging.partPath(state.ID))
+	os.Remove(s.staging.statePath(state.ID))
+
 	logger.Info(logger.RECEIVE_FILE, "File transfer completed", true, map[string]interface{}{
 		"remote_addr":    remoteAddr,
-		"temp_file":      tempFile,
-		"bytes_received": totalReceived,
-		"original_file":  metadata.Filename,
-		"algorithm":      metadata.EncryptionAlgorithm,
+		"transfer_id":    state.ID,
+		"bytes_received": totalReceived - offset,
+		"resumed_at":     offset,
+		"original_file":  container.Metadata.Filename,
+		"algorithm":      container.Metadata.EncryptionAlgorithm,
 	})
 
-	log.Printf("File received: %s (%d bytes)", tempFile, totalReceived)
-	return tempFile, metadata, nil
+	log.Printf("File received: %s (%d bytes)", state.Filename, totalReceived)
+	// dalje se koristi provereno zaglavlje, ne METADATA poruka
+	return &receivedFile{
+		id:       state.ID,
+		path:     s.staging.outputPath(state.ID),
+		name:     state.Filename,
+		metadata: container.Metadata,
+		signer:   container.Signer,
+		progress: progress,
+	}, nil
 }
 
-// verifyAndDecrypt verifikuje i dekriptuje primljeni fajl
-func (s *TCPServer) verifyAndDecrypt(encryptedPath string, metadata *core.Metadata) error {
-	filename := metadata.Filename
-	if idx := strings.LastIndex(filename, "/"); idx != -1 {
-		filename = filename[idx+1:]
+// checkContainerHeader proverava zaglavlje kontejnera čim je pročitano:
+// mora da odgovara METADATA poruci i da može da se sačuva u inbox režimu.
+func (s *TCPServer) checkContainerHeader(claimed, header *core.Metadata) error {
+	if err := checkHeader(claimed, header); err != nil {
+		return err
 	}
-	if idx := strings.LastIndex(filename, "\\"); idx != -1 {
-		filename = filename[idx+1:]
+	return s.checkStorable(header)
+}
+
+// replayStaged provlači prvih offset bajtova iz .part fajla kroz dekripciju.
+func (s *TCPServer) replayStaged(id string, offset int64, container *core.ContainerWriter) error {
+	staged, err := os.Open(s.staging.partPath(id))
+	if err != nil {
+		return fmt.Errorf("failed to open staging file: %w", err)
 	}
+	defer staged.Close()
 
-	outputPath := filepath.Join(s.outputDir, filename)
+	if _, err := io.CopyN(container, staged, offset); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("staging file is shorter than %d bytes", offset)
+		}
+		return containerError(err)
+	}
+	return nil
+}
 
-	if err := os.MkdirAll(s.outputDir, 0755); err != nil {
-		logger.Error(logger.RECEIVE_FILE, "Failed to create output directory", map[string]interface{}{
-			"directory": s.outputDir,
-			"error":     err.Error(),
-		})
-		return fmt.Errorf("failed to create output directory: %w", err)
+// discardFileData čita i odbacuje FILE_DATA poruke odbijenog fajla do FILE_END.
+func discardFileData(conn *deadlineConn, session *Session, limit int64) error {
+	var discarded int64
+	for {
+		msg, err := session.ReceiveMessage(conn)
+		if err != nil {
+			return fmt.Errorf("failed to receive file data: %w", err)
+		}
+		switch msg.Command {
+		case FileEndCmd:
+			return nil
+		case FileDataCmd:
+			discarded += int64(len(msg.Payload))
+			if limit > 0 && discarded > limit {
+				return NewProtocolError(ErrCodeTransferTooLarge, "transfer exceeds limit of %d bytes", limit)
+			}
+		default:
+			return fmt.Errorf("expected FILE_DATA or FILE_END, got %s", msg.Command)
+		}
 	}
+}
 
-	logger.Info(logger.DECRYPT, "Starting file decryption", true, map[string]interface{}{
-		"input_file":  encryptedPath,
-		"output_file": outputPath,
-		"algorithm":   metadata.EncryptionAlgorithm,
-		"hash_check":  metadata.Hash != "",
-	})
+// startTransfer obrađuje FILE_START i METADATA poruke novog prenosa.
+func (s *TCPServer) startTransfer(conn *deadlineConn, session *Session, startMsg Message, identity string) (*stagedTransfer, *core.Metadata, error) {
+	name, declaredSize, transferID, err := parseFileStart(startMsg.Payload)
+	if err != nil {
+		return nil, nil, NewProtocolError(ErrCodeProtocol, "malformed FILE_START: %v", err)
+	}
+	if s.maxTransferSize > 0 && declaredSize > s.maxTransferSize {
+		return nil, nil, NewProtocolError(ErrCodeTransferTooLarge,
+			"file of %d bytes exceeds transfer limit of %d bytes", declaredSize, s.maxTransferSize)
+	}
+	if transferID == "" {
+		if transferID, err = newTransferID(); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	fileProcessor := core.NewFileProcessor()
-	_, err := fileProcessor.DecryptFileWithMetadata(encryptedPath, outputPath, s.key)
+	metadataMsg, err := session.ReceiveMessage(conn)
 	if err != nil {
-		logger.Error(logger.DECRYPT, "Decryption failed", map[string]interface{}{
-			"input_file":  encryptedPath,
-			"output_file": outputPath,
-			"algorithm":   metadata.EncryptionAlgorithm,
-			"error":       err.Error(),
-		})
-		return fmt.Errorf("decryption/verification failed: %w", err)
+		return nil, nil, fmt.Errorf("failed to receive metadata: %w", err)
 	}
 
-	if metadata.Hash != "" {
-		decryptedData, err := os.ReadFile(outputPath)
-		if err == nil {
-			decryptedHash := sha256.HashBytes(decryptedData)
-			decryptedHashStr := sha256.HashToString(decryptedHash)
+	metadata, err := core.FromJSON(metadataMsg.Payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
 
-			hashMatch := decryptedHashStr == metadata.Hash
-			logger.LogHashVerification(outputPath, metadata.Hash, decryptedHashStr, hashMatch)
+	// fajl šifrovan drugim ključem se odbija pre prijema podataka
+	if err := metadata.CheckKey(s.key); err != nil {
+		logger.Error(logger.RECEIVE_FILE, "Key fingerprint mismatch", map[string]interface{}{
+			"remote_addr":     conn.RemoteAddr().String(),
+			"file":            metadata.Filename,
+			"file_key_info":   metadata.KeyInfo,
+			"server_key_info": core.KeyFingerprint(s.key),
+		})
+		return nil, nil, NewProtocolError(ErrCodeKeyMismatch, "%v", err)
+	}
+	if !slices.Contains(session.Capabilities.Algorithms, metadata.EncryptionAlgorithm) {
+		return nil, nil, NewProtocolError(ErrCodeNoCommonAlgorithm,
+			"algorithm %s was not negotiated for this session", metadata.EncryptionAlgorithm)
+	}
 
-			if !hashMatch {
-				logger.Error(logger.VERIFY_HASH, "Hash verification failed", map[string]interface{}{
-					"file":          outputPath,
-					"expected_hash": metadata.Hash,
-					"actual_hash":   decryptedHashStr,
-				})
-				return fmt.Errorf("hash verification failed")
-			}
+	filename, err := SanitizeFilename(metadata.Filename)
+	if err != nil {
+		return nil, nil, NewProtocolError(ErrCodeInvalidFilename, "%v", err)
+	}
+
+	// ime iz FILE_START može imati poddirektorijume, ali mora se završavati
+	// imenom iz METADATA; da METADATA odgovara potpisanom zaglavlju kontejnera
+	// proverava se kada zaglavlje stigne (checkHeader)
+	if name != "" {
+		relPath, err := SanitizePath(name)
+		if err != nil {
+			return nil, nil, NewProtocolError(ErrCodeInvalidFilename, "%v", err)
+		}
+		if path.Base(relPath) != filename {
+			return nil, nil, NewProtocolError(ErrCodeInvalidFilename,
+				"transfer name %q does not match file name %q in metadata", relPath, filename)
 		}
+		filename = relPath
 	}
 
-	os.Remove(encryptedPath)
+	if err := s.checkStorable(metadata); err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkCollision(filename); err != nil {
+		return nil, nil, err
+	}
 
-	fileInfo, _ := os.Stat(outputPath)
-	logger.LogEncryption("decrypt", metadata.EncryptionAlgorithm, outputPath,
-		fileInfo.Size(), true, map[string]interface{}{
-			"hash_verified":  metadata.Hash != "",
-			"hash_algorithm": metadata.HashAlgorithm,
-			"iv_used":        metadata.IV != "",
-		})
+	metadataJSON, err := metadata.ToJSON()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize metadata: %w", err)
+	}
+
+	return &stagedTransfer{
+		ID:       transferID,
+		Filename: filename,
+		Size:     declaredSize,
+		Metadata: metadataJSON,
+		Identity: identity,
+		Created:  time.Now().UTC(),
+	}, metadata, nil
+}
 
-	log.Printf("✅ File successfully decrypted and verified: %s (%d bytes)",
-		filename, fileInfo.Size())
+// checkCollision rano odbija fajl koji već postoji kada je politika reject.
+func (s *TCPServer) checkCollision(filename string) error {
+	if s.collisionPolicy != CollisionReject {
+		return nil
+	}
+	filename = s.storedName(filename)
+	if _, err := os.Stat(filepath.Join(s.outputDir, filepath.FromSlash(filename))); err == nil {
+		return NewProtocolError(ErrCodeFileExists, "file %s already exists on server", filename)
+	}
 	return nil
 }
+
+// publishFile premešta dekriptovan i proveren fajl iz staging-a na mesto
+// koje određuje politika kolizija. name je već prošao SanitizePath.
+func (s *TCPServer) publishFile(stagedPath, name string) (string, error) {
+	if err := os.Chmod(stagedPath, 0644); err != nil {
+		os.Remove(stagedPath)
+		return "", fmt.Errorf("failed to move file to output directory: %w", err)
+	}
+
+	outputPath, err := publishOutputPath(stagedPath, s.outputDir, s.storedName(name), s.collisionPolicy)
+	if err != nil {
+		os.Remove(stagedPath)
+		return "", err
+	}
+	return outputPath, nil
+}
@@ -0,0 +1,127 @@
+package network
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/lea"
+)
+
+const (
+	sealedFrameAAD = "zastitaprojekat frame v1"
+	// Najveći zapečaćeni okvir: komanda, dužine, payload i GCM tag.
+	maxSealedFrameSize = MaxPacketSize + 1024
+)
+
+var ErrFrameAuth = errors.New("frame authentication failed: message was tampered with, replayed or reordered")
+
+// Session pečati svaki okvir posle handshake-a sa LEA-GCM. Ključevi su
+// posebni za svaki smer, a redni broj poruke je nonce, pa se ponovljena,
+// preuređena ili izbačena poruka ne može otvoriti.
+type Session struct {
+	ID string
+	// Capabilities su mogućnosti dogovorene u handshake-u.
+	Capabilities Capabilities
+
+	sendAEAD cipher.AEAD
+	recvAEAD cipher.AEAD
+	sendSeq  uint64
+	recvSeq  uint64
+}
+
+func newSession(sendKey, recvKey, id []byte) (*Session, error) {
+	sendAEAD, err := newFrameAEAD(sendKey)
+	if err != nil {
+		return nil, err
+	}
+	recvAEAD, err := newFrameAEAD(recvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		ID:       fmt.Sprintf("%x", id),
+		sendAEAD: sendAEAD,
+		recvAEAD: recvAEAD,
+	}, nil
+}
+
+func newFrameAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := lea.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncodeMessage vraća okvir: uint32 dužina + GCM(EncodeMessage(msg)).
+func (s *Session) EncodeMessage(msg Message) []byte {
+	plain := EncodeMessage(msg)
+
+	sealed := s.sendAEAD.Seal(nil, frameNonce(s.sendSeq), plain, frameAAD(s.sendSeq))
+	s.sendSeq++
+
+	frame := make([]byte, 4+len(sealed))
+	binary.BigEndian.PutUint32(frame[:4], uint32(len(sealed)))
+	copy(frame[4:], sealed)
+	return frame
+}
+
+func (s *Session) DecodeMessage(reader io.Reader) (Message, error) {
+	var msg Message
+
+	var frameLen uint32
+	if err := binary.Read(reader, binary.BigEndian, &frameLen); err != nil {
+		return msg, fmt.Errorf("failed to read frame length: %w", err)
+	}
+	if frameLen > maxSealedFrameSize {
+		return msg, NewProtocolError(ErrCodeFrameTooLarge, "frame of %d bytes exceeds limit of %d", frameLen, maxSealedFrameSize)
+	}
+
+	sealed := make([]byte, frameLen)
+	if _, err := io.ReadFull(reader, sealed); err != nil {
+		return msg, fmt.Errorf("failed to read frame: %w", err)
+	}
+
+	plain, err := s.recvAEAD.Open(nil, frameNonce(s.recvSeq), sealed, frameAAD(s.recvSeq))
+	if err != nil {
+		return msg, ErrFrameAuth
+	}
+	s.recvSeq++
+
+	inner := bytes.NewReader(plain)
+	msg, err = DecodeMessage(inner)
+	if err != nil {
+		return msg, err
+	}
+	if inner.Len() != 0 {
+		return msg, fmt.Errorf("trailing data in frame")
+	}
+	return msg, nil
+}
+
+func (s *Session) SendMessage(writer io.Writer, command string, payload []byte) error {
+	_, err := writer.Write(s.EncodeMessage(Message{Command: command, Payload: payload}))
+	return err
+}
+
+func (s *Session) ReceiveMessage(reader io.Reader) (Message, error) {
+	return s.DecodeMessage(reader)
+}
+
+func frameNonce(seq uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], seq)
+	return nonce
+}
+
+func frameAAD(seq uint64) []byte {
+	aad := make([]byte, len(sealedFrameAAD)+8)
+	copy(aad, sealedFrameAAD)
+	binary.BigEndian.PutUint64(aad[len(sealedFrameAAD):], seq)
+	return aad
+}
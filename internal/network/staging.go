@@ -0,0 +1,284 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/logger"
+)
+
+const (
+	ResumeCmd   = "RESUME"
+	ResumeOKCmd = "RESUME_OK"
+
+	stagingDirName         = ".staging"
+	transferIDSize         = 16
+	DefaultStagingExpiry   = 24 * time.Hour
+	stagingCleanupInterval = time.Hour
+)
+
+var ErrUnknownTransfer = errors.New("unknown or expired transfer")
+
+// StagingLimits ograničavaju nedovršene prenose, koji zauzimaju disk do
+// isteka roka i kada ih klijent napusti (0 = bez ograničenja). Računa se
+// veličina najavljena u FILE_START; bez fajla klijenata svi klijenti dele
+// isti (prazan) identitet.
+type StagingLimits struct {
+	MaxIdentity  int64
+	MaxTotal     int64
+	MaxTransfers int
+}
+
+var DefaultStagingLimits = StagingLimits{
+	MaxIdentity:  4 << 30,
+	MaxTotal:     16 << 30,
+	MaxTransfers: 16,
+}
+
+// stagedTransfer je stanje nedovršenog prenosa, čuva se pored .part fajla
+// da bi klijent posle prekida mogao da nastavi od poslednjeg primljenog bajta.
+type stagedTransfer struct {
+	ID       string          `json:"id"`
+	Filename string          `json:"filename"`
+	Size     int64           `json:"size"`
+	Metadata json.RawMessage `json:"metadata"`
+	Identity string          `json:"identity,omitempty"`
+	Created  time.Time       `json:"created"`
+}
+
+// stagingArea drži delimično primljene (šifrovane) fajlove u <output>/.staging.
+// Dekriptovan sadržaj postoji samo dok prenos traje.
+type stagingArea struct {
+	dir    string
+	expiry time.Duration
+	limits StagingLimits
+	mu     sync.Mutex
+}
+
+func newStagingArea(outputDir string, expiry time.Duration) *stagingArea {
+	return &stagingArea{dir: filepath.Join(outputDir, stagingDirName), expiry: expiry, limits: DefaultStagingLimits}
+}
+
+func newTransferID() (string, error) {
+	id := make([]byte, transferIDSize)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return "", fmt.Errorf("failed to generate transfer id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func validTransferID(id string) bool {
+	decoded, err := hex.DecodeString(id)
+	return err == nil && len(decoded) == transferIDSize
+}
+
+func (a *stagingArea) partPath(id string) string {
+	return filepath.Join(a.dir, id+".part")
+}
+
+func (a *stagingArea) statePath(id string) string {
+	return filepath.Join(a.dir, id+".json")
+}
+
+// outputPath je dekriptovan sadržaj prenosa u toku; na konačno mesto ide
+// tek kada provera uspe.
+func (a *stagingArea) outputPath(id string) string {
+	return filepath.Join(a.dir, id+".out")
+}
+
+// create počinje novi prenos; postojeći prenos sa istim ID-jem se ne prepisuje.
+func (a *stagingArea) create(state *stagedTransfer) (*os.File, error) {
+	if !validTransferID(state.ID) {
+		return nil, NewProtocolError(ErrCodeProtocol, "invalid transfer id")
+	}
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	// provera kvote i upis stanja su atomični u odnosu na druge konekcije
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.checkQuota(state); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize transfer state: %w", err)
+	}
+
+	stateFile, err := os.OpenFile(a.statePath(state.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, NewProtocolError(ErrCodeProtocol, "transfer %s already exists", state.ID)
+		}
+		return nil, fmt.Errorf("failed to write transfer state: %w", err)
+	}
+	_, err = stateFile.Write(data)
+	if closeErr := stateFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		a.remove(state.ID)
+		return nil, fmt.Errorf("failed to write transfer state: %w", err)
+	}
+
+	part, err := os.OpenFile(a.partPath(state.ID), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		a.remove(state.ID)
+		return nil, fmt.Errorf("failed to create staging file: %w", err)
+	}
+	return part, nil
+}
+
+// checkQuota odbija prenos koji bi prešao kvote za identitet klijenta ili
+// za ceo staging direktorijum.
+func (a *stagingArea) checkQuota(state *stagedTransfer) error {
+	if a.limits == (StagingLimits{}) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return NewProtocolError(ErrCodeInternal, "failed to check staging area: %v", err)
+	}
+
+	var identityBytes, totalBytes int64
+	var identityTransfers int
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(a.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var staged stagedTransfer
+		if err := json.Unmarshal(data, &staged); err != nil {
+			continue
+		}
+		totalBytes += staged.Size
+		if staged.Identity == state.Identity {
+			identityBytes += staged.Size
+			identityTransfers++
+		}
+	}
+
+	switch {
+	case a.limits.MaxTransfers > 0 && identityTransfers >= a.limits.MaxTransfers:
+		return NewProtocolError(ErrCodeQuotaExceeded,
+			"too many unfinished transfers (%d); resume or wait for them to expire", identityTransfers)
+	case a.limits.MaxIdentity > 0 && identityBytes+state.Size > a.limits.MaxIdentity:
+		return NewProtocolError(ErrCodeQuotaExceeded,
+			"unfinished transfers would exceed %d bytes (%d bytes staged)", a.limits.MaxIdentity, identityBytes)
+	case a.limits.MaxTotal > 0 && totalBytes+state.Size > a.limits.MaxTotal:
+		return NewProtocolError(ErrCodeQuotaExceeded, "server staging area is full")
+	}
+	return nil
+}
+
+// resume otvara postojeći prenos za dopisivanje i vraća broj već primljenih bajtova.
+func (a *stagingArea) resume(id, identity string) (*stagedTransfer, *os.File, int64, error) {
+	if !validTransferID(id) {
+		return nil, nil, 0, NewProtocolError(ErrCodeUnknownTransfer, "invalid transfer id")
+	}
+
+	data, err := os.ReadFile(a.statePath(id))
+	if err != nil {
+		return nil, nil, 0, NewProtocolError(ErrCodeUnknownTransfer, "%v", ErrUnknownTransfer)
+	}
+	var state stagedTransfer
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, nil, 0, fmt.Errorf("corrupted transfer state %s: %w", id, err)
+	}
+
+	// prenos može da nastavi samo isti autentifikovani klijent
+	if state.Identity != identity {
+		return nil, nil, 0, NewProtocolError(ErrCodeUnknownTransfer, "%v", ErrUnknownTransfer)
+	}
+
+	part, err := os.OpenFile(a.partPath(id), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, 0, NewProtocolError(ErrCodeUnknownTransfer, "%v", ErrUnknownTransfer)
+	}
+	info, err := part.Stat()
+	if err != nil {
+		part.Close()
+		return nil, nil, 0, fmt.Errorf("failed to stat staging file: %w", err)
+	}
+	if info.Size() > state.Size {
+		part.Close()
+		a.remove(id)
+		return nil, nil, 0, NewProtocolError(ErrCodeUnknownTransfer, "staged data larger than declared size")
+	}
+
+	return &state, part, info.Size(), nil
+}
+
+func (a *stagingArea) remove(id string) {
+	os.Remove(a.partPath(id))
+	os.Remove(a.statePath(id))
+	os.Remove(a.outputPath(id))
+}
+
+// removeOutputs briše dekriptovan sadržaj koji je ostao posle pada servera.
+// Nastavak prenosa ga ponovo pravi iz šifrovanog .part fajla.
+func (a *stagingArea) removeOutputs() {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".out" {
+			os.Remove(filepath.Join(a.dir, entry.Name()))
+		}
+	}
+}
+
+// cleanExpired briše prenose u kojima ništa nije primljeno duže od roka.
+func (a *stagingArea) cleanExpired() {
+	if a.expiry <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-a.expiry)
+	removed := 0
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		id := entry.Name()[:len(entry.Name())-len(".json")]
+
+		lastActivity := time.Time{}
+		for _, path := range []string{a.statePath(id), a.partPath(id)} {
+			if info, err := os.Stat(path); err == nil && info.ModTime().After(lastActivity) {
+				lastActivity = info.ModTime()
+			}
+		}
+		if lastActivity.Before(cutoff) {
+			a.remove(id)
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		logger.Info(logger.RECEIVE_FILE, "Expired partial transfers removed", true, map[string]interface{}{
+			"staging_dir": a.dir,
+			"removed":     removed,
+			"expiry":      a.expiry.String(),
+		})
+	}
+}
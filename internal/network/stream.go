@@ -0,0 +1,119 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/core"
+)
+
+const fileChunkSize = 32 * 1024
+
+// dataWriter deli šifrovan tok na FILE_DATA poruke, pa se fajl šalje dok se
+// šifruje, bez privremenog fajla.
+type dataWriter struct {
+	writer  io.Writer
+	session *Session
+	buf     []byte
+	sent    int64
+	chunks  int
+	err     error
+	onChunk func(sent int64, chunks int)
+}
+
+func newDataWriter(writer io.Writer, session *Session) *dataWriter {
+	return &dataWriter{writer: writer, session: session, buf: make([]byte, 0, fileChunkSize)}
+}
+
+func (w *dataWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	chunkSize := w.chunkSize()
+	for len(p) > 0 {
+		take := min(chunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:take]...)
+		p = p[take:]
+
+		if len(w.buf) == chunkSize {
+			if err := w.Flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// chunkSize poštuje najveći okvir dogovoren u handshake-u.
+func (w *dataWriter) chunkSize() int {
+	if frame := w.session.Capabilities.MaxFrame; frame > 0 && frame < fileChunkSize {
+		return frame
+	}
+	return fileChunkSize
+}
+
+// Flush šalje nepotpun poslednji chunk.
+func (w *dataWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if err := w.session.SendMessage(w.writer, FileDataCmd, w.buf); err != nil {
+		w.err = err
+		return err
+	}
+
+	w.sent += int64(len(w.buf))
+	w.chunks++
+	w.buf = w.buf[:0]
+	if w.onChunk != nil {
+		w.onChunk(w.sent, w.chunks)
+	}
+	return nil
+}
+
+// containerError dodeljuje kod grešci provere primljenog kontejnera.
+func containerError(err error) error {
+	var protocolErr *ProtocolError
+	var mismatch *core.KeyMismatchError
+	switch {
+	case errors.As(err, &protocolErr):
+		return err
+	case errors.As(err, &mismatch):
+		return NewProtocolError(ErrCodeKeyMismatch, "%v", err)
+	case errors.Is(err, core.ErrHeaderTooLarge):
+		return NewProtocolError(ErrCodeTransferTooLarge, "%v", err)
+	case errors.Is(err, core.ErrNotSigned), errors.Is(err, core.ErrInvalidSignature), errors.Is(err, core.ErrUntrustedSigner):
+		return NewProtocolError(ErrCodeSignatureRejected, "%v", err)
+	}
+	return NewProtocolError(ErrCodeVerification, "decryption/verification failed: %v", err)
+}
+
+// checkHeader odbija kontejner čije zaglavlje (koje pokriva potpis) ne
+// odgovara METADATA poruci na osnovu koje je prenos prihvaćen.
+func checkHeader(claimed, header *core.Metadata) error {
+	switch {
+	case header.Filename != claimed.Filename:
+		return NewProtocolError(ErrCodeInvalidFilename,
+			"file name %q in metadata does not match %q in the container header", claimed.Filename, header.Filename)
+	case header.EncryptionAlgorithm != claimed.EncryptionAlgorithm:
+		return NewProtocolError(ErrCodeVerification,
+			"algorithm %s in metadata does not match %s in the container header", claimed.EncryptionAlgorithm, header.EncryptionAlgorithm)
+	case header.KeyInfo != claimed.KeyInfo:
+		return NewProtocolError(ErrCodeVerification,
+			"key fingerprint %s in metadata does not match %s in the container header", claimed.KeyInfo, header.KeyInfo)
+	}
+	return nil
+}
+
+// outputFile označava greške pisanja dekriptovanog fajla kao INTERNAL_ERROR,
+// da se ne bi prijavile kao neuspela provera.
+type outputFile struct {
+	*os.File
+}
+
+func (f outputFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	if err != nil {
+		return n, NewProtocolError(ErrCodeInternal, "failed to write output file: %v", err)
+	}
+	return n, nil
+}
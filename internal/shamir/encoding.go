@@ -0,0 +1,60 @@
+package shamir
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AleksaS003/zastitaprojekat/internal/algorithms/sha256"
+)
+
+const sharePrefix = "zsss1"
+
+// String kodira deo kao "zsss1-<prag>-<indeks>-<otisak ključa>-<podaci>-<checksum>".
+// Checksum hvata greške pri kopiranju, a otisak ključa pogrešne kombinacije.
+func (s *Share) String() string {
+	body := fmt.Sprintf("%s-%d-%d-%s-%s", sharePrefix, s.Threshold, s.Index, s.Fingerprint, hex.EncodeToString(s.Data))
+	return body + "-" + shareChecksum(body)
+}
+
+func ParseShare(text string) (*Share, error) {
+	text = strings.TrimSpace(text)
+
+	parts := strings.Split(text, "-")
+	if len(parts) != 6 || parts[0] != sharePrefix {
+		return nil, fmt.Errorf("invalid share format")
+	}
+
+	body := strings.Join(parts[:5], "-")
+	if shareChecksum(body) != parts[5] {
+		return nil, fmt.Errorf("share checksum mismatch (share is corrupted)")
+	}
+
+	threshold, err := strconv.Atoi(parts[1])
+	if err != nil || threshold < 2 || threshold > MaxShares {
+		return nil, fmt.Errorf("invalid share threshold: %s", parts[1])
+	}
+
+	index, err := strconv.Atoi(parts[2])
+	if err != nil || index < 1 || index > MaxShares {
+		return nil, fmt.Errorf("invalid share index: %s", parts[2])
+	}
+
+	data, err := hex.DecodeString(parts[4])
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid share data")
+	}
+
+	return &Share{
+		Index:       byte(index),
+		Threshold:   threshold,
+		Fingerprint: parts[3],
+		Data:        data,
+	}, nil
+}
+
+func shareChecksum(body string) string {
+	sum := sha256.HashString(body)
+	return hex.EncodeToString(sum[:4])
+}
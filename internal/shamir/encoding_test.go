@@ -0,0 +1,55 @@
+package shamir
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestShareRoundTrip(t *testing.T) {
+	shares, err := Split(testSecret, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	share := shares[2]
+	share.Fingerprint = "0a1b2c3d"
+
+	parsed, err := ParseShare(" " + share.String() + "\n")
+	if err != nil {
+		t.Fatalf("ParseShare: %v", err)
+	}
+	if parsed.Index != share.Index || parsed.Threshold != share.Threshold ||
+		parsed.Fingerprint != share.Fingerprint || !bytes.Equal(parsed.Data, share.Data) {
+		t.Errorf("parsed %+v, want %+v", parsed, share)
+	}
+}
+
+func TestParseShareChecksumMismatch(t *testing.T) {
+	share := &Share{Index: 1, Threshold: 2, Fingerprint: "0a1b2c3d", Data: []byte{0x01, 0x02, 0x03}}
+	text := share.String()
+
+	// jedna promenjena cifra u podacima
+	corrupted := strings.Replace(text, "-010203-", "-010303-", 1)
+	if corrupted == text {
+		t.Fatal("test did not corrupt the share")
+	}
+	_, err := ParseShare(corrupted)
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("got %v, want a checksum error", err)
+	}
+}
+
+func TestParseShareInvalidFormat(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"zsss1-2-1-fp-0102",
+		"zsss2-2-1-fp-0102-00000000",
+		(&Share{Index: 1, Threshold: 1, Fingerprint: "fp", Data: []byte{1}}).String(),
+		(&Share{Index: 0, Threshold: 2, Fingerprint: "fp", Data: []byte{1}}).String(),
+		(&Share{Index: 1, Threshold: 2, Fingerprint: "fp"}).String(),
+	} {
+		if _, err := ParseShare(text); err == nil {
+			t.Errorf("ParseShare(%q) succeeded", text)
+		}
+	}
+}
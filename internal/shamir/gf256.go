@@ -0,0 +1,65 @@
+package shamir
+
+// Aritmetika u GF(2^8) sa redukcionim polinomom x^8 + x^4 + x^3 + x + 1 (0x11b)
+// i generatorom 3, preko log/exp tabela.
+
+var (
+	expTable [510]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		expTable[i+255] = x
+		logTable[x] = byte(i)
+		x = gfMulSlow(x, 3)
+	}
+}
+
+func gfMulSlow(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func gfAdd(a, b byte) byte {
+	return a ^ b
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if b == 0 {
+		panic("shamir: division by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
+
+// evaluate računa vrednost polinoma sa koeficijentima coeffs u tački x (Horner).
+func evaluate(coeffs []byte, x byte) byte {
+	result := coeffs[len(coeffs)-1]
+	for i := len(coeffs) - 2; i >= 0; i-- {
+		result = gfAdd(gfMul(result, x), coeffs[i])
+	}
+	return result
+}
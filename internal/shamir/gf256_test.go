@@ -0,0 +1,34 @@
+package shamir
+
+import "testing"
+
+func TestGFMulMatchesSlow(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 0; b < 256; b++ {
+			if got, want := gfMul(byte(a), byte(b)), gfMulSlow(byte(a), byte(b)); got != want {
+				t.Fatalf("gfMul(%#x, %#x) = %#x, want %#x", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestGFDivInvertsMul(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			if got := gfDiv(gfMul(byte(a), byte(b)), byte(b)); got != byte(a) {
+				t.Fatalf("(%#x * %#x) / %#x = %#x", a, b, b, got)
+			}
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	// p(x) = 0x42 + 0x07x + 0x11x^2
+	coeffs := []byte{0x42, 0x07, 0x11}
+	for x := 0; x < 256; x++ {
+		want := coeffs[0] ^ gfMulSlow(coeffs[1], byte(x)) ^ gfMulSlow(coeffs[2], gfMulSlow(byte(x), byte(x)))
+		if got := evaluate(coeffs, byte(x)); got != want {
+			t.Fatalf("p(%#x) = %#x, want %#x", x, got, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const MaxShares = 255
+
+type Share struct {
+	Index       byte
+	Threshold   int
+	Fingerprint string
+	Data        []byte
+}
+
+// Split deli secret na n delova tako da je bilo kojih threshold dovoljno za
+// rekonstrukciju, a manje od toga ne otkriva ništa o tajni.
+func Split(secret []byte, n, threshold int) ([]*Share, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("secret must not be empty")
+	}
+	if threshold < 2 {
+		return nil, errors.New("threshold must be at least 2")
+	}
+	if n < threshold {
+		return nil, fmt.Errorf("number of shares (%d) must be at least threshold (%d)", n, threshold)
+	}
+	if n > MaxShares {
+		return nil, fmt.Errorf("number of shares must not exceed %d", MaxShares)
+	}
+
+	shares := make([]*Share, n)
+	for i := range shares {
+		shares[i] = &Share{
+			Index:     byte(i + 1),
+			Threshold: threshold,
+			Data:      make([]byte, len(secret)),
+		}
+	}
+
+	coeffs := make([]byte, threshold)
+	for b, secretByte := range secret {
+		coeffs[0] = secretByte
+		if _, err := io.ReadFull(rand.Reader, coeffs[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate coefficients: %w", err)
+		}
+
+		for _, share := range shares {
+			share.Data[b] = evaluate(coeffs, share.Index)
+		}
+	}
+
+	for i := range coeffs {
+		coeffs[i] = 0
+	}
+
+	return shares, nil
+}
+
+// Combine rekonstruiše tajnu Lagranžovom interpolacijom u x = 0.
+func Combine(shares []*Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares provided")
+	}
+
+	first := shares[0]
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("need at least %d shares, got %d", first.Threshold, len(shares))
+	}
+
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		if share.Index == 0 {
+			return nil, errors.New("invalid share index 0")
+		}
+		if seen[share.Index] {
+			return nil, fmt.Errorf("duplicate share index %d", share.Index)
+		}
+		seen[share.Index] = true
+
+		if share.Threshold != first.Threshold {
+			return nil, errors.New("shares have different thresholds")
+		}
+		if share.Fingerprint != first.Fingerprint {
+			return nil, errors.New("shares belong to different keys (fingerprint mismatch)")
+		}
+		if len(share.Data) != len(first.Data) {
+			return nil, errors.New("shares have different lengths")
+		}
+	}
+
+	used := shares[:first.Threshold]
+	secret := make([]byte, len(first.Data))
+
+	for i, share := range used {
+		basis := byte(1)
+		for j, other := range used {
+			if i == j {
+				continue
+			}
+			basis = gfMul(basis, gfDiv(other.Index, gfAdd(other.Index, share.Index)))
+		}
+
+		for b := range secret {
+			secret[b] = gfAdd(secret[b], gfMul(share.Data[b], basis))
+		}
+	}
+
+	return secret, nil
+}
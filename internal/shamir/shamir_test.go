@@ -0,0 +1,111 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func splitForTest(t *testing.T, n, threshold int) []*Share {
+	t.Helper()
+	shares, err := Split(testSecret, n, threshold)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	for _, share := range shares {
+		share.Fingerprint = "fp"
+	}
+	return shares
+}
+
+func TestCombineAtAndAboveThreshold(t *testing.T) {
+	shares := splitForTest(t, 5, 3)
+
+	subsets := [][]*Share{
+		{shares[0], shares[1], shares[2]},
+		{shares[4], shares[2], shares[0]},
+		{shares[1], shares[3], shares[4]},
+		{shares[0], shares[1], shares[2], shares[3]},
+		shares,
+	}
+	for _, subset := range subsets {
+		secret, err := Combine(subset)
+		if err != nil {
+			t.Fatalf("Combine(%d shares): %v", len(subset), err)
+		}
+		if !bytes.Equal(secret, testSecret) {
+			t.Errorf("Combine(%d shares) = %x, want the secret", len(subset), secret)
+		}
+	}
+}
+
+func TestCombineBelowThreshold(t *testing.T) {
+	shares := splitForTest(t, 5, 3)
+
+	if secret, err := Combine(shares[:2]); err == nil {
+		t.Fatalf("Combine accepted 2 of 3 shares and returned %x", secret)
+	}
+
+	// i sa lažno sniženim pragom interpolacija kroz dve tačke ne daje tajnu
+	forged := []*Share{{}, {}}
+	for i := range forged {
+		*forged[i] = *shares[i]
+		forged[i].Threshold = 2
+	}
+	secret, err := Combine(forged)
+	if err != nil {
+		t.Fatalf("Combine: %v", err)
+	}
+	if bytes.Equal(secret, testSecret) {
+		t.Error("two shares of a 3-of-5 split revealed the secret")
+	}
+}
+
+func TestCombineRejectsInvalidIndexes(t *testing.T) {
+	shares := splitForTest(t, 3, 2)
+
+	duplicate := *shares[0]
+	if _, err := Combine([]*Share{shares[0], &duplicate}); err == nil {
+		t.Error("Combine accepted a duplicate share index")
+	}
+
+	zero := *shares[1]
+	zero.Index = 0
+	if _, err := Combine([]*Share{shares[0], &zero}); err == nil {
+		t.Error("Combine accepted share index 0")
+	}
+}
+
+func TestCombineRejectsMismatchedShares(t *testing.T) {
+	shares := splitForTest(t, 3, 2)
+
+	other := *shares[1]
+	other.Fingerprint = "other"
+	if _, err := Combine([]*Share{shares[0], &other}); err == nil {
+		t.Error("Combine accepted shares with different fingerprints")
+	}
+
+	short := *shares[1]
+	short.Data = short.Data[:8]
+	if _, err := Combine([]*Share{shares[0], &short}); err == nil {
+		t.Error("Combine accepted shares of different lengths")
+	}
+}
+
+func TestSplitRejectsInvalidParameters(t *testing.T) {
+	tests := []struct {
+		secret       []byte
+		n, threshold int
+	}{
+		{nil, 3, 2},
+		{testSecret, 3, 1},
+		{testSecret, 2, 3},
+		{testSecret, MaxShares + 1, 2},
+	}
+	for _, tt := range tests {
+		if _, err := Split(tt.secret, tt.n, tt.threshold); err == nil {
+			t.Errorf("Split(%d bytes, %d, %d) succeeded", len(tt.secret), tt.n, tt.threshold)
+		}
+	}
+}